@@ -0,0 +1,428 @@
+package admin
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"vending-machine/cashaudit"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+)
+
+//Admin - maintenance mode of a machine for operators, protected by PIN
+//every action changes the machine through the same APIs as purchases and is recorded in the audit log
+type Admin struct {
+	Machine *payment.Machine
+
+	//PIN - PIN that must be entered before any command, empty for disabling admin mode
+	PIN string
+
+	//AuditLog - where admin actions are recorded, nil for not recording
+	AuditLog AuditLog
+
+	//Ledger - purchases that moved money since the previous cash audit, nil for no movements
+	Ledger SalesLedger
+
+	//CashAudits - where cash audits are kept, nil for not keeping
+	CashAudits CashAuditStore
+
+	//Clock - source of time of admin actions, nil for the system clock
+	Clock payment.Clock
+}
+
+//SalesLedger - finished purchases recorded by the machine
+type SalesLedger interface {
+	Entries() ([]payment.LedgerEntry, error)
+}
+
+//CashAuditStore - cash audits kept so discrepancies can be traced over time
+type CashAuditStore interface {
+	Append(cashAudit cashaudit.CashAudit) error
+
+	//CashAudits - every cash audit, oldest first
+	CashAudits() ([]cashaudit.CashAudit, error)
+}
+
+//Totals - summary of the machine's stock
+type Totals struct {
+	ProductPieces int64        //pieces of every product in stock
+	ProductValue  money.Amount //price of every product in stock
+	ChangeValue   money.Amount //value of money that can be used for change
+	CashBoxValue  money.Amount //value of money in cash box
+}
+
+//NewAdmin - create admin mode of machine protected by pin
+func NewAdmin(machine *payment.Machine, pin string, auditLog AuditLog) *Admin {
+	return &Admin{
+		Machine:  machine,
+		PIN:      pin,
+		AuditLog: auditLog,
+	}
+}
+
+//Run - ask for PIN then read admin commands from user until user type "exit"
+func (a *Admin) Run(userInput io.Reader, output io.Writer) error {
+
+	//if userInput is not given (for test purpose) then use from stdin instead
+	if userInput == nil {
+		userInput = os.Stdin
+	}
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	if a.PIN == "" {
+		return errors.New("admin mode is disabled")
+	}
+
+	//whole lines are read so a name can have spaces, one reader is kept so nothing it has read ahead is lost
+	lines := bufio.NewReader(userInput)
+
+	fmt.Fprint(output, "Please enter PIN: ")
+	pin, ok := readLine(lines)
+	if !ok {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(a.PIN)) != 1 {
+		err := a.record(AuditEntry{Action: ACTION_LOGIN_FAILED})
+		if err != nil {
+			return err
+		}
+		return errors.New("wrong PIN")
+	}
+	err := a.record(AuditEntry{Action: ACTION_LOGIN})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(output, "------------ Admin ------------")
+	for {
+		fmt.Fprint(output, "\nPlease type command (restock, add, price, remove, refill, empty, jam, clear, assign, totals, audit, audits) or \"exit\" to leave admin mode: ")
+		command, ok := readLine(lines)
+		if !ok || command == "exit" {
+			return a.record(AuditEntry{Action: ACTION_LOGOUT})
+		}
+
+		err = a.execute(command, lines, output)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//execute - read arguments of command from user then do it,
+//error only if it can't be recorded, failed command is printed so user can try again
+func (a *Admin) execute(command string, userInput *bufio.Reader, output io.Writer) error {
+	switch command {
+	case "restock":
+		productNo, ok := readProductNo(userInput, output)
+		if !ok {
+			return nil
+		}
+		amount, ok := readNumber(userInput, output, "Amount")
+		if !ok {
+			return nil
+		}
+		return a.report(output, AuditEntry{Action: ACTION_RESTOCK_PRODUCT, ProductNo: productNo, Amount: amount},
+			a.Machine.RestockProduct(productNo, amount))
+
+	case "add":
+		productNo, ok := readProductNo(userInput, output)
+		if !ok {
+			return nil
+		}
+		fmt.Fprint(output, "Name: ")
+		name, _ := readLine(userInput)
+		price, ok := readAmount(userInput, output, "Price")
+		if !ok {
+			return nil
+		}
+		stock, ok := readNumber(userInput, output, "Stock")
+		if !ok {
+			return nil
+		}
+		newProduct := product.Product{ProductNo: productNo, Name: name, Price: price, Stock: stock}
+		return a.report(output, AuditEntry{Action: ACTION_ADD_PRODUCT, ProductNo: productNo, Name: name, Price: price, Amount: stock},
+			a.Machine.AddProduct(newProduct))
+
+	case "price":
+		productNo, ok := readProductNo(userInput, output)
+		if !ok {
+			return nil
+		}
+		price, ok := readAmount(userInput, output, "Price")
+		if !ok {
+			return nil
+		}
+		return a.report(output, AuditEntry{Action: ACTION_CHANGE_PRICE, ProductNo: productNo, Price: price},
+			a.Machine.ChangePrice(productNo, price))
+
+	case "remove":
+		productNo, ok := readProductNo(userInput, output)
+		if !ok {
+			return nil
+		}
+		removedProduct, err := a.Machine.RemoveProduct(productNo)
+		return a.report(output, AuditEntry{Action: ACTION_REMOVE_PRODUCT, ProductNo: productNo, Name: removedProduct.Name, Amount: removedProduct.Stock},
+			err)
+
+	case "refill":
+		fmt.Fprint(output, "Money: ")
+		moneyName, _ := readLine(userInput)
+		amount, ok := readNumber(userInput, output, "Amount")
+		if !ok {
+			return nil
+		}
+		return a.report(output, AuditEntry{Action: ACTION_REFILL_MONEY, Name: moneyName, Amount: amount},
+			a.Machine.RestockMoney(moneyName, amount))
+
+	case "empty":
+		emptied, err := a.Machine.EmptyCashBox()
+		var emptiedValue money.Amount
+		emptiedPieces := make(map[string]int64)
+		for _, mon := range emptied {
+			fmt.Fprintf(output, "%+v %+v for %+v pieces\n", mon.MoneyType, mon.Name, mon.CashBox)
+			emptiedValue = emptiedValue + mon.Value.Times(mon.CashBox)
+			emptiedPieces[mon.Name] = mon.CashBox
+		}
+		fmt.Fprintln(output, "taken from cash box: ", emptiedValue, "THB")
+		return a.report(output, AuditEntry{Action: ACTION_EMPTY_CASH_BOX, Value: emptiedValue, Pieces: emptiedPieces}, err)
+
+	case "jam", "clear":
+		fmt.Fprint(output, "Slot: ")
+		code, _ := readLine(userInput)
+		action := ACTION_JAM_SLOT
+		if command == "clear" {
+			action = ACTION_CLEAR_SLOT
+		}
+		return a.report(output, AuditEntry{Action: action, Name: code},
+			a.Machine.SetSlotJammed(code, command == "jam"))
+
+	case "assign":
+		fmt.Fprint(output, "Slot: ")
+		code, _ := readLine(userInput)
+		productNo, ok := readNumber(userInput, output, "Product No (0 for unassigned)")
+		if !ok {
+			return nil
+		}
+		return a.report(output, AuditEntry{Action: ACTION_ASSIGN_SLOT, Name: code, ProductNo: productNo},
+			a.Machine.AssignSlot(code, productNo))
+
+	case "totals":
+		a.Machine.ListAllProducts(output)
+		a.Machine.ListAvailableMoney(output)
+		PrintTotals(output, a.Totals())
+		return a.record(AuditEntry{Action: ACTION_VIEW_TOTALS})
+
+	case "audit":
+		return a.cashAudit(userInput, output)
+
+	case "audits":
+		cashAudits, err := a.cashAudits()
+		if err != nil {
+			fmt.Fprintf(output, "%+v, please try again\n", err)
+			return nil
+		}
+		cashaudit.PrintHistory(output, cashAudits)
+		return nil
+	}
+
+	fmt.Fprintln(output, "command doesn't exist, please try again")
+	return nil
+}
+
+//cashAudit - read pieces of each money counted by user, then compare them with the machine's money's stock
+//and keep the result
+func (a *Admin) cashAudit(userInput *bufio.Reader, output io.Writer) error {
+	counted := make(map[string]int64)
+	moneyList := a.Machine.Bank.List()
+	for _, mon := range moneyList {
+		count, ok := readNumber(userInput, output, "Counted "+mon.MoneyType+" "+mon.Name)
+		if !ok {
+			return nil
+		}
+		if count < 0 {
+			fmt.Fprintln(output, "invalid input, please try again")
+			return nil
+		}
+		counted[mon.Name] = count
+	}
+
+	//movements are counted from the previous cash audit
+	var previous cashaudit.CashAudit
+	cashAudits, err := a.cashAudits()
+	if err != nil {
+		return a.report(output, AuditEntry{Action: ACTION_CASH_AUDIT}, err)
+	}
+	if len(cashAudits) > 0 {
+		previous = cashAudits[len(cashAudits)-1]
+	}
+
+	entries := []payment.LedgerEntry{}
+	if a.Ledger != nil {
+		entries, err = a.Ledger.Entries()
+		if err != nil {
+			return a.report(output, AuditEntry{Action: ACTION_CASH_AUDIT}, err)
+		}
+	}
+
+	adjustments, err := a.adjustments()
+	if err != nil {
+		return a.report(output, AuditEntry{Action: ACTION_CASH_AUDIT}, err)
+	}
+
+	cashAudit := cashaudit.NewCashAudit(moneyList, counted, entries, adjustments, previous, a.clock().Now())
+	cashaudit.PrintCashAudit(output, cashAudit)
+	if a.CashAudits != nil {
+		err = a.CashAudits.Append(cashAudit)
+	}
+	return a.report(output, AuditEntry{Action: ACTION_CASH_AUDIT, Value: cashAudit.DiscrepancyValue}, err)
+}
+
+//adjustments - money refilled or taken from cash box by successful admin actions of the audit log
+func (a *Admin) adjustments() ([]cashaudit.Adjustment, error) {
+	adjustments := []cashaudit.Adjustment{}
+	if a.AuditLog == nil {
+		return adjustments, nil
+	}
+
+	entries, err := a.AuditLog.Entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Error != "" {
+			continue
+		}
+		switch entry.Action {
+		case ACTION_REFILL_MONEY:
+			adjustments = append(adjustments, cashaudit.Adjustment{Time: entry.Time, Name: entry.Name, Pieces: entry.Amount})
+		case ACTION_EMPTY_CASH_BOX:
+			for name, pieces := range entry.Pieces {
+				adjustments = append(adjustments, cashaudit.Adjustment{Time: entry.Time, Name: name, Pieces: -pieces})
+			}
+		}
+	}
+	return adjustments, nil
+}
+
+//cashAudits - every kept cash audit, oldest first
+func (a *Admin) cashAudits() ([]cashaudit.CashAudit, error) {
+	if a.CashAudits == nil {
+		return []cashaudit.CashAudit{}, nil
+	}
+	return a.CashAudits.CashAudits()
+}
+
+//Totals - summary of the machine's stock
+func (a *Admin) Totals() Totals {
+	var totals Totals
+	state := a.Machine.State()
+	for _, prod := range state.Products {
+		totals.ProductPieces = totals.ProductPieces + prod.Stock
+		totals.ProductValue = totals.ProductValue + prod.Price.Times(prod.Stock)
+	}
+	for _, mon := range state.Money {
+		totals.ChangeValue = totals.ChangeValue + mon.Value.Times(mon.Stock)
+		totals.CashBoxValue = totals.CashBoxValue + mon.Value.Times(mon.CashBox)
+	}
+	return totals
+}
+
+//PrintTotals - print summary of the machine's stock
+func PrintTotals(output io.Writer, totals Totals) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	fmt.Fprintln(output, "------------ Totals ------------")
+	fmt.Fprintln(output, "products in stock: ", totals.ProductPieces, "pieces")
+	fmt.Fprintln(output, "value of products: ", totals.ProductValue, "THB")
+	fmt.Fprintln(output, "money for change:  ", totals.ChangeValue, "THB")
+	fmt.Fprintln(output, "money in cash box: ", totals.CashBoxValue, "THB")
+	fmt.Fprintln(output, "total money:       ", totals.ChangeValue+totals.CashBoxValue, "THB")
+	fmt.Fprintln(output, "--------------------------------")
+}
+
+//report - print result of action then record it
+func (a *Admin) report(output io.Writer, entry AuditEntry, err error) error {
+	if err != nil {
+		fmt.Fprintf(output, "%+v, please try again\n", err)
+		entry.Error = err.Error()
+	} else {
+		fmt.Fprintln(output, "done")
+	}
+	return a.record(entry)
+}
+
+//record - write entry that happens now to the audit log if there is one
+func (a *Admin) record(entry AuditEntry) error {
+	if a.AuditLog == nil {
+		return nil
+	}
+	entry.Time = a.clock().Now()
+	return a.AuditLog.Record(entry)
+}
+
+//clock - admin's clock, system clock if it's not given
+func (a *Admin) clock() payment.Clock {
+	if a.Clock == nil {
+		return payment.SystemClock{}
+	}
+	return a.Clock
+}
+
+//readProductNo - ask user for product no., false if it's invalid
+func readProductNo(userInput *bufio.Reader, output io.Writer) (int64, bool) {
+	productNo, ok := readNumber(userInput, output, "Product No")
+	return productNo, ok
+}
+
+//readNumber - ask user for 64-bit number named name, false if it's invalid
+func readNumber(userInput *bufio.Reader, output io.Writer, name string) (int64, bool) {
+	fmt.Fprintf(output, "%v: ", name)
+	line, ok := readLine(userInput)
+	if !ok {
+		return 0, false
+	}
+
+	number, err := strconv.ParseInt(line, 10, 64)
+	if err != nil {
+		fmt.Fprintln(output, "invalid input, please try again")
+		return 0, false
+	}
+	return number, true
+}
+
+//readAmount - read amount of baht like 12.50 named name from userInput, false if it's invalid or there is nothing left to read
+func readAmount(userInput *bufio.Reader, output io.Writer, name string) (money.Amount, bool) {
+	fmt.Fprintf(output, "%v: ", name)
+	line, ok := readLine(userInput)
+	if !ok {
+		return 0, false
+	}
+
+	amount, err := money.ParseAmount(line)
+	if err != nil {
+		fmt.Fprintln(output, "invalid input, please try again")
+		return 0, false
+	}
+	return amount, true
+}
+
+//readLine - read a whole line from userInput without spaces around it, false if there is nothing left to read
+func readLine(userInput *bufio.Reader) (string, bool) {
+	line, err := userInput.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimSpace(line), true
+}
@@ -0,0 +1,418 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"vending-machine/cashaudit"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData() *payment.Machine {
+	return payment.NewMachine([]product.Product{
+		{
+			ProductNo: 1,
+			Name:      "Lays",
+			Price:     money.Baht(5),
+			Stock:     1,
+		},
+		{
+			ProductNo: 2,
+			Name:      "Hanami",
+			Price:     money.Baht(10),
+			Stock:     10,
+		},
+	}, []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     2,
+			Capacity:  10,
+			CashBox:   1,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "5",
+			Value:     money.Baht(5),
+			Stock:     0,
+		},
+		{
+			MoneyType:     money.BANK,
+			Name:          "1000",
+			Value:         money.Baht(1000),
+			NonRecyclable: true,
+			CashBox:       1,
+		},
+	})
+}
+
+//memoryAuditLog - AuditLog that keeps every recorded entry
+type memoryAuditLog struct {
+	entries []AuditEntry
+	err     error
+}
+
+func (l *memoryAuditLog) Record(entry AuditEntry) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *memoryAuditLog) Entries() ([]AuditEntry, error) {
+	return l.entries, nil
+}
+
+func Test_Admin_Run(t *testing.T) {
+	tests := []struct {
+		description      string
+		input            string
+		expectedProducts []product.Product
+		expectedMoney    []money.Money
+		expectedEntries  []AuditEntry
+		expectedOutput   []string
+		expectedError    error
+		hasError         bool
+	}{
+		{
+			description:      "test_run_success_restock_and_refill",
+			input:            "1234\nrestock\n1\n5\nrefill\n5\n4\nexit\n",
+			expectedProducts: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 6}, {ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 10}},
+			expectedMoney: []money.Money{
+				{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), NonRecyclable: true, CashBox: 1},
+				{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 2, Capacity: 10, CashBox: 1},
+				{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 4},
+			},
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_RESTOCK_PRODUCT, ProductNo: 1, Amount: 5},
+				{Action: ACTION_REFILL_MONEY, Name: "5", Amount: 4},
+				{Action: ACTION_LOGOUT},
+			},
+			hasError: false,
+		},
+		{
+			description:      "test_run_success_restock_beyond_int8",
+			input:            "1234\nrestock\n2\n200\nexit\n",
+			expectedProducts: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1}, {ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 210}},
+			expectedMoney:    commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_RESTOCK_PRODUCT, ProductNo: 2, Amount: 200},
+				{Action: ACTION_LOGOUT},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_run_success_add_change_price_and_remove_product",
+			input:       "1234\nadd\n3\nOreo\n20\n7\nprice\n2\n12\nremove\n1\nexit\n",
+			expectedProducts: []product.Product{
+				{ProductNo: 2, Name: "Hanami", Price: money.Baht(12), Stock: 10},
+				{ProductNo: 3, Name: "Oreo", Price: money.Baht(20), Stock: 7},
+			},
+			expectedMoney: commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_ADD_PRODUCT, ProductNo: 3, Name: "Oreo", Price: money.Baht(20), Amount: 7},
+				{Action: ACTION_CHANGE_PRICE, ProductNo: 2, Price: money.Baht(12)},
+				{Action: ACTION_REMOVE_PRODUCT, ProductNo: 1, Name: "Lays", Amount: 1},
+				{Action: ACTION_LOGOUT},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_run_success_add_product_with_spaces_in_name",
+			input:       "1234\nadd\n3\n Potato Chips \n20\n7\r\nrestock\n3\n2\nexit\n",
+			expectedProducts: []product.Product{
+				{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+				{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 10},
+				{ProductNo: 3, Name: "Potato Chips", Price: money.Baht(20), Stock: 9},
+			},
+			expectedMoney: commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_ADD_PRODUCT, ProductNo: 3, Name: "Potato Chips", Price: money.Baht(20), Amount: 7},
+				{Action: ACTION_RESTOCK_PRODUCT, ProductNo: 3, Amount: 2},
+				{Action: ACTION_LOGOUT},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_run_success_change_price_with_satang",
+			input:       "1234\nprice\n2\n12.50\nprice\n2\n12.505\nexit\n",
+			expectedProducts: []product.Product{
+				{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+				{ProductNo: 2, Name: "Hanami", Price: money.Satang(1250), Stock: 10},
+			},
+			expectedMoney: commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_CHANGE_PRICE, ProductNo: 2, Price: money.Satang(1250)},
+				{Action: ACTION_LOGOUT},
+			},
+			expectedOutput: []string{"invalid input, please try again"},
+			hasError:       false,
+		},
+		{
+			description:      "test_run_success_empty_cash_box_and_view_totals",
+			input:            "1234\nempty\ntotals\nexit\n",
+			expectedProducts: commonPrepData().Inventory.Products,
+			expectedMoney: []money.Money{
+				{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), NonRecyclable: true},
+				{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 2, Capacity: 10},
+				{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+			},
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_EMPTY_CASH_BOX, Value: money.Baht(1010), Pieces: map[string]int64{"1000": 1, "10": 1}},
+				{Action: ACTION_VIEW_TOTALS},
+				{Action: ACTION_LOGOUT},
+			},
+			expectedOutput: []string{
+				"taken from cash box:  1010 THB",
+				"products in stock:  11 pieces",
+				"value of products:  105 THB",
+				"money for change:   20 THB",
+				"money in cash box:  0 THB",
+			},
+			hasError: false,
+		},
+		{
+			description:      "test_run_success_failed_commands_are_recorded",
+			input:            "1234\nrestock\n9\n1\nrefill\n1000\n1\nrestock\nabc\nrefund\n",
+			expectedProducts: commonPrepData().Inventory.Products,
+			expectedMoney:    commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN},
+				{Action: ACTION_RESTOCK_PRODUCT, ProductNo: 9, Amount: 1, Error: "product doesn't exist"},
+				{Action: ACTION_REFILL_MONEY, Name: "1000", Amount: 1, Error: "1000 can't be used for change"},
+				{Action: ACTION_LOGOUT},
+			},
+			expectedOutput: []string{
+				"product doesn't exist, please try again",
+				"1000 can't be used for change, please try again",
+				"invalid input, please try again",
+				"command doesn't exist, please try again",
+			},
+			hasError: false,
+		},
+		{
+			description:      "test_run_failed_wrong_pin",
+			input:            "4321\nrestock\n1\n5\n",
+			expectedProducts: commonPrepData().Inventory.Products,
+			expectedMoney:    commonPrepData().Bank.Money,
+			expectedEntries: []AuditEntry{
+				{Action: ACTION_LOGIN_FAILED},
+			},
+			expectedError: errors.New("wrong PIN"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := commonPrepData()
+			auditLog := &memoryAuditLog{}
+			output := &bytes.Buffer{}
+
+			err := NewAdmin(machine, "1234", auditLog).Run(strings.NewReader(test.input), output)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+			assert.Equal(t, test.expectedProducts, machine.Inventory.Products)
+			assert.Equal(t, test.expectedMoney, machine.Bank.Money)
+
+			//time of entries is not predictable
+			for i, entry := range auditLog.entries {
+				assert.False(t, entry.Time.IsZero())
+				auditLog.entries[i].Time = time.Time{}
+			}
+			assert.Equal(t, test.expectedEntries, auditLog.entries)
+		})
+	}
+}
+
+func Test_Admin_Run_jam_and_clear_slot(t *testing.T) {
+	machine := commonPrepData()
+	assert.NoError(t, machine.Inventory.LoadSlots([]product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 1},
+		{Code: "B1", ProductNo: 2, Capacity: 10, Stock: 10},
+	}))
+	auditLog := &memoryAuditLog{}
+
+	err := NewAdmin(machine, "1234", auditLog).Run(strings.NewReader("1234\njam\nb1\njam\nC1\n"), &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.True(t, machine.Inventory.ListSlots()[1].Jammed)
+	assert.Equal(t, int64(0), machine.Inventory.List()[1].Stock)
+
+	err = NewAdmin(machine, "1234", auditLog).Run(strings.NewReader("1234\nclear\nB1\nexit\n"), &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.False(t, machine.Inventory.ListSlots()[1].Jammed)
+	assert.Equal(t, int64(10), machine.Inventory.List()[1].Stock)
+
+	for i := range auditLog.entries {
+		auditLog.entries[i].Time = time.Time{}
+	}
+	assert.Equal(t, []AuditEntry{
+		{Action: ACTION_LOGIN},
+		{Action: ACTION_JAM_SLOT, Name: "b1"},
+		{Action: ACTION_JAM_SLOT, Name: "C1", Error: "slot C1 doesn't exist"},
+		{Action: ACTION_LOGOUT},
+		{Action: ACTION_LOGIN},
+		{Action: ACTION_CLEAR_SLOT, Name: "B1"},
+		{Action: ACTION_LOGOUT},
+	}, auditLog.entries)
+}
+
+func Test_Admin_Run_assign_slot(t *testing.T) {
+	machine := commonPrepData()
+	assert.NoError(t, machine.Inventory.LoadSlots([]product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 1},
+		{Code: "B1", Capacity: 10},
+	}))
+	auditLog := &memoryAuditLog{}
+
+	//new product is assigned to the free slot, then it's restocked and can be sold
+	err := NewAdmin(machine, "1234", auditLog).Run(strings.NewReader("1234\nadd\n3\nPepsi\n15\n0\nassign\nb1\n3\nrestock\n3\n4\nassign\nA1\n3\nexit\n"), &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, product.Slot{Code: "B1", ProductNo: 3, Capacity: 10, Stock: 4}, machine.Inventory.ListSlots()[1])
+	assert.Equal(t, int64(4), machine.Inventory.List()[2].Stock)
+
+	for i := range auditLog.entries {
+		auditLog.entries[i].Time = time.Time{}
+	}
+	assert.Equal(t, []AuditEntry{
+		{Action: ACTION_LOGIN},
+		{Action: ACTION_ADD_PRODUCT, ProductNo: 3, Name: "Pepsi", Price: money.Baht(15)},
+		{Action: ACTION_ASSIGN_SLOT, Name: "b1", ProductNo: 3},
+		{Action: ACTION_RESTOCK_PRODUCT, ProductNo: 3, Amount: 4},
+		{Action: ACTION_ASSIGN_SLOT, Name: "A1", ProductNo: 3, Error: "slot A1 must be empty to be assigned to another product"},
+		{Action: ACTION_LOGOUT},
+	}, auditLog.entries)
+}
+
+func Test_Admin_Run_disabled_without_pin(t *testing.T) {
+	machine := commonPrepData()
+	auditLog := &memoryAuditLog{}
+
+	err := NewAdmin(machine, "", auditLog).Run(strings.NewReader("\nrestock\n1\n5\n"), &bytes.Buffer{})
+	assert.Equal(t, errors.New("admin mode is disabled"), err)
+	assert.Equal(t, commonPrepData().Inventory.Products, machine.Inventory.Products)
+	assert.Empty(t, auditLog.entries)
+}
+
+func Test_Admin_Run_failed_audit_log(t *testing.T) {
+	auditLog := &memoryAuditLog{err: errors.New("disk is full")}
+
+	err := NewAdmin(commonPrepData(), "1234", auditLog).Run(strings.NewReader("1234\nexit\n"), &bytes.Buffer{})
+	assert.Equal(t, errors.New("disk is full"), err)
+}
+
+//fixedClock - clock that never moves
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+//memoryCashAudits - CashAuditStore and SalesLedger that keep everything in memory
+type memoryCashAudits struct {
+	cashAudits []cashaudit.CashAudit
+	entries    []payment.LedgerEntry
+}
+
+func (s *memoryCashAudits) Append(cashAudit cashaudit.CashAudit) error {
+	s.cashAudits = append(s.cashAudits, cashAudit)
+	return nil
+}
+
+func (s *memoryCashAudits) CashAudits() ([]cashaudit.CashAudit, error) {
+	return s.cashAudits, nil
+}
+
+func (s *memoryCashAudits) Entries() ([]payment.LedgerEntry, error) {
+	return s.entries, nil
+}
+
+func Test_Admin_Run_cash_audit(t *testing.T) {
+	previous := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	store := &memoryCashAudits{
+		cashAudits: []cashaudit.CashAudit{{
+			Time: previous,
+			Counts: []cashaudit.Count{
+				{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), Counted: 1},
+				{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Counted: 4},
+				{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Counted: 0},
+			},
+		}},
+		entries: []payment.LedgerEntry{{
+			Time: previous.Add(time.Hour),
+			Receipt: payment.NewReceipt(
+				map[product.Product]int64{{ProductNo: 2, Name: "Hanami", Price: money.Baht(10)}: 1},
+				money.Baht(10),
+				map[money.Money]int64{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 1},
+				[]money.Money{},
+				true,
+			),
+		}},
+	}
+	//only successful refills and emptying after the previous cash audit are counted
+	adjustments := []AuditEntry{
+		{Time: previous.Add(-time.Hour), Action: ACTION_REFILL_MONEY, Name: "10", Amount: 5},
+		{Time: previous.Add(2 * time.Hour), Action: ACTION_EMPTY_CASH_BOX, Value: money.Baht(20), Pieces: map[string]int64{"10": 2}},
+		{Time: previous.Add(3 * time.Hour), Action: ACTION_REFILL_MONEY, Name: "5", Amount: 4, Error: "5 is full"},
+	}
+	auditLog := &memoryAuditLog{entries: adjustments}
+	machine := commonPrepData()
+
+	maintenance := NewAdmin(machine, "1234", auditLog)
+	maintenance.Ledger = store
+	maintenance.CashAudits = store
+	maintenance.Clock = fixedClock{now: now}
+
+	//1000 THB banknote in the cash box is missing
+	output := &bytes.Buffer{}
+	err := maintenance.Run(strings.NewReader("1234\naudit\n0\n3\n0\naudits\nexit\n"), output)
+	assert.NoError(t, err)
+
+	assert.Equal(t, cashaudit.CashAudit{
+		Time:  now,
+		Since: previous,
+		Counts: []cashaudit.Count{
+			{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), Previous: 1, InStock: 1, Expected: 1, Counted: 0, Discrepancy: -1},
+			{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Previous: 4, In: 1, Adjusted: -2, InStock: 3, Expected: 3, Counted: 3, Discrepancy: 0},
+			{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), InStock: 0, Expected: 0, Counted: 0, Discrepancy: 0},
+		},
+		DiscrepancyValue: money.Baht(-1000),
+	}, store.cashAudits[1])
+	assert.Equal(t, append(adjustments,
+		AuditEntry{Time: now, Action: ACTION_LOGIN},
+		AuditEntry{Time: now, Action: ACTION_CASH_AUDIT, Value: money.Baht(-1000)},
+		AuditEntry{Time: now, Action: ACTION_LOGOUT},
+	), auditLog.entries)
+	assert.Contains(t, output.String(), "total discrepancy:  -1000 THB")
+	assert.Contains(t, output.String(), "2021-01-02T12:00:00Z  discrepancy -1000 THB\n    bank 1000: -1 pieces\n")
+
+	//stock is not changed by cash audit
+	assert.Equal(t, commonPrepData().Bank.Money, machine.Bank.Money)
+}
@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"encoding/json"
+	"time"
+	"vending-machine/jsonl"
+	"vending-machine/money"
+)
+
+const (
+	ACTION_LOGIN           = "login"
+	ACTION_LOGIN_FAILED    = "login-failed"
+	ACTION_LOGOUT          = "logout"
+	ACTION_RESTOCK_PRODUCT = "restock-product"
+	ACTION_ADD_PRODUCT     = "add-product"
+	ACTION_CHANGE_PRICE    = "change-price"
+	ACTION_REMOVE_PRODUCT  = "remove-product"
+	ACTION_REFILL_MONEY    = "refill-money"
+	ACTION_EMPTY_CASH_BOX  = "empty-cash-box"
+	ACTION_JAM_SLOT        = "jam-slot"
+	ACTION_CLEAR_SLOT      = "clear-slot"
+	ACTION_ASSIGN_SLOT     = "assign-slot"
+	ACTION_VIEW_TOTALS     = "view-totals"
+	ACTION_CASH_AUDIT      = "cash-audit"
+)
+
+//AuditEntry - admin action, Error is why it failed or empty when it succeeded
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	//ProductNo, Name - product, money or slot that the action is done to
+	ProductNo int64  `json:"productNo,omitempty"`
+	Name      string `json:"name,omitempty"`
+
+	Price  money.Amount `json:"price,omitempty"`
+	Amount int64        `json:"amount,omitempty"`
+
+	//Value - value of money that the action is done to, like money taken from cash box
+	Value money.Amount `json:"value,omitempty"`
+
+	//Pieces - pieces by money's name that the action is done to, like money taken from cash box
+	Pieces map[string]int64 `json:"pieces,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+//AuditLog - where admin actions are recorded
+type AuditLog interface {
+	Record(entry AuditEntry) error
+
+	//Entries - every recorded entry, oldest first
+	Entries() ([]AuditEntry, error)
+}
+
+//FileAuditLog - audit log kept as JSON lines in a file,
+//every entry is flushed to disk before Record returns
+type FileAuditLog struct {
+	file *jsonl.File
+}
+
+//OpenAuditLog - open audit log file at path, create it if it doesn't exist
+func OpenAuditLog(path string) (*FileAuditLog, error) {
+	file, err := jsonl.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditLog{file: file}, nil
+}
+
+//Close - close audit log file
+func (l *FileAuditLog) Close() error {
+	return l.file.Close()
+}
+
+//Record - append entry and flush it to disk
+func (l *FileAuditLog) Record(entry AuditEntry) error {
+	return l.file.Append(entry)
+}
+
+//Entries - read every entry of audit log file, oldest first
+func (l *FileAuditLog) Entries() ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := l.file.Read(func(line []byte) error {
+		var entry AuditEntry
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
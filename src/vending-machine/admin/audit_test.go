@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileAuditLog_Entries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	auditLog, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, auditLog.Record(AuditEntry{Time: at, Action: ACTION_LOGIN}))
+	assert.NoError(t, auditLog.Record(AuditEntry{Time: at, Action: ACTION_RESTOCK_PRODUCT, ProductNo: 1, Amount: 5}))
+	assert.NoError(t, auditLog.Close())
+
+	//reopened log continues after existing entries
+	auditLog, err = OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditLog.Close()
+	assert.NoError(t, auditLog.Record(AuditEntry{Time: at, Action: ACTION_LOGOUT}))
+
+	entries, err := auditLog.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, []AuditEntry{
+		{Time: at, Action: ACTION_LOGIN},
+		{Time: at, Action: ACTION_RESTOCK_PRODUCT, ProductNo: 1, Amount: 5},
+		{Time: at, Action: ACTION_LOGOUT},
+	}, entries)
+}
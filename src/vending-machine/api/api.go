@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+)
+
+//DEFAULT_SELECTION_TIMEOUT, DEFAULT_PAYMENT_TIMEOUT - timeouts of sessions when none are configured,
+//a client that abandons a session would otherwise hold its products and money forever
+const (
+	DEFAULT_SELECTION_TIMEOUT = 5 * time.Minute
+	DEFAULT_PAYMENT_TIMEOUT   = 5 * time.Minute
+)
+
+//Server - HTTP REST API of a vending machine
+type Server struct {
+	machine  *payment.Machine
+	mu       sync.Mutex
+	sessions map[string]*payment.Session
+}
+
+//ItemRequest - body of POST /sessions/{id}/items
+type ItemRequest struct {
+	ProductNo int64 `json:"productNo"`
+
+	//Slot - slot code of the machine's planogram, it's selected instead of product no. if it's given
+	Slot string `json:"slot,omitempty"`
+}
+
+//CoinRequest - body of POST /sessions/{id}/coins
+type CoinRequest struct {
+	Name string `json:"name"`
+}
+
+//SessionResponse - purchase in progress
+type SessionResponse struct {
+	ID          string                   `json:"id"`
+	State       string                   `json:"state"`
+	Products    []payment.ReceiptProduct `json:"products"`
+	TotalAmount money.Amount             `json:"totalAmount"`
+	Paid        []payment.ReceiptMoney   `json:"paid"`
+	PaidAmount  money.Amount             `json:"paidAmount"`
+
+	//ExactChangeOnly - the machine's money's stock is too low to make change for its price range
+	ExactChangeOnly bool `json:"exactChangeOnly"`
+
+	//RejectedMoney - money that can't be inserted now because its change can't be made
+	RejectedMoney []string `json:"rejectedMoney"`
+}
+
+//ErrorResponse - body of every error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+//NewServer - create HTTP REST API of machine
+func NewServer(machine *payment.Machine) *Server {
+	return &Server{
+		machine:  machine,
+		sessions: make(map[string]*payment.Session),
+	}
+}
+
+//ServeHTTP - route request
+//
+//	GET  /products
+//	GET  /money
+//	POST /sessions
+//	POST /sessions/{id}/items
+//	POST /sessions/{id}/coins
+//	POST /sessions/{id}/checkout
+//	POST /sessions/{id}/cancel
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "products":
+		s.route(w, r, http.MethodGet, s.listProducts)
+	case len(parts) == 1 && parts[0] == "money":
+		s.route(w, r, http.MethodGet, s.listMoney)
+	case len(parts) == 1 && parts[0] == "sessions":
+		s.route(w, r, http.MethodPost, s.createSession)
+	case len(parts) == 3 && parts[0] == "sessions":
+		handlers := map[string]func(http.ResponseWriter, *http.Request, string, *payment.Session){
+			"items":    s.addItem,
+			"coins":    s.insertCoin,
+			"checkout": s.checkout,
+			"cancel":   s.cancel,
+		}
+		handler, ok := handlers[parts[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, errors.New("not found"))
+			return
+		}
+		s.route(w, r, http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+			sess, ok := s.sessions[parts[1]]
+			if !ok {
+				writeError(w, http.StatusNotFound, errors.New("session doesn't exist"))
+				return
+			}
+			handler(w, r, parts[1], sess)
+		})
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+//route - call handler if request's method is method, one request at a time because the machine is shared
+func (s *Server) route(w http.ResponseWriter, r *http.Request, method string, handler http.HandlerFunc) {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireSessions()
+	handler(w, r)
+}
+
+//expireSessions - cancel and forget sessions that are inactive longer than the machine's timeouts
+func (s *Server) expireSessions() {
+	for id, sess := range s.sessions {
+		if sess.Expire() {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *Server) listProducts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.machine.Inventory.List())
+}
+
+func (s *Server) listMoney(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.machine.Bank.List())
+}
+
+func (s *Server) createSession(w http.ResponseWriter, r *http.Request) {
+	sess := s.machine.NewSession()
+	err := sess.Start()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.sessions[sess.ID()] = sess
+	writeJSON(w, http.StatusCreated, newSessionResponse(sess.ID(), sess))
+}
+
+func (s *Server) addItem(w http.ResponseWriter, r *http.Request, id string, sess *payment.Session) {
+	var body ItemRequest
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	selection := strconv.FormatInt(body.ProductNo, 10)
+	if body.Slot != "" {
+		selection = body.Slot
+	}
+	err := sess.Select(selection)
+	if err != nil {
+		writeSessionError(w, sess, payment.STATE_SELECTING, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessionResponse(id, sess))
+}
+
+func (s *Server) insertCoin(w http.ResponseWriter, r *http.Request, id string, sess *payment.Session) {
+	var body CoinRequest
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	//first money inserted finishes selection
+	if sess.State() == payment.STATE_SELECTING {
+		err := sess.FinishSelection()
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+	}
+
+	err := sess.Insert(body.Name)
+	if err != nil {
+		writeSessionError(w, sess, payment.STATE_AWAITING_PAYMENT, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newSessionResponse(id, sess))
+}
+
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request, id string, sess *payment.Session) {
+	if sess.State() == payment.STATE_SELECTING {
+		err := sess.FinishSelection()
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+	}
+
+	err := sess.Checkout()
+	if err != nil {
+		//products can't be dispensed or purchase can't be recorded, session is over
+		if sess.State() == payment.STATE_CANCELLED || sess.State() == payment.STATE_DONE {
+			delete(s.sessions, id)
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		//user can insert more money or cancel
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	delete(s.sessions, id)
+	writeJSON(w, http.StatusOK, sess.Receipt())
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request, id string, sess *payment.Session) {
+	err := sess.Cancel()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	delete(s.sessions, id)
+	writeJSON(w, http.StatusOK, sess.Receipt())
+}
+
+//newSessionResponse - purchase in progress of sess
+func newSessionResponse(id string, sess *payment.Session) SessionResponse {
+	receipt := payment.NewReceipt(sess.BuyedProducts(), sess.TotalAmount(), sess.ReceivedMoney(), []money.Money{}, true)
+
+	rejectedMoney := []string{}
+	for _, rejected := range sess.RejectedMoney() {
+		rejectedMoney = append(rejectedMoney, rejected.Name)
+	}
+
+	return SessionResponse{
+		ID:              id,
+		State:           sess.State(),
+		Products:        receipt.Products,
+		TotalAmount:     receipt.TotalAmount,
+		Paid:            receipt.Paid,
+		PaidAmount:      sess.PaidAmount(),
+		ExactChangeOnly: sess.ExactChangeOnly(),
+		RejectedMoney:   rejectedMoney,
+	}
+}
+
+//readJSON - decode request's body to v, write bad request response if it's invalid
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errors.New("invalid request body: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+//writeSessionError - unprocessable entity if event was rejected by validation in state, otherwise conflict with the session's state
+func writeSessionError(w http.ResponseWriter, sess *payment.Session, state string, err error) {
+	if sess.State() != state {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeError(w, http.StatusUnprocessableEntity, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
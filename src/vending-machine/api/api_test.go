@@ -0,0 +1,356 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData() *payment.Machine {
+	return payment.NewMachine([]product.Product{
+		{
+			ProductNo: 1,
+			Name:      "Lays",
+			Price:     money.Baht(15),
+			Stock:     1,
+		},
+		{
+			ProductNo: 2,
+			Name:      "Coke",
+			Price:     money.Baht(12),
+			Stock:     5,
+		},
+	}, []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     0,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "5",
+			Value:     money.Baht(5),
+			Stock:     0,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "1",
+			Value:     money.Baht(1),
+			Stock:     2,
+		},
+	})
+}
+
+//request - send request to server, then decode response's body to v
+func request(t *testing.T, server http.Handler, method string, path string, body string, v interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	if v != nil {
+		err := json.Unmarshal(rec.Body.Bytes(), v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code
+}
+
+//newSession - start a purchase, then return its path
+func newSession(t *testing.T, server http.Handler) string {
+	var sess SessionResponse
+	status := request(t, server, http.MethodPost, "/sessions", "", &sess)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.NotEmpty(t, sess.ID)
+	return "/sessions/" + sess.ID
+}
+
+func Test_Server_list(t *testing.T) {
+	server := NewServer(commonPrepData())
+
+	var products []product.Product
+	status := request(t, server, http.MethodGet, "/products", "", &products)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, commonPrepData().Inventory.Products, products)
+
+	var moneyList []money.Money
+	status = request(t, server, http.MethodGet, "/money", "", &moneyList)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, commonPrepData().Bank.Money, moneyList)
+}
+
+func Test_Server_checkout(t *testing.T) {
+	tests := []struct {
+		description     string
+		items           []string
+		coins           []string
+		expectedStatus  int
+		expectedReceipt payment.Receipt
+		expectedError   ErrorResponse
+		expectedState   payment.State
+	}{
+		{
+			description:    "test_checkout_success",
+			items:          []string{`{"productNo": 2}`, `{"productNo": 2}`},
+			coins:          []string{`{"name": "10"}`, `{"name": "10"}`, `{"name": "5"}`},
+			expectedStatus: http.StatusOK,
+			expectedReceipt: payment.Receipt{
+				Products:     []payment.ReceiptProduct{{ProductNo: 2, Name: "Coke", Price: money.Baht(12), Quantity: 2}},
+				TotalAmount:  money.Baht(24),
+				IsSuccessful: true,
+				Status:       payment.STATUS_SUCCESSFUL,
+				Paid:         []payment.ReceiptMoney{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Quantity: 2}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+				Change:       []payment.ReceiptMoney{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Quantity: 1}},
+				Returned:     []payment.ReceiptMoney{},
+			},
+			expectedState: payment.State{
+				Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(15), Stock: 1}, {ProductNo: 2, Name: "Coke", Price: money.Baht(12), Stock: 3}},
+				Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 2}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1}, {MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 1}},
+			},
+		},
+		{
+			description:    "test_checkout_failed_payment_is_not_enough",
+			items:          []string{`{"productNo": 1}`},
+			coins:          []string{`{"name": "10"}`},
+			expectedStatus: http.StatusConflict,
+			expectedError:  ErrorResponse{Error: "payment is not enough"},
+			expectedState:  commonPrepData().State(),
+		},
+		{
+			description:    "test_checkout_failed_no_product_is_selected",
+			expectedStatus: http.StatusConflict,
+			expectedError:  ErrorResponse{Error: "you have not select any product"},
+			expectedState:  commonPrepData().State(),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := commonPrepData()
+			server := NewServer(machine)
+			path := newSession(t, server)
+
+			for _, item := range test.items {
+				status := request(t, server, http.MethodPost, path+"/items", item, nil)
+				assert.Equal(t, http.StatusOK, status)
+			}
+			for _, coin := range test.coins {
+				status := request(t, server, http.MethodPost, path+"/coins", coin, nil)
+				assert.Equal(t, http.StatusOK, status)
+			}
+
+			if test.expectedStatus == http.StatusOK {
+				var receipt payment.Receipt
+				status := request(t, server, http.MethodPost, path+"/checkout", "", &receipt)
+				assert.Equal(t, test.expectedStatus, status)
+				assert.Equal(t, test.expectedReceipt, receipt)
+
+				//session is finished
+				status = request(t, server, http.MethodPost, path+"/checkout", "", nil)
+				assert.Equal(t, http.StatusNotFound, status)
+			} else {
+				var errResponse ErrorResponse
+				status := request(t, server, http.MethodPost, path+"/checkout", "", &errResponse)
+				assert.Equal(t, test.expectedStatus, status)
+				assert.Equal(t, test.expectedError, errResponse)
+			}
+			assert.Equal(t, test.expectedState, machine.State())
+		})
+	}
+}
+
+func Test_Server_cancel(t *testing.T) {
+	machine := commonPrepData()
+	server := NewServer(machine)
+	path := newSession(t, server)
+
+	request(t, server, http.MethodPost, path+"/items", `{"productNo": 1}`, nil)
+	request(t, server, http.MethodPost, path+"/coins", `{"name": "10"}`, nil)
+
+	var receipt payment.Receipt
+	status := request(t, server, http.MethodPost, path+"/cancel", "", &receipt)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, payment.Receipt{
+		Products:     []payment.ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(15), Quantity: 1}},
+		TotalAmount:  money.Baht(15),
+		IsSuccessful: false,
+		Status:       payment.STATUS_CANCELLED,
+		Paid:         []payment.ReceiptMoney{},
+		Change:       []payment.ReceiptMoney{},
+		Returned:     []payment.ReceiptMoney{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Quantity: 1}},
+	}, receipt)
+	assert.Equal(t, commonPrepData().State(), machine.State())
+
+	status = request(t, server, http.MethodPost, path+"/cancel", "", nil)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func Test_Server_exact_change_only(t *testing.T) {
+	server := NewServer(commonPrepData())
+	path := newSession(t, server)
+
+	var sess SessionResponse
+	//two 1 THB coins can't change 5 THB of 15 THB paid with 10, so the indicator is on before paying
+	request(t, server, http.MethodPost, path+"/items", `{"productNo": 1}`, &sess)
+	assert.True(t, sess.ExactChangeOnly)
+	assert.Equal(t, []string{}, sess.RejectedMoney)
+
+	//5 THB left can't be changed from 10 because there are only two 1 THB coins
+	status := request(t, server, http.MethodPost, path+"/coins", `{"name": "10"}`, &sess)
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, sess.ExactChangeOnly)
+	assert.Equal(t, []string{"10"}, sess.RejectedMoney)
+
+	status = request(t, server, http.MethodPost, path+"/coins", `{"name": "5"}`, &sess)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, money.Baht(15), sess.PaidAmount)
+}
+
+func Test_Server_errors(t *testing.T) {
+	tests := []struct {
+		description    string
+		method         string
+		path           string
+		body           string
+		prepData       func(server http.Handler, path string)
+		expectedStatus int
+		expectedError  ErrorResponse
+	}{
+		{
+			description:    "test_item_failed_product_doesn't_exist",
+			method:         http.MethodPost,
+			path:           "/items",
+			body:           `{"productNo": 9}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrorResponse{Error: "product doesn't exist"},
+		},
+		{
+			description:    "test_item_failed_out_of_stock",
+			method:         http.MethodPost,
+			path:           "/items",
+			body:           `{"productNo": 1}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrorResponse{Error: "Lays is out of stock"},
+		},
+		{
+			description:    "test_coin_failed_money_doesn't_excepted",
+			method:         http.MethodPost,
+			path:           "/coins",
+			body:           `{"name": "2"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrorResponse{Error: "money doesn't excepted"},
+		},
+		{
+			description: "test_coin_failed_exact_change_only",
+			method:      http.MethodPost,
+			path:        "/coins",
+			body:        `{"name": "10"}`,
+			prepData: func(server http.Handler, path string) {
+				request(t, server, http.MethodPost, path+"/coins", `{"name": "10"}`, nil)
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  ErrorResponse{Error: "exact change only, 10 is rejected because its change can't be made"},
+		},
+		{
+			description:    "test_coin_failed_invalid_body",
+			method:         http.MethodPost,
+			path:           "/coins",
+			body:           `{"coin": "10"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrorResponse{Error: "invalid request body: json: unknown field \"coin\""},
+		},
+		{
+			description: "test_item_failed_after_money_is_inserted",
+			method:      http.MethodPost,
+			path:        "/items",
+			body:        `{"productNo": 2}`,
+			prepData: func(server http.Handler, path string) {
+				request(t, server, http.MethodPost, path+"/coins", `{"name": "10"}`, nil)
+			},
+			expectedStatus: http.StatusConflict,
+			expectedError:  ErrorResponse{Error: "select is not allowed while session is awaiting-payment"},
+		},
+		{
+			description:    "test_checkout_failed_method_not_allowed",
+			method:         http.MethodGet,
+			path:           "/checkout",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedError:  ErrorResponse{Error: "method not allowed"},
+		},
+		{
+			description:    "test_failed_not_found",
+			method:         http.MethodPost,
+			path:           "/refund",
+			expectedStatus: http.StatusNotFound,
+			expectedError:  ErrorResponse{Error: "not found"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			server := NewServer(commonPrepData())
+			path := newSession(t, server)
+
+			//Lays has only one in stock
+			request(t, server, http.MethodPost, path+"/items", `{"productNo": 1}`, nil)
+			if test.prepData != nil {
+				test.prepData(server, path)
+			}
+
+			var errResponse ErrorResponse
+			status := request(t, server, test.method, path+test.path, test.body, &errResponse)
+			assert.Equal(t, test.expectedStatus, status)
+			assert.Equal(t, test.expectedError, errResponse)
+		})
+	}
+
+	var errResponse ErrorResponse
+	status := request(t, NewServer(commonPrepData()), http.MethodPost, "/sessions/unknown/checkout", "", &errResponse)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, ErrorResponse{Error: "session doesn't exist"}, errResponse)
+}
+
+//fakeClock - clock that moves only when a test sets it
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func Test_Server_expires_inactive_sessions(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	machine := commonPrepData()
+	machine.Clock = clock
+	machine.SelectionTimeout = 30 * time.Second
+	server := NewServer(machine)
+
+	active := newSession(t, server)
+	inactive := newSession(t, server)
+
+	clock.now = clock.now.Add(20 * time.Second)
+	status := request(t, server, http.MethodPost, active+"/items", `{"productNo": 2}`, nil)
+	assert.Equal(t, http.StatusOK, status)
+
+	clock.now = clock.now.Add(20 * time.Second)
+	var errResponse ErrorResponse
+	status = request(t, server, http.MethodPost, inactive+"/items", `{"productNo": 2}`, &errResponse)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, ErrorResponse{Error: "session doesn't exist"}, errResponse)
+
+	status = request(t, server, http.MethodPost, active+"/items", `{"productNo": 2}`, nil)
+	assert.Equal(t, http.StatusOK, status)
+}
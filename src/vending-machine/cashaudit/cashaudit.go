@@ -0,0 +1,178 @@
+package cashaudit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+)
+
+//CashAudit - money counted by operator compared with what the machine believes it has
+type CashAudit struct {
+	Time time.Time `json:"time"`
+
+	//Since - time of the previous cash audit, zero for the first one
+	Since time.Time `json:"since"`
+
+	Counts []Count `json:"counts"`
+
+	//DiscrepancyValue - total value of every discrepancy, negative when money is missing
+	DiscrepancyValue money.Amount `json:"discrepancyValue"`
+}
+
+//Count - pieces of a money counted by operator
+type Count struct {
+	MoneyType string       `json:"type"`
+	Name      string       `json:"name"`
+	Value     money.Amount `json:"value"`
+
+	//Previous - pieces counted by the previous cash audit
+	Previous int64 `json:"previous"`
+
+	//In, Out - pieces paid in and changed out by purchases in the ledger since the previous cash audit
+	In  int64 `json:"in"`
+	Out int64 `json:"out"`
+
+	//Adjusted - pieces refilled minus pieces taken from cash box by operator since the previous cash audit
+	Adjusted int64 `json:"adjusted"`
+
+	//InStock - pieces in stock plus cash box of money's stock
+	InStock int64 `json:"inStock"`
+
+	//Expected - previous count plus in, out and adjusted pieces,
+	//pieces in stock when there is no previous count of the money
+	Expected int64 `json:"expected"`
+	Counted  int64 `json:"counted"`
+
+	//Discrepancy - counted minus expected, negative when pieces are missing
+	Discrepancy int64 `json:"discrepancy"`
+
+	//StockDiscrepancy - pieces in stock minus expected, money's stock doesn't match the ledger when it isn't zero
+	StockDiscrepancy int64 `json:"stockDiscrepancy"`
+}
+
+//Adjustment - pieces of a money added by operator like a refill, negative when they are taken like emptying cash box
+type Adjustment struct {
+	Time   time.Time
+	Name   string
+	Pieces int64
+}
+
+//NewCashAudit - compare counted pieces by money's name with moneyList at time at,
+//expected pieces are counted from the previous cash audit (zero for the first one) with purchases of entries
+//and adjustments after it
+func NewCashAudit(moneyList []money.Money, counted map[string]int64, entries []payment.LedgerEntry, adjustments []Adjustment, previous CashAudit, at time.Time) CashAudit {
+	since := previous.Time
+	cashAudit := CashAudit{
+		Time:   at,
+		Since:  since,
+		Counts: []Count{},
+	}
+
+	in := make(map[string]int64)
+	out := make(map[string]int64)
+	for _, entry := range entries {
+		if !entry.Time.After(since) || entry.Receipt.Status != payment.STATUS_SUCCESSFUL {
+			continue
+		}
+		for _, paid := range entry.Receipt.Paid {
+			in[paid.Name] = in[paid.Name] + paid.Quantity
+		}
+		for _, change := range entry.Receipt.Change {
+			out[change.Name] = out[change.Name] + change.Quantity
+		}
+	}
+
+	adjusted := make(map[string]int64)
+	for _, adjustment := range adjustments {
+		if adjustment.Time.After(since) {
+			adjusted[adjustment.Name] = adjusted[adjustment.Name] + adjustment.Pieces
+		}
+	}
+
+	previousCounted := make(map[string]int64)
+	for _, count := range previous.Counts {
+		previousCounted[count.Name] = count.Counted
+	}
+
+	for _, mon := range moneyList {
+		count := Count{
+			MoneyType: mon.MoneyType,
+			Name:      mon.Name,
+			Value:     mon.Value,
+			In:        in[mon.Name],
+			Out:       out[mon.Name],
+			Adjusted:  adjusted[mon.Name],
+			InStock:   mon.Stock + mon.CashBox,
+			Counted:   counted[mon.Name],
+		}
+
+		//without a previous count there is nothing to count movements from, so money's stock is expected
+		count.Expected = count.InStock
+		if previousCount, ok := previousCounted[mon.Name]; ok {
+			count.Previous = previousCount
+			count.Expected = previousCount + count.In - count.Out + count.Adjusted
+		}
+
+		count.Discrepancy = count.Counted - count.Expected
+		count.StockDiscrepancy = count.InStock - count.Expected
+		cashAudit.Counts = append(cashAudit.Counts, count)
+		cashAudit.DiscrepancyValue = cashAudit.DiscrepancyValue + count.Value.Times(count.Discrepancy)
+	}
+	return cashAudit
+}
+
+//PrintCashAudit - print discrepancies of cash audit
+func PrintCashAudit(output io.Writer, cashAudit CashAudit) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	fmt.Fprintln(output, "------------ Cash audit ------------")
+	if cashAudit.Since.IsZero() {
+		fmt.Fprintln(output, "first cash audit")
+	} else {
+		fmt.Fprintln(output, "since", cashAudit.Since.Format(time.RFC3339))
+	}
+	fmt.Fprintln(output, "Name        Previous    In          Out         Adjusted    Expected    In stock    Counted     Discrepancy")
+	fmt.Fprintln(output, "--------------------------------------------------------------------------------------------------------")
+	for _, count := range cashAudit.Counts {
+		fmt.Fprintf(output, "%-12v%-12v%-12v%-12v%-12v%-12v%-12v%-12v%-12v\n", count.Name, count.Previous, count.In, count.Out, count.Adjusted,
+			count.Expected, count.InStock, count.Counted, count.Discrepancy)
+	}
+	for _, count := range cashAudit.Counts {
+		if count.StockDiscrepancy != 0 {
+			fmt.Fprintf(output, "%v %v: money's stock has %+d pieces that the ledger doesn't explain\n", count.MoneyType, count.Name, count.StockDiscrepancy)
+		}
+	}
+	fmt.Fprintln(output, "total discrepancy: ", cashAudit.DiscrepancyValue, "THB")
+	fmt.Fprintln(output, "-----------------------------------")
+}
+
+//PrintHistory - print time and total discrepancy of every cash audit, oldest first
+func PrintHistory(output io.Writer, cashAudits []CashAudit) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	fmt.Fprintln(output, "------------ Cash audits ------------")
+	if len(cashAudits) == 0 {
+		fmt.Fprintln(output, "no cash audit")
+	}
+	for _, cashAudit := range cashAudits {
+		fmt.Fprintf(output, "%v  discrepancy %v THB\n", cashAudit.Time.Format(time.RFC3339), cashAudit.DiscrepancyValue)
+		for _, count := range cashAudit.Counts {
+			if count.Discrepancy != 0 {
+				fmt.Fprintf(output, "    %v %v: %+d pieces\n", count.MoneyType, count.Name, count.Discrepancy)
+			}
+			if count.StockDiscrepancy != 0 {
+				fmt.Fprintf(output, "    %v %v: %+d pieces in stock\n", count.MoneyType, count.Name, count.StockDiscrepancy)
+			}
+		}
+	}
+	fmt.Fprintln(output, "-----------------------------------")
+}
@@ -0,0 +1,131 @@
+package cashaudit
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData() []money.Money {
+	return []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     5,
+			CashBox:   2,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "5",
+			Value:     money.Baht(5),
+			Stock:     3,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "1",
+			Value:     money.Baht(1),
+			Stock:     4,
+		},
+	}
+}
+
+//purchase - ledger entry of a purchase of Lays for 5 THB paid by a 10 THB coin
+func purchase(at time.Time, isSuccessful bool) payment.LedgerEntry {
+	return payment.LedgerEntry{
+		Time: at,
+		Receipt: payment.NewReceipt(
+			map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: 1},
+			money.Baht(5),
+			map[money.Money]int64{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 1},
+			[]money.Money{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5)}},
+			isSuccessful,
+		),
+	}
+}
+
+//previousCashAudit - cash audit at time at that counted pieces by money's name
+func previousCashAudit(at time.Time, counted map[string]int64) CashAudit {
+	return NewCashAudit(commonPrepData(), counted, nil, nil, CashAudit{}, at)
+}
+
+func Test_NewCashAudit(t *testing.T) {
+	since := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	entries := []payment.LedgerEntry{
+		purchase(since.Add(-time.Hour), true),
+		purchase(since.Add(time.Hour), true),
+		purchase(since.Add(2*time.Hour), true),
+		purchase(since.Add(3*time.Hour), false),
+	}
+	adjustments := []Adjustment{
+		{Time: since.Add(-time.Hour), Name: "5", Pieces: 10},
+		{Time: since.Add(4 * time.Hour), Name: "10", Pieces: -2},
+		{Time: since.Add(5 * time.Hour), Name: "1", Pieces: 2},
+	}
+	previous := previousCashAudit(since, map[string]int64{"10": 7, "5": 5, "1": 4})
+
+	cashAudit := NewCashAudit(commonPrepData(), map[string]int64{"10": 7, "5": 2, "1": 6}, entries, adjustments, previous, at)
+	assert.Equal(t, CashAudit{
+		Time:  at,
+		Since: since,
+		Counts: []Count{
+			{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Previous: 7, In: 2, Out: 0, Adjusted: -2, InStock: 7, Expected: 7, Counted: 7, Discrepancy: 0, StockDiscrepancy: 0},
+			{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Previous: 5, In: 0, Out: 2, Adjusted: 0, InStock: 3, Expected: 3, Counted: 2, Discrepancy: -1, StockDiscrepancy: 0},
+			{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Previous: 4, In: 0, Out: 0, Adjusted: 2, InStock: 4, Expected: 6, Counted: 6, Discrepancy: 0, StockDiscrepancy: -2},
+		},
+		DiscrepancyValue: money.Baht(-5),
+	}, cashAudit)
+
+	//money that the previous cash audit didn't count is expected as it is in stock
+	previous = previousCashAudit(since, map[string]int64{"10": 7, "5": 5, "1": 4})
+	previous.Counts = previous.Counts[:2]
+	cashAudit = NewCashAudit(commonPrepData(), map[string]int64{"10": 7, "5": 2, "1": 6}, entries, adjustments, previous, at)
+	assert.Equal(t, int64(0), cashAudit.Counts[2].Previous)
+	assert.Equal(t, int64(4), cashAudit.Counts[2].Expected)
+	assert.Equal(t, int64(2), cashAudit.Counts[2].Discrepancy)
+	assert.Equal(t, int64(0), cashAudit.Counts[2].StockDiscrepancy)
+
+	//first cash audit counts every movement, what is in stock is expected
+	cashAudit = NewCashAudit(commonPrepData(), map[string]int64{}, entries, adjustments, CashAudit{}, at)
+	assert.Equal(t, int64(3), cashAudit.Counts[0].In)
+	assert.Equal(t, int64(-7), cashAudit.Counts[0].Discrepancy)
+	assert.Equal(t, money.Baht(-89), cashAudit.DiscrepancyValue)
+}
+
+func Test_PrintCashAudit(t *testing.T) {
+	since := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	previous := previousCashAudit(since, map[string]int64{"10": 7, "5": 3, "1": 6})
+	output := &bytes.Buffer{}
+	PrintCashAudit(output, NewCashAudit(commonPrepData(), map[string]int64{"10": 7, "5": 3, "1": 6}, nil, nil, previous, at))
+
+	assert.Contains(t, output.String(), "1           6           0           0           0           6           4           6           0           \n")
+	assert.Contains(t, output.String(), "coin 1: money's stock has -2 pieces that the ledger doesn't explain\n")
+	assert.NotContains(t, output.String(), "coin 10: money's stock")
+}
+
+func Test_PrintHistory(t *testing.T) {
+	since := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	output := &bytes.Buffer{}
+	first := previousCashAudit(since, map[string]int64{"10": 7, "5": 2, "1": 6})
+	PrintHistory(output, []CashAudit{
+		first,
+		NewCashAudit(commonPrepData(), map[string]int64{"10": 7, "5": 2, "1": 6}, nil, nil, first, at),
+	})
+
+	assert.Equal(t, "------------ Cash audits ------------\n"+
+		"2021-01-01T12:00:00Z  discrepancy -3 THB\n"+
+		"    coin 5: -1 pieces\n"+
+		"    coin 1: +2 pieces\n"+
+		"2021-01-02T12:00:00Z  discrepancy 0 THB\n"+
+		"    coin 5: +1 pieces in stock\n"+
+		"    coin 1: -2 pieces in stock\n"+
+		"-----------------------------------\n", output.String())
+}
@@ -0,0 +1,49 @@
+package cashaudit
+
+import (
+	"encoding/json"
+	"vending-machine/jsonl"
+)
+
+//FileStore - cash audits kept as JSON lines in a file,
+//every cash audit is flushed to disk before Append returns
+type FileStore struct {
+	file *jsonl.File
+}
+
+//Open - open cash audit file at path, create it if it doesn't exist
+func Open(path string) (*FileStore, error) {
+	file, err := jsonl.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{file: file}, nil
+}
+
+//Close - close cash audit file
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+//Append - append cashAudit and flush it to disk
+func (s *FileStore) Append(cashAudit CashAudit) error {
+	return s.file.Append(cashAudit)
+}
+
+//CashAudits - read every cash audit of file, oldest first
+func (s *FileStore) CashAudits() ([]CashAudit, error) {
+	cashAudits := []CashAudit{}
+	err := s.file.Read(func(line []byte) error {
+		var cashAudit CashAudit
+		err := json.Unmarshal(line, &cashAudit)
+		if err != nil {
+			return err
+		}
+		cashAudits = append(cashAudits, cashAudit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cashAudits, nil
+}
@@ -0,0 +1,34 @@
+package cashaudit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_FileStore_CashAudits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cash-audits.log")
+	first := NewCashAudit(commonPrepData(), map[string]int64{"10": 7, "5": 3, "1": 4}, nil, nil, CashAudit{}, time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+	second := NewCashAudit(commonPrepData(), map[string]int64{"10": 6, "5": 3, "1": 4}, nil, nil, first, time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC))
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, store.Append(first))
+	assert.NoError(t, store.Close())
+
+	//reopened store continues after existing cash audits
+	store, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	assert.NoError(t, store.Append(second))
+
+	cashAudits, err := store.CashAudits()
+	assert.NoError(t, err)
+	assert.Equal(t, []CashAudit{first, second}, cashAudits)
+}
@@ -0,0 +1,405 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"gopkg.in/yaml.v3"
+)
+
+//Config - machine's configuration of change strategy, product's stock and money's stock
+type Config struct {
+	ChangeStrategy string `json:"changeStrategy" yaml:"changeStrategy"`
+
+	//SelectionTimeout, PaymentTimeout - durations like "30s" or "1m", empty for waiting forever
+	SelectionTimeout string `json:"selectionTimeout,omitempty" yaml:"selectionTimeout,omitempty"`
+	PaymentTimeout   string `json:"paymentTimeout,omitempty" yaml:"paymentTimeout,omitempty"`
+
+	//ReservationTTL - duration like "5m" that selected products are held for a purchase without checkout,
+	//empty for holding them until the purchase ends
+	ReservationTTL string `json:"reservationTtl,omitempty" yaml:"reservationTtl,omitempty"`
+
+	//AdminPIN - PIN of admin mode, at least 4 digits, empty for disabling admin mode
+	AdminPIN string `json:"adminPin,omitempty" yaml:"adminPin,omitempty"`
+
+	Products []ProductConfig `json:"products" yaml:"products"`
+	Money    []MoneyConfig   `json:"money" yaml:"money"`
+
+	//Slots - planogram that products are vended from, empty for selling product's stock without slots
+	Slots []SlotConfig `json:"slots,omitempty" yaml:"slots,omitempty"`
+}
+
+//ProductConfig - product in config file, numbers are int64 so out of range values can be reported
+type ProductConfig struct {
+	ProductNo int64        `json:"productNo" yaml:"productNo"`
+	Name      string       `json:"name" yaml:"name"`
+	Price     money.Amount `json:"price" yaml:"price"`
+	Stock     int64        `json:"stock" yaml:"stock"`
+}
+
+//SlotConfig - slot of planogram in config file, product's stock is the stock of its slots
+type SlotConfig struct {
+	Code      string `json:"code" yaml:"code"`
+	ProductNo int64  `json:"productNo,omitempty" yaml:"productNo,omitempty"`
+	Capacity  int64  `json:"capacity" yaml:"capacity"`
+	Stock     int64  `json:"stock" yaml:"stock"`
+	Jammed    bool   `json:"jammed,omitempty" yaml:"jammed,omitempty"`
+}
+
+//MoneyConfig - money in config file
+type MoneyConfig struct {
+	MoneyType string       `json:"type" yaml:"type"`
+	Name      string       `json:"name" yaml:"name"`
+	Value     money.Amount `json:"value" yaml:"value"`
+	Stock     int64        `json:"stock" yaml:"stock"`
+
+	//Capacity - the most pieces that can be used for change (banknote recycler), zero for no limit
+	Capacity int64 `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+
+	//NonRecyclable - received money goes to cash box and is never used for change
+	NonRecyclable bool `json:"nonRecyclable,omitempty" yaml:"nonRecyclable,omitempty"`
+}
+
+//Default - config from the hardcoded product's stock and money's stock, used when no config file is given
+func Default() Config {
+	cfg := Config{ChangeStrategy: payment.FEWEST_COINS}
+	for _, prod := range product.ProductStock {
+		cfg.Products = append(cfg.Products, ProductConfig{
+			ProductNo: prod.ProductNo,
+			Name:      prod.Name,
+			Price:     prod.Price,
+			Stock:     prod.Stock,
+		})
+	}
+	for _, mon := range money.MoneyStock {
+		cfg.Money = append(cfg.Money, MoneyConfig{
+			MoneyType:     mon.MoneyType,
+			Name:          mon.Name,
+			Value:         mon.Value,
+			Stock:         mon.Stock,
+			Capacity:      mon.Capacity,
+			NonRecyclable: mon.NonRecyclable,
+		})
+	}
+	return cfg
+}
+
+//Load - read and validate config from JSON (.json) or YAML (.yaml, .yml) file
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	cfg := Config{ChangeStrategy: payment.FEWEST_COINS}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&cfg)
+	default:
+		return Config{}, errors.New("config file must be .json, .yaml or .yml")
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid config file: %v", err)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+//Validate - check config can build a machine
+func (cfg Config) Validate() error {
+	_, err := payment.NewChangeMaker(cfg.ChangeStrategy)
+	if err != nil {
+		return err
+	}
+
+	_, err = parseTimeout("selection", cfg.SelectionTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = parseTimeout("payment", cfg.PaymentTimeout)
+	if err != nil {
+		return err
+	}
+	_, err = parseTimeout("reservation", cfg.ReservationTTL)
+	if err != nil {
+		return err
+	}
+
+	err = validatePIN(cfg.AdminPIN)
+	if err != nil {
+		return err
+	}
+
+	productNos := make(map[int64]bool)
+	for _, prod := range cfg.Products {
+		if prod.ProductNo < 1 {
+			return fmt.Errorf("product no. %v must be greater than zero", prod.ProductNo)
+		}
+		if productNos[prod.ProductNo] {
+			return fmt.Errorf("product no. %v is duplicated", prod.ProductNo)
+		}
+		productNos[prod.ProductNo] = true
+
+		if prod.Name == "" {
+			return fmt.Errorf("product no. %v has no name", prod.ProductNo)
+		}
+		if prod.Price < 0 {
+			return fmt.Errorf("%v's price must not be negative", prod.Name)
+		}
+		if prod.Stock < 0 {
+			return fmt.Errorf("%v's stock must not be negative", prod.Name)
+		}
+		if len(cfg.Slots) > 0 && prod.Stock != 0 {
+			return fmt.Errorf("%v's stock must be loaded into slots", prod.Name)
+		}
+	}
+
+	slotCodes := make(map[string]bool)
+	for _, slot := range cfg.Slots {
+		if !product.ValidSlotCode(slot.Code) {
+			return fmt.Errorf("slot code %v must be a row letter and a column number like A1", slot.Code)
+		}
+		if slotCodes[slot.Code] {
+			return fmt.Errorf("slot %v is duplicated", slot.Code)
+		}
+		slotCodes[slot.Code] = true
+
+		if slot.ProductNo != 0 && !productNos[slot.ProductNo] {
+			return fmt.Errorf("product no. %v of slot %v doesn't exist", slot.ProductNo, slot.Code)
+		}
+		if slot.Capacity < 1 {
+			return fmt.Errorf("slot %v's capacity must be greater than zero", slot.Code)
+		}
+		if slot.Stock < 0 || slot.Stock > slot.Capacity {
+			return fmt.Errorf("slot %v's stock must be between 0 and its capacity", slot.Code)
+		}
+		if slot.ProductNo == 0 && slot.Stock != 0 {
+			return fmt.Errorf("slot %v's stock must be zero because it's unassigned", slot.Code)
+		}
+	}
+
+	moneyNames := make(map[string]bool)
+	for _, mon := range cfg.Money {
+		if mon.Name == "" {
+			return errors.New("money has no name")
+		}
+		if moneyNames[mon.Name] {
+			return fmt.Errorf("money %v is duplicated", mon.Name)
+		}
+		moneyNames[mon.Name] = true
+
+		if mon.MoneyType != money.COIN && mon.MoneyType != money.BANK {
+			return fmt.Errorf("money %v's type must be %v or %v", mon.Name, money.COIN, money.BANK)
+		}
+		if mon.Value <= 0 {
+			return fmt.Errorf("money %v's value must be greater than zero", mon.Name)
+		}
+		if mon.Stock < 0 {
+			return fmt.Errorf("money %v's stock must not be negative", mon.Name)
+		}
+		if mon.Capacity < 0 {
+			return fmt.Errorf("money %v's capacity must not be negative", mon.Name)
+		}
+		if mon.Capacity > 0 && mon.Stock > mon.Capacity {
+			return fmt.Errorf("money %v's stock must not exceed its capacity", mon.Name)
+		}
+		if mon.NonRecyclable && mon.Stock != 0 {
+			return fmt.Errorf("money %v's stock must be zero because it's non-recyclable", mon.Name)
+		}
+	}
+
+	return nil
+}
+
+//ProductStock - product's stock of config, config must be valid
+func (cfg Config) ProductStock() []product.Product {
+	products := []product.Product{}
+	for _, prod := range cfg.Products {
+		products = append(products, product.Product{
+			ProductNo: prod.ProductNo,
+			Name:      prod.Name,
+			Price:     prod.Price,
+			Stock:     prod.Stock,
+		})
+	}
+	return products
+}
+
+//Planogram - slots of config, config must be valid
+func (cfg Config) Planogram() []product.Slot {
+	slots := []product.Slot{}
+	for _, slot := range cfg.Slots {
+		slots = append(slots, product.Slot{
+			Code:      slot.Code,
+			ProductNo: slot.ProductNo,
+			Capacity:  slot.Capacity,
+			Stock:     slot.Stock,
+			Jammed:    slot.Jammed,
+		})
+	}
+	return slots
+}
+
+//MoneyStock - money's stock of config, config must be valid
+func (cfg Config) MoneyStock() []money.Money {
+	moneyList := []money.Money{}
+	for _, mon := range cfg.Money {
+		moneyList = append(moneyList, money.Money{
+			MoneyType:     mon.MoneyType,
+			Name:          mon.Name,
+			Value:         mon.Value,
+			Stock:         mon.Stock,
+			Capacity:      mon.Capacity,
+			NonRecyclable: mon.NonRecyclable,
+		})
+	}
+	return moneyList
+}
+
+//NewMachine - validate config and build machine from it
+func (cfg Config) NewMachine() (*payment.Machine, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	machine := payment.NewMachine(cfg.ProductStock(), cfg.MoneyStock())
+	if len(cfg.Slots) > 0 {
+		err = machine.Inventory.LoadSlots(cfg.Planogram())
+		if err != nil {
+			return nil, err
+		}
+	}
+	machine.ChangeMaker, err = payment.NewChangeMaker(cfg.ChangeStrategy)
+	if err != nil {
+		return nil, err
+	}
+	machine.SelectionTimeout, err = parseTimeout("selection", cfg.SelectionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	machine.PaymentTimeout, err = parseTimeout("payment", cfg.PaymentTimeout)
+	if err != nil {
+		return nil, err
+	}
+	machine.ReservationTTL, err = parseTimeout("reservation", cfg.ReservationTTL)
+	if err != nil {
+		return nil, err
+	}
+	return machine, nil
+}
+
+//setting - what config or saved state sets to a product, money or slot named name
+type setting struct {
+	name  string
+	value string
+}
+
+//Differences - products, money and slots that saved state of the machine sets differently from config,
+//empty if they're the same, stock is not compared because it's changed by every purchase, config must be valid
+func (cfg Config) Differences(state payment.State) []string {
+	configured := settings(cfg.ProductStock(), cfg.MoneyStock(), cfg.Planogram())
+	saved := settings(state.Products, state.Money, state.Slots)
+
+	savedValues := make(map[string]string)
+	for _, savedSetting := range saved {
+		savedValues[savedSetting.name] = savedSetting.value
+	}
+	configuredNames := make(map[string]bool)
+	differences := []string{}
+	for _, configuredSetting := range configured {
+		configuredNames[configuredSetting.name] = true
+		savedValue, ok := savedValues[configuredSetting.name]
+		if !ok {
+			differences = append(differences, configuredSetting.name+" is in config but not in saved state")
+		} else if savedValue != configuredSetting.value {
+			differences = append(differences, fmt.Sprintf("%v is %v in config but %v in saved state", configuredSetting.name, configuredSetting.value, savedValue))
+		}
+	}
+	for _, savedSetting := range saved {
+		if !configuredNames[savedSetting.name] {
+			differences = append(differences, savedSetting.name+" is in saved state but not in config")
+		}
+	}
+	return differences
+}
+
+//settings - what is set to each of products, moneyList and slots apart from their stock
+func settings(products []product.Product, moneyList []money.Money, slots []product.Slot) []setting {
+	settings := []setting{}
+	for _, prod := range products {
+		settings = append(settings, setting{
+			name:  "product no. " + strconv.FormatInt(prod.ProductNo, 10),
+			value: fmt.Sprintf("%v for %v THB", prod.Name, prod.Price),
+		})
+	}
+	for _, mon := range moneyList {
+		value := fmt.Sprintf("%v of %v THB", mon.MoneyType, mon.Value)
+		if mon.Capacity > 0 {
+			value = value + fmt.Sprintf(" with capacity %v", mon.Capacity)
+		}
+		if mon.NonRecyclable {
+			value = value + " non-recyclable"
+		}
+		settings = append(settings, setting{name: "money " + mon.Name, value: value})
+	}
+	for _, slot := range slots {
+		settings = append(settings, setting{
+			name:  "slot " + slot.Code,
+			value: fmt.Sprintf("product no. %v with capacity %v", slot.ProductNo, slot.Capacity),
+		})
+	}
+	return settings
+}
+
+//validatePIN - PIN must be empty or at least 4 digits
+func validatePIN(pin string) error {
+	if pin == "" {
+		return nil
+	}
+	if len(pin) < 4 {
+		return errors.New("admin PIN must have at least 4 digits")
+	}
+	for _, digit := range pin {
+		if digit < '0' || digit > '9' {
+			return errors.New("admin PIN must have only digits")
+		}
+	}
+	return nil
+}
+
+//parseTimeout - duration of timeout of phase, zero if it's empty
+func parseTimeout(phase string, timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v timeout: %v", phase, err)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("%v timeout must not be negative", phase)
+	}
+	return duration, nil
+}
@@ -0,0 +1,559 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Load(t *testing.T) {
+	tests := []struct {
+		description   string
+		fileName      string
+		content       string
+		expected      Config
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_load_json_success",
+			fileName:    "config.json",
+			content: `{
+				"changeStrategy": "greedy",
+				"products": [{"productNo": 1, "name": "Lays", "price": 5, "stock": 1}],
+				"money": [{"type": "coin", "name": "1", "value": 1, "stock": 2}]
+			}`,
+			expected: Config{
+				ChangeStrategy: payment.GREEDY,
+				Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1}},
+				Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_load_yaml_success_with_default_change_strategy",
+			fileName:    "config.yaml",
+			content: `
+products:
+  - productNo: 1
+    name: Lays
+    price: 5
+    stock: 1
+money:
+  - type: coin
+    name: "1"
+    value: 1
+    stock: 2
+`,
+			expected: Config{
+				ChangeStrategy: payment.FEWEST_COINS,
+				Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1}},
+				Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_load_yaml_success_stock_beyond_int8",
+			fileName:    "config.yaml",
+			content: `
+products:
+  - {productNo: 200, name: Pepsi, price: 15, stock: 200}
+money:
+  - {type: coin, name: "1", value: 1, stock: 1000}
+`,
+			expected: Config{
+				ChangeStrategy: payment.FEWEST_COINS,
+				Products:       []ProductConfig{{ProductNo: 200, Name: "Pepsi", Price: money.Baht(15), Stock: 200}},
+				Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 1000}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_load_json_success_fractional_amounts",
+			fileName:    "config.json",
+			content: `{
+				"products": [{"productNo": 1, "name": "Lays", "price": 12.50, "stock": 1}],
+				"money": [{"type": "coin", "name": "0.25", "value": "0.25", "stock": 4}]
+			}`,
+			expected: Config{
+				ChangeStrategy: payment.FEWEST_COINS,
+				Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Satang(1250), Stock: 1}},
+				Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "0.25", Value: money.Satang(25), Stock: 4}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_load_yaml_success_fractional_amounts",
+			fileName:    "config.yaml",
+			content: `
+products:
+  - {productNo: 1, name: Lays, price: 12.5, stock: 1}
+money:
+  - {type: coin, name: "0.50", value: 0.50, stock: 4}
+`,
+			expected: Config{
+				ChangeStrategy: payment.FEWEST_COINS,
+				Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Satang(1250), Stock: 1}},
+				Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "0.50", Value: money.Satang(50), Stock: 4}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_load_failed_price_finer_than_satang",
+			fileName:    "config.yaml",
+			content: `
+products:
+  - {productNo: 1, name: Lays, price: 12.505, stock: 1}
+`,
+			expectedError: errors.New(`invalid config file: line 3: invalid amount "12.505", it must be baht with at most 2 decimal places like 12.50`),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_failed_unknown_extension",
+			fileName:      "config.txt",
+			content:       ``,
+			expectedError: errors.New("config file must be .json, .yaml or .yml"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_failed_invalid_json",
+			fileName:      "config.json",
+			content:       `{"products": [{"productNo": 1, "colour": "red"}]}`,
+			expectedError: errors.New("invalid config file: json: unknown field \"colour\""),
+			hasError:      true,
+		},
+		{
+			description: "test_load_failed_duplicate_product_no",
+			fileName:    "config.yml",
+			content: `
+products:
+  - {productNo: 1, name: Lays, price: 5, stock: 1}
+  - {productNo: 1, name: Pepsi, price: 15, stock: 1}
+`,
+			expectedError: errors.New("product no. 1 is duplicated"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.fileName)
+			err := os.WriteFile(path, []byte(test.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			output, err := Load(path)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, output)
+			}
+		})
+	}
+}
+
+func Test_Load_number_overflows(t *testing.T) {
+	tests := []struct {
+		description string
+		fileName    string
+		content     string
+	}{
+		{
+			description: "test_load_json_failed_stock_overflows",
+			fileName:    "config.json",
+			content:     `{"products": [{"productNo": 1, "name": "Lays", "price": 5, "stock": 9223372036854775808}]}`,
+		},
+		{
+			description: "test_load_yaml_failed_stock_overflows",
+			fileName:    "config.yaml",
+			content:     "products:\n  - {productNo: 1, name: Lays, price: 5, stock: 9223372036854775808}\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.fileName)
+			err := os.WriteFile(path, []byte(test.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			//number that doesn't fit in int64 is rejected rather than wrapped around
+			_, err = Load(path)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "cannot unmarshal")
+			assert.Contains(t, err.Error(), "int64")
+		})
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	validConfig := func() Config {
+		return Config{
+			ChangeStrategy: payment.FEWEST_COINS,
+			Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1}},
+			Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2}},
+		}
+	}
+
+	tests := []struct {
+		description   string
+		prepData      func(cfg *Config)
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_validate_success",
+			prepData:    func(cfg *Config) {},
+			hasError:    false,
+		},
+		{
+			description: "test_validate_failed_change_strategy_does_not_exist",
+			prepData: func(cfg *Config) {
+				cfg.ChangeStrategy = "random"
+			},
+			expectedError: errors.New("change strategy doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_success_with_timeouts",
+			prepData: func(cfg *Config) {
+				cfg.SelectionTimeout = "30s"
+				cfg.PaymentTimeout = "1m"
+				cfg.ReservationTTL = "5m"
+			},
+			hasError: false,
+		},
+		{
+			description: "test_validate_failed_invalid_timeout",
+			prepData: func(cfg *Config) {
+				cfg.PaymentTimeout = "soon"
+			},
+			expectedError: errors.New("invalid payment timeout: time: invalid duration \"soon\""),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_negative_timeout",
+			prepData: func(cfg *Config) {
+				cfg.SelectionTimeout = "-5s"
+			},
+			expectedError: errors.New("selection timeout must not be negative"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_invalid_reservation_ttl",
+			prepData: func(cfg *Config) {
+				cfg.ReservationTTL = "1x"
+			},
+			expectedError: errors.New("invalid reservation timeout: time: unknown unit \"x\" in duration \"1x\""),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_success_with_admin_pin",
+			prepData: func(cfg *Config) {
+				cfg.AdminPIN = "1234"
+			},
+			hasError: false,
+		},
+		{
+			description: "test_validate_failed_short_admin_pin",
+			prepData: func(cfg *Config) {
+				cfg.AdminPIN = "123"
+			},
+			expectedError: errors.New("admin PIN must have at least 4 digits"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_admin_pin_is_not_digits",
+			prepData: func(cfg *Config) {
+				cfg.AdminPIN = "12ab"
+			},
+			expectedError: errors.New("admin PIN must have only digits"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_duplicate_product_no",
+			prepData: func(cfg *Config) {
+				cfg.Products = append(cfg.Products, ProductConfig{ProductNo: 1, Name: "Pepsi", Price: money.Baht(15), Stock: 1})
+			},
+			expectedError: errors.New("product no. 1 is duplicated"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_success_product_no_and_stock_beyond_int8",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].ProductNo = 128
+				cfg.Products[0].Stock = 200
+			},
+			hasError: false,
+		},
+		{
+			description: "test_validate_failed_product_no_is_not_positive",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].ProductNo = 0
+			},
+			expectedError: errors.New("product no. 0 must be greater than zero"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_negative_price",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Price = -1
+			},
+			expectedError: errors.New("Lays's price must not be negative"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_negative_product_stock",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = -1
+			},
+			expectedError: errors.New("Lays's stock must not be negative"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_duplicate_money_name",
+			prepData: func(cfg *Config) {
+				cfg.Money = append(cfg.Money, MoneyConfig{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2})
+			},
+			expectedError: errors.New("money 1 is duplicated"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_negative_money_value",
+			prepData: func(cfg *Config) {
+				cfg.Money[0].Value = -1
+			},
+			expectedError: errors.New("money 1's value must be greater than zero"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_negative_money_stock",
+			prepData: func(cfg *Config) {
+				cfg.Money[0].Stock = -1
+			},
+			expectedError: errors.New("money 1's stock must not be negative"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_money_stock_exceeds_capacity",
+			prepData: func(cfg *Config) {
+				cfg.Money[0].Capacity = 1
+			},
+			expectedError: errors.New("money 1's stock must not exceed its capacity"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_non_recyclable_money_has_stock",
+			prepData: func(cfg *Config) {
+				cfg.Money = append(cfg.Money, MoneyConfig{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), Stock: 1, NonRecyclable: true})
+			},
+			expectedError: errors.New("money 1000's stock must be zero because it's non-recyclable"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_unknown_money_type",
+			prepData: func(cfg *Config) {
+				cfg.Money[0].MoneyType = "token"
+			},
+			expectedError: errors.New("money 1's type must be coin or bank"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_success_with_slots",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 3}, {Code: "A2", Capacity: 5}}
+			},
+			hasError: false,
+		},
+		{
+			description: "test_validate_failed_product_stock_with_slots",
+			prepData: func(cfg *Config) {
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 3}}
+			},
+			expectedError: errors.New("Lays's stock must be loaded into slots"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_invalid_slot_code",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "a1", ProductNo: 1, Capacity: 5}}
+			},
+			expectedError: errors.New("slot code a1 must be a row letter and a column number like A1"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_slot_is_duplicated",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5}, {Code: "A1", ProductNo: 1, Capacity: 5}}
+			},
+			expectedError: errors.New("slot A1 is duplicated"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_product_of_slot_doesnt_exist",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 2, Capacity: 5}}
+			},
+			expectedError: errors.New("product no. 2 of slot A1 doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_slot_has_no_capacity",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 1}}
+			},
+			expectedError: errors.New("slot A1's capacity must be greater than zero"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_slot_stock_over_capacity",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 6}}
+			},
+			expectedError: errors.New("slot A1's stock must be between 0 and its capacity"),
+			hasError:      true,
+		},
+		{
+			description: "test_validate_failed_stock_of_unassigned_slot",
+			prepData: func(cfg *Config) {
+				cfg.Products[0].Stock = 0
+				cfg.Slots = []SlotConfig{{Code: "A1", Capacity: 5, Stock: 1}}
+			},
+			expectedError: errors.New("slot A1's stock must be zero because it's unassigned"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			cfg := validConfig()
+			test.prepData(&cfg)
+
+			err := cfg.Validate()
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_Default_NewMachine(t *testing.T) {
+	machine, err := Default().NewMachine()
+	assert.NoError(t, err)
+
+	assert.Equal(t, product.ProductStock, machine.Inventory.Products)
+	assert.Equal(t, money.MoneyStock, machine.Bank.Money)
+	assert.Equal(t, payment.FewestCoinsChangeMaker{}, machine.ChangeMaker)
+	assert.Equal(t, time.Duration(0), machine.SelectionTimeout)
+	assert.Equal(t, time.Duration(0), machine.PaymentTimeout)
+	assert.Equal(t, time.Duration(0), machine.ReservationTTL)
+}
+
+func Test_NewMachine_not_exact_change_only(t *testing.T) {
+	example, err := Load("../config.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		description string
+		cfg         Config
+	}{
+		{
+			description: "test_default_stock",
+			cfg:         Default(),
+		},
+		{
+			description: "test_example_config",
+			cfg:         example,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine, err := test.cfg.NewMachine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.False(t, machine.ExactChangeOnly())
+
+			//the payment prompt of every product doesn't show the indicator with normal stock
+			for _, prod := range machine.Inventory.List() {
+				session := machine.NewSession()
+				session.Start()
+				assert.NoError(t, session.Select(strconv.FormatInt(prod.ProductNo, 10)))
+				assert.NoError(t, session.FinishSelection())
+
+				output := &bytes.Buffer{}
+				assert.NoError(t, session.ReadPayment(strings.NewReader("cancel\n"), output))
+				assert.NotContains(t, output.String(), "EXACT CHANGE ONLY")
+				assert.NotContains(t, output.String(), "can't be accepted now")
+			}
+		})
+	}
+}
+
+func Test_NewMachine_with_slots(t *testing.T) {
+	cfg := Config{
+		ChangeStrategy: payment.FEWEST_COINS,
+		Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}},
+		Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2}},
+		Slots:          []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 3}, {Code: "A2", ProductNo: 1, Capacity: 5, Stock: 2, Jammed: true}},
+	}
+	machine, err := cfg.NewMachine()
+	assert.NoError(t, err)
+
+	assert.Equal(t, []product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 3},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 2, Jammed: true},
+	}, machine.Inventory.ListSlots())
+	assert.Equal(t, int64(3), machine.Inventory.List()[0].Stock)
+}
+
+func Test_Config_Differences(t *testing.T) {
+	cfg := Config{
+		ChangeStrategy: payment.FEWEST_COINS,
+		Products:       []ProductConfig{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}, {ProductNo: 2, Name: "Hanami", Price: money.Baht(10)}},
+		Money:          []MoneyConfig{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 2}},
+		Slots:          []SlotConfig{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 3}},
+	}
+	machine, err := cfg.NewMachine()
+	assert.NoError(t, err)
+
+	//stock changed by purchases is not a difference
+	state := machine.State()
+	state.Products[0].Stock = 0
+	state.Slots[0].Stock = 0
+	state.Money[0].Stock = 9
+	assert.Empty(t, cfg.Differences(state))
+
+	state.Products[0].Price = money.Baht(6)
+	state.Products = state.Products[:1]
+	state.Money = append(state.Money, money.Money{MoneyType: money.BANK, Name: "20", Value: money.Baht(20), NonRecyclable: true})
+	state.Slots[0].Capacity = 8
+	assert.Equal(t, []string{
+		"product no. 1 is Lays for 5 THB in config but Lays for 6 THB in saved state",
+		"product no. 2 is in config but not in saved state",
+		"slot A1 is product no. 1 with capacity 5 in config but product no. 1 with capacity 8 in saved state",
+		"money 20 is in saved state but not in config",
+	}, cfg.Differences(state))
+}
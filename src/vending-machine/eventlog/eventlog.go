@@ -0,0 +1,61 @@
+package eventlog
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"vending-machine/jsonl"
+	"vending-machine/payment"
+)
+
+//Log - events of the machine written as JSON lines to output, apart from what user sees,
+//a failed write never stops a purchase, the first error is kept for Err
+type Log struct {
+	mu     sync.Mutex
+	output io.Writer
+	file   *jsonl.File //file opened by Open, nil if output is given
+	err    error
+}
+
+//New - event log that writes to output, e.g. os.Stderr
+func New(output io.Writer) *Log {
+	return &Log{output: output}
+}
+
+//Open - event log that appends to file at path, create it if it doesn't exist
+func Open(path string) (*Log, error) {
+	file, err := jsonl.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Log{output: file, file: file}, nil
+}
+
+//Close - close file opened by Open, output given to New is left open
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+//Write - write event as a JSON line
+func (l *Log) Write(event payment.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, err := json.Marshal(event)
+	if err == nil {
+		_, err = l.output.Write(append(data, '\n'))
+	}
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+//Err - the first error of writing events, nil if every event is written
+func (l *Log) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
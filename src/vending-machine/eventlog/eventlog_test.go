@@ -0,0 +1,61 @@
+package eventlog
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Log_Write(t *testing.T) {
+	at := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	output := &bytes.Buffer{}
+
+	eventLog := New(output)
+	eventLog.Write(payment.Event{Type: payment.EVENT_PRODUCT_SELECTED, Time: at, SessionID: "a1", ProductNo: 1, Name: "Lays", Amount: money.Baht(5)})
+	eventLog.Write(payment.Event{Type: payment.EVENT_COIN_REJECTED, Time: at, SessionID: "a1", Input: "3", Reason: "money doesn't exist"})
+
+	assert.NoError(t, eventLog.Err())
+	assert.Equal(t, `{"type":"ProductSelected","time":"2021-01-01T10:00:00Z","sessionId":"a1","productNo":1,"name":"Lays","amount":5}
+{"type":"CoinRejected","time":"2021-01-01T10:00:00Z","sessionId":"a1","input":"3","reason":"money doesn't exist"}
+`, output.String())
+}
+
+//failedWriter - writer that always fails
+type failedWriter struct{}
+
+func (failedWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk is full")
+}
+
+func Test_Log_Write_keeps_first_error(t *testing.T) {
+	eventLog := New(failedWriter{})
+	eventLog.Write(payment.Event{Type: payment.EVENT_DISPENSED})
+	eventLog.Write(payment.Event{Type: payment.EVENT_CHANGE_RETURNED})
+
+	assert.Equal(t, errors.New("disk is full"), eventLog.Err())
+}
+
+func Test_Open_appends_to_file(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.log")
+	at := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	eventLog, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventLog.Write(payment.Event{Type: payment.EVENT_DISPENSED, Time: at, SessionID: "a1", Amount: money.Baht(5)})
+	assert.NoError(t, eventLog.Err())
+	assert.NoError(t, eventLog.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"type":"Dispensed","time":"2021-01-01T10:00:00Z","sessionId":"a1","amount":5}
+`, string(data))
+}
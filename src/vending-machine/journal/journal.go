@@ -0,0 +1,129 @@
+package journal
+
+import (
+	"encoding/json"
+	"time"
+	"vending-machine/jsonl"
+	"vending-machine/payment"
+)
+
+const (
+	BEGIN    = "begin"
+	COMMIT   = "commit"
+	ROLLBACK = "rollback"
+)
+
+//Record - line of journal file
+type Record struct {
+	Status      string              `json:"status"`
+	Time        time.Time           `json:"time"`
+	Transaction payment.Transaction `json:"transaction"`
+}
+
+//FileJournal - write-ahead log of transactions kept as JSON lines in a file,
+//every record is flushed to disk before Begin, Commit or Rollback returns
+type FileJournal struct {
+	file   *jsonl.File
+	lastID int64
+}
+
+//Open - open journal file at path, create it if it doesn't exist
+func Open(path string) (*FileJournal, error) {
+	file, err := jsonl.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &FileJournal{file: file}
+	records, err := journal.records()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Transaction.ID > journal.lastID {
+			journal.lastID = record.Transaction.ID
+		}
+	}
+	return journal, nil
+}
+
+//Close - close journal file
+func (j *FileJournal) Close() error {
+	return j.file.Close()
+}
+
+//Begin - write intent of tx with a new ID
+func (j *FileJournal) Begin(tx payment.Transaction) (payment.Transaction, error) {
+	tx.ID = j.lastID + 1
+	err := j.write(BEGIN, tx)
+	if err != nil {
+		return payment.Transaction{}, err
+	}
+	j.lastID = tx.ID
+	return tx, nil
+}
+
+//Commit - mark tx as applied
+func (j *FileJournal) Commit(tx payment.Transaction) error {
+	return j.write(COMMIT, tx)
+}
+
+//Rollback - mark tx as not applied
+func (j *FileJournal) Rollback(tx payment.Transaction) error {
+	return j.write(ROLLBACK, tx)
+}
+
+//Incomplete - transactions that began but were neither committed nor rolled back, oldest first
+func (j *FileJournal) Incomplete() ([]payment.Transaction, error) {
+	records, err := j.records()
+	if err != nil {
+		return nil, err
+	}
+
+	began := []payment.Transaction{}
+	finished := make(map[int64]bool)
+	for _, record := range records {
+		switch record.Status {
+		case BEGIN:
+			began = append(began, record.Transaction)
+		case COMMIT, ROLLBACK:
+			finished[record.Transaction.ID] = true
+		}
+	}
+
+	incomplete := []payment.Transaction{}
+	for _, tx := range began {
+		if !finished[tx.ID] {
+			incomplete = append(incomplete, tx)
+		}
+	}
+	return incomplete, nil
+}
+
+//write - append record of tx and flush it to disk
+func (j *FileJournal) write(status string, tx payment.Transaction) error {
+	return j.file.Append(Record{
+		Status:      status,
+		Time:        time.Now(),
+		Transaction: tx,
+	})
+}
+
+//records - read every record of journal file
+func (j *FileJournal) records() ([]Record, error) {
+	records := []Record{}
+	err := j.file.Read(func(line []byte) error {
+		var record Record
+		err := json.Unmarshal(line, &record)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,117 @@
+package journal
+
+import (
+	"path/filepath"
+	"testing"
+	"vending-machine/money"
+	"vending-machine/payment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData() payment.Transaction {
+	return payment.Transaction{
+		Products: map[int64]int64{1: 1},
+		Received: map[string]int64{"10": 1},
+		Change: []money.Money{
+			{
+				MoneyType: money.COIN,
+				Name:      "5",
+				Value:     money.Baht(5),
+			},
+		},
+	}
+}
+
+func Test_FileJournal_Incomplete(t *testing.T) {
+	tests := []struct {
+		description string
+		prepData    func(j *FileJournal)
+		expectedIDs []int64
+	}{
+		{
+			description: "test_incomplete_success_with_empty_journal",
+			prepData:    func(j *FileJournal) {},
+			expectedIDs: []int64{},
+		},
+		{
+			description: "test_incomplete_success_with_committed_and_rolled_back_transactions",
+			prepData: func(j *FileJournal) {
+				first, _ := j.Begin(commonPrepData())
+				j.Commit(first)
+				second, _ := j.Begin(commonPrepData())
+				j.Rollback(second)
+			},
+			expectedIDs: []int64{},
+		},
+		{
+			description: "test_incomplete_success_with_began_transactions",
+			prepData: func(j *FileJournal) {
+				j.Begin(commonPrepData())
+				second, _ := j.Begin(commonPrepData())
+				j.Commit(second)
+				j.Begin(commonPrepData())
+			},
+			expectedIDs: []int64{1, 3},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			journal, err := Open(filepath.Join(t.TempDir(), "journal.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer journal.Close()
+
+			test.prepData(journal)
+
+			output, err := journal.Incomplete()
+			assert.NoError(t, err)
+
+			ids := []int64{}
+			for _, tx := range output {
+				ids = append(ids, tx.ID)
+				assert.Equal(t, commonPrepData().Products, tx.Products)
+				assert.Equal(t, commonPrepData().Received, tx.Received)
+				assert.Equal(t, commonPrepData().Change, tx.Change)
+			}
+			assert.Equal(t, test.expectedIDs, ids)
+		})
+	}
+}
+
+func Test_Open_continues_existing_journal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+
+	journal, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := journal.Begin(commonPrepData())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), first.ID)
+	journal.Close()
+
+	journal, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	incomplete, err := journal.Incomplete()
+	assert.NoError(t, err)
+	assert.Len(t, incomplete, 1)
+	assert.Equal(t, int64(1), incomplete[0].ID)
+
+	second, err := journal.Begin(commonPrepData())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), second.ID)
+
+	err = journal.Commit(first)
+	assert.NoError(t, err)
+
+	incomplete, err = journal.Incomplete()
+	assert.NoError(t, err)
+	assert.Len(t, incomplete, 1)
+	assert.Equal(t, int64(2), incomplete[0].ID)
+}
@@ -0,0 +1,125 @@
+package jsonl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+//MAX_LINE_SIZE - the longest line that can be read, a longer line fails Read
+const MAX_LINE_SIZE = 16 * 1024 * 1024
+
+//File - append-only file of JSON lines,
+//a broken last line is a line that was being written when the machine stopped
+type File struct {
+	file *os.File
+}
+
+//Open - open JSON lines file at path, create it if it doesn't exist,
+//a last line that is whole JSON but misses its newline is ended, any other broken last line is cut off,
+//so the next line starts on its own line and a broken line is never followed by other lines
+func Open(path string) (*File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonlFile := &File{file: file}
+	err = jsonlFile.terminateBrokenLine()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return jsonlFile, nil
+}
+
+//Close - close file
+func (f *File) Close() error {
+	return f.file.Close()
+}
+
+//Write - append data without flushing it to disk, data must be whole lines
+func (f *File) Write(data []byte) (int, error) {
+	return f.file.Write(data)
+}
+
+//Append - append value as a JSON line and flush it to disk
+func (f *File) Append(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.file.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+//Read - call decode with every line of file, oldest first,
+//a last line that decode fails is a broken line being written when the machine stopped, so it is skipped,
+//decode failing any other line is corruption and fails Read
+func (f *File) Read(decode func(line []byte) error) error {
+	_, err := f.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(f.file)
+	scanner.Buffer(make([]byte, 64*1024), MAX_LINE_SIZE)
+	var decodeErr error
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if decodeErr != nil {
+			return decodeErr
+		}
+		err = decode(scanner.Bytes())
+		if err != nil {
+			decodeErr = fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+//terminateBrokenLine - end a whole JSON last line or cut off a broken last line and flush it to disk
+func (f *File) terminateBrokenLine() error {
+	info, err := f.file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+
+	lastByte := make([]byte, 1)
+	_, err = f.file.ReadAt(lastByte, info.Size()-1)
+	if err != nil {
+		return err
+	}
+	if lastByte[0] == '\n' {
+		return nil
+	}
+
+	//a line longer than MAX_LINE_SIZE can't be read anyway, so only the end of file is searched for its start
+	offset := info.Size() - MAX_LINE_SIZE
+	if offset < 0 {
+		offset = 0
+	}
+	data, err := io.ReadAll(io.NewSectionReader(f.file, offset, info.Size()-offset))
+	if err != nil {
+		return err
+	}
+	lineStart := bytes.LastIndexByte(data, '\n') + 1
+	if json.Valid(data[lineStart:]) {
+		_, err = f.file.Write([]byte{'\n'})
+	} else {
+		err = f.file.Truncate(offset + int64(lineStart))
+	}
+	if err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
@@ -0,0 +1,157 @@
+package jsonl
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLine struct {
+	No   int    `json:"no"`
+	Text string `json:"text"`
+}
+
+func readLines(t *testing.T, file *File) []testLine {
+	lines := []testLine{}
+	err := file.Read(func(data []byte) error {
+		var line testLine
+		err := json.Unmarshal(data, &line)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	assert.NoError(t, err)
+	return lines
+}
+
+func Test_File_continues_after_broken_line(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.log")
+
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, file.Append(testLine{No: 1, Text: "first"}))
+	assert.NoError(t, file.Close())
+
+	//line being written when the machine stopped
+	broken, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken.WriteString(`{"no":2,"te`)
+	broken.Close()
+
+	//reopened file cuts off the broken line and continues after existing lines
+	file, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	assert.NoError(t, file.Append(testLine{No: 3, Text: "third"}))
+
+	assert.Equal(t, []testLine{{No: 1, Text: "first"}, {No: 3, Text: "third"}}, readLines(t, file))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\"no\":1,\"text\":\"first\"}\n{\"no\":3,\"text\":\"third\"}\n", string(data))
+}
+
+func Test_File_Open_ends_whole_last_line(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.log")
+	assert.NoError(t, os.WriteFile(path, []byte("{\"no\":1,\"text\":\"first\"}"), 0644))
+
+	//last line was written whole but its newline wasn't, so it is kept
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	assert.NoError(t, file.Append(testLine{No: 2, Text: "second"}))
+	assert.Equal(t, []testLine{{No: 1, Text: "first"}, {No: 2, Text: "second"}}, readLines(t, file))
+}
+
+func Test_File_Read_broken_lines(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []testLine
+		wantErr string
+	}{
+		{
+			name: "broken last line is skipped",
+			data: "{\"no\":1,\"text\":\"first\"}\n{\"no\":2,\"te",
+			want: []testLine{{No: 1, Text: "first"}},
+		},
+		{
+			name:    "broken line before other lines is corruption",
+			data:    "{\"no\":1,\"text\":\"first\"}\n{\"no\":2,\"te\n{\"no\":3,\"text\":\"third\"}\n",
+			wantErr: "line 2: ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, err := Open(filepath.Join(t.TempDir(), "lines.log"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			_, err = file.Write([]byte(test.data))
+			assert.NoError(t, err)
+
+			lines := []testLine{}
+			err = file.Read(func(data []byte) error {
+				var line testLine
+				err := json.Unmarshal(data, &line)
+				if err != nil {
+					return err
+				}
+				lines = append(lines, line)
+				return nil
+			})
+			if test.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, lines)
+		})
+	}
+}
+
+func Test_File_Write(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lines.log")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"no":1,"te`), 0644))
+
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	//written lines start after the cut off broken line, same as appended lines
+	_, err = file.Write([]byte("{\"no\":2,\"text\":\"second\"}\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []testLine{{No: 2, Text: "second"}}, readLines(t, file))
+}
+
+func Test_File_Read_long_line(t *testing.T) {
+	file, err := Open(filepath.Join(t.TempDir(), "lines.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	//a line longer than bufio.Scanner's default buffer is still read
+	long := strings.Repeat("x", 1024*1024)
+	assert.NoError(t, file.Append(testLine{No: 1, Text: long}))
+	assert.Equal(t, []testLine{{No: 1, Text: long}}, readLines(t, file))
+}
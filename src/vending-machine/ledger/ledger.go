@@ -0,0 +1,50 @@
+package ledger
+
+import (
+	"encoding/json"
+	"vending-machine/jsonl"
+	"vending-machine/payment"
+)
+
+//FileLedger - record of finished purchases kept as JSON lines in a file,
+//every entry is flushed to disk before Append returns
+type FileLedger struct {
+	file *jsonl.File
+}
+
+//Open - open ledger file at path, create it if it doesn't exist
+func Open(path string) (*FileLedger, error) {
+	file, err := jsonl.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLedger{file: file}, nil
+}
+
+//Close - close ledger file
+func (l *FileLedger) Close() error {
+	return l.file.Close()
+}
+
+//Append - append entry and flush it to disk
+func (l *FileLedger) Append(entry payment.LedgerEntry) error {
+	return l.file.Append(entry)
+}
+
+//Entries - read every entry of ledger file, oldest first
+func (l *FileLedger) Entries() ([]payment.LedgerEntry, error) {
+	entries := []payment.LedgerEntry{}
+	err := l.file.Read(func(line []byte) error {
+		var entry payment.LedgerEntry
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
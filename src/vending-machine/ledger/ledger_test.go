@@ -0,0 +1,57 @@
+package ledger
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData(at time.Time, status string) payment.LedgerEntry {
+	buyedProducts := map[product.Product]int64{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}:    2,
+		{ProductNo: 2, Name: "Hanami", Price: money.Baht(10)}: 1,
+	}
+	receivedMoney := map[money.Money]int64{
+		{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 2,
+		{MoneyType: money.COIN, Name: "1", Value: money.Baht(1)}:   1,
+	}
+	changeList := []money.Money{
+		{MoneyType: money.COIN, Name: "1", Value: money.Baht(1)},
+	}
+
+	receipt := payment.NewReceipt(buyedProducts, money.Baht(20), receivedMoney, changeList, status == payment.STATUS_SUCCESSFUL)
+	receipt.Status = status
+	return payment.LedgerEntry{Time: at, Receipt: receipt}
+}
+
+func Test_FileLedger_Entries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.log")
+	at := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	salesLedger, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, salesLedger.Append(commonPrepData(at, payment.STATUS_SUCCESSFUL)))
+	assert.NoError(t, salesLedger.Close())
+
+	//reopened ledger continues after existing entries
+	salesLedger, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer salesLedger.Close()
+	assert.NoError(t, salesLedger.Append(commonPrepData(at, payment.STATUS_CANCELLED)))
+
+	entries, err := salesLedger.Entries()
+	assert.NoError(t, err)
+	assert.Equal(t, []payment.LedgerEntry{
+		commonPrepData(at, payment.STATUS_SUCCESSFUL),
+		commonPrepData(at, payment.STATUS_CANCELLED),
+	}, entries)
+}
@@ -0,0 +1,243 @@
+package ledger
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"vending-machine/money"
+	"vending-machine/payment"
+)
+
+const (
+	FORMAT_TABLE = "table"
+	FORMAT_CSV   = "csv"
+	FORMAT_JSON  = "json"
+)
+
+//DailyReport - totals of purchases that finished on a day
+type DailyReport struct {
+	Date string `json:"date"`
+
+	//Transactions - every finished purchase, the sum of the counts by outcome below
+	Transactions       int64 `json:"transactions"`
+	Completed          int64 `json:"completed"`
+	Cancellations      int64 `json:"cancellations"`
+	TimedOut           int64 `json:"timedOut"`
+	InsufficientChange int64 `json:"insufficientChange"`
+	Failed             int64 `json:"failed"`
+
+	//Revenue - total price of completed purchases
+	Revenue money.Amount `json:"revenue"`
+
+	Units []ProductUnits `json:"units"`
+	Money []MoneyFlow    `json:"money"`
+}
+
+//ProductUnits - pieces of a product sold by completed purchases
+type ProductUnits struct {
+	ProductNo int64        `json:"productNo"`
+	Name      string       `json:"name"`
+	Quantity  int64        `json:"quantity"`
+	Revenue   money.Amount `json:"revenue"`
+}
+
+//MoneyFlow - pieces of a money paid in and changed out by completed purchases,
+//money of unsuccessful purchases is returned to user so it doesn't flow
+type MoneyFlow struct {
+	MoneyType string       `json:"type"`
+	Name      string       `json:"name"`
+	Value     money.Amount `json:"value"`
+	In        int64        `json:"in"`
+	Out       int64        `json:"out"`
+}
+
+//DailyReports - totals of entries for each day from the oldest, only of date (e.g. "2021-01-01") if it's not empty
+func DailyReports(entries []payment.LedgerEntry, date string) []DailyReport {
+	reports := []DailyReport{}
+	indexes := make(map[string]int)
+	for _, entry := range entries {
+		entryDate := entry.Time.Format("2006-01-02")
+		if date != "" && entryDate != date {
+			continue
+		}
+
+		i, ok := indexes[entryDate]
+		if !ok {
+			reports = append(reports, DailyReport{
+				Date:  entryDate,
+				Units: []ProductUnits{},
+				Money: []MoneyFlow{},
+			})
+			i = len(reports) - 1
+			indexes[entryDate] = i
+		}
+		reports[i].add(entry.Receipt)
+	}
+
+	sort.SliceStable(reports, func(i, j int) bool {
+		return reports[i].Date < reports[j].Date
+	})
+	for _, report := range reports {
+		sort.Slice(report.Units, func(i, j int) bool {
+			return report.Units[i].ProductNo < report.Units[j].ProductNo
+		})
+		sort.Slice(report.Money, func(i, j int) bool {
+			return report.Money[i].Value > report.Money[j].Value
+		})
+	}
+	return reports
+}
+
+//add - count purchase summarized by receipt to the report
+func (report *DailyReport) add(receipt payment.Receipt) {
+	report.Transactions = report.Transactions + 1
+	switch receipt.Status {
+	case payment.STATUS_SUCCESSFUL:
+		report.Completed = report.Completed + 1
+	case payment.STATUS_CANCELLED:
+		report.Cancellations = report.Cancellations + 1
+		return
+	case payment.STATUS_TIMED_OUT:
+		report.TimedOut = report.TimedOut + 1
+		return
+	case payment.STATUS_INSUFFICIENT_CHANGE:
+		report.InsufficientChange = report.InsufficientChange + 1
+		return
+	default:
+		report.Failed = report.Failed + 1
+		return
+	}
+
+	report.Revenue = report.Revenue + receipt.TotalAmount
+	for _, boughtProduct := range receipt.Products {
+		units := report.units(boughtProduct)
+		units.Quantity = units.Quantity + boughtProduct.Quantity
+		units.Revenue = units.Revenue + boughtProduct.Price.Times(boughtProduct.Quantity)
+	}
+	for _, paid := range receipt.Paid {
+		flow := report.money(paid)
+		flow.In = flow.In + paid.Quantity
+	}
+	for _, change := range receipt.Change {
+		flow := report.money(change)
+		flow.Out = flow.Out + change.Quantity
+	}
+}
+
+//units - units of boughtProduct in the report, added if it isn't there yet
+func (report *DailyReport) units(boughtProduct payment.ReceiptProduct) *ProductUnits {
+	for i, units := range report.Units {
+		if units.ProductNo == boughtProduct.ProductNo {
+			return &report.Units[i]
+		}
+	}
+	report.Units = append(report.Units, ProductUnits{
+		ProductNo: boughtProduct.ProductNo,
+		Name:      boughtProduct.Name,
+	})
+	return &report.Units[len(report.Units)-1]
+}
+
+//money - flow of mon in the report, added if it isn't there yet
+func (report *DailyReport) money(mon payment.ReceiptMoney) *MoneyFlow {
+	for i, flow := range report.Money {
+		if flow.Name == mon.Name {
+			return &report.Money[i]
+		}
+	}
+	report.Money = append(report.Money, MoneyFlow{
+		MoneyType: mon.MoneyType,
+		Name:      mon.Name,
+		Value:     mon.Value,
+	})
+	return &report.Money[len(report.Money)-1]
+}
+
+//WriteReports - write reports to output in format table, csv or json
+func WriteReports(output io.Writer, reports []DailyReport, format string) error {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	switch format {
+	case FORMAT_TABLE:
+		printTable(output, reports)
+		return nil
+	case FORMAT_CSV:
+		return writeCSV(output, reports)
+	case FORMAT_JSON:
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+	return errors.New("report format must be table, csv or json")
+}
+
+func printTable(output io.Writer, reports []DailyReport) {
+	if len(reports) == 0 {
+		fmt.Fprintln(output, "no transaction")
+		return
+	}
+
+	for _, report := range reports {
+		fmt.Fprintf(output, "------------ Report %v ------------\n", report.Date)
+		fmt.Fprintln(output, "transactions:        ", report.Transactions)
+		fmt.Fprintln(output, "completed:           ", report.Completed)
+		fmt.Fprintln(output, "cancelled:           ", report.Cancellations)
+		fmt.Fprintln(output, "timed out:           ", report.TimedOut)
+		fmt.Fprintln(output, "insufficient change: ", report.InsufficientChange)
+		fmt.Fprintln(output, "failed:              ", report.Failed)
+		fmt.Fprintln(output, "revenue:             ", report.Revenue, "THB")
+
+		fmt.Fprintln(output, "\nNo        Name      Units     Revenue")
+		fmt.Fprintln(output, "-----------------------------------")
+		for _, units := range report.Units {
+			fmt.Fprintf(output, "%-10v%-10v%-10v%-10v\n", units.ProductNo, units.Name, units.Quantity, units.Revenue)
+		}
+
+		fmt.Fprintln(output, "\nMoneyType   Name        Value       In          Out")
+		fmt.Fprintln(output, "------------------------------------------------------")
+		for _, flow := range report.Money {
+			fmt.Fprintf(output, "%-12v%-12v%-12v%-12v%-12v\n", flow.MoneyType, flow.Name, flow.Value, flow.In, flow.Out)
+		}
+		fmt.Fprintln(output, "-----------------------------------")
+	}
+}
+
+//writeCSV - write reports as rows of date, metric, name and value so every day has the same columns
+func writeCSV(output io.Writer, reports []DailyReport) error {
+	writer := csv.NewWriter(output)
+	rows := [][]string{{"date", "metric", "name", "value"}}
+	for _, report := range reports {
+		rows = append(rows,
+			[]string{report.Date, "transactions", "", strconv.FormatInt(report.Transactions, 10)},
+			[]string{report.Date, "completed", "", strconv.FormatInt(report.Completed, 10)},
+			[]string{report.Date, "cancellations", "", strconv.FormatInt(report.Cancellations, 10)},
+			[]string{report.Date, "timed-out", "", strconv.FormatInt(report.TimedOut, 10)},
+			[]string{report.Date, "insufficient-change", "", strconv.FormatInt(report.InsufficientChange, 10)},
+			[]string{report.Date, "failed", "", strconv.FormatInt(report.Failed, 10)},
+			[]string{report.Date, "revenue", "", report.Revenue.String()},
+		)
+		for _, units := range report.Units {
+			rows = append(rows, []string{report.Date, "units", units.Name, strconv.FormatInt(units.Quantity, 10)})
+		}
+		for _, flow := range report.Money {
+			rows = append(rows,
+				[]string{report.Date, "money-in", flow.Name, strconv.FormatInt(flow.In, 10)},
+				[]string{report.Date, "money-out", flow.Name, strconv.FormatInt(flow.Out, 10)},
+			)
+		}
+	}
+
+	err := writer.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
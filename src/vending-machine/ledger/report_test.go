@@ -0,0 +1,178 @@
+package ledger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reportPrepData() []payment.LedgerEntry {
+	firstDay := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	secondDay := time.Date(2021, 1, 2, 10, 0, 0, 0, time.UTC)
+	return []payment.LedgerEntry{
+		commonPrepData(secondDay, payment.STATUS_TIMED_OUT),
+		commonPrepData(firstDay, payment.STATUS_SUCCESSFUL),
+		commonPrepData(firstDay, payment.STATUS_SUCCESSFUL),
+		commonPrepData(firstDay, payment.STATUS_CANCELLED),
+		commonPrepData(firstDay, payment.STATUS_INSUFFICIENT_CHANGE),
+		commonPrepData(firstDay, payment.STATUS_UNSUCCESSFUL),
+	}
+}
+
+func Test_DailyReports(t *testing.T) {
+	firstDay := DailyReport{
+		Date:               "2021-01-01",
+		Transactions:       5,
+		Completed:          2,
+		Cancellations:      1,
+		InsufficientChange: 1,
+		Failed:             1,
+		Revenue:            money.Baht(40),
+		Units: []ProductUnits{
+			{ProductNo: 1, Name: "Lays", Quantity: 4, Revenue: money.Baht(20)},
+			{ProductNo: 2, Name: "Hanami", Quantity: 2, Revenue: money.Baht(20)},
+		},
+		Money: []MoneyFlow{
+			{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), In: 4, Out: 0},
+			{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), In: 2, Out: 2},
+		},
+	}
+	secondDay := DailyReport{
+		Date:         "2021-01-02",
+		Transactions: 1,
+		TimedOut:     1,
+		Units:        []ProductUnits{},
+		Money:        []MoneyFlow{},
+	}
+
+	tests := []struct {
+		description string
+		date        string
+		expected    []DailyReport
+	}{
+		{
+			description: "test_daily_reports_success_every_day",
+			date:        "",
+			expected:    []DailyReport{firstDay, secondDay},
+		},
+		{
+			description: "test_daily_reports_success_a_day",
+			date:        "2021-01-02",
+			expected:    []DailyReport{secondDay},
+		},
+		{
+			description: "test_daily_reports_success_no_transaction",
+			date:        "2021-01-03",
+			expected:    []DailyReport{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			assert.Equal(t, test.expected, DailyReports(reportPrepData(), test.date))
+		})
+	}
+}
+
+func Test_WriteReports(t *testing.T) {
+	tests := []struct {
+		description    string
+		format         string
+		expectedOutput string
+		expectedError  error
+		hasError       bool
+	}{
+		{
+			description: "test_write_reports_table_success",
+			format:      FORMAT_TABLE,
+			expectedOutput: "------------ Report 2021-01-02 ------------\n" +
+				"transactions:         1\n" +
+				"completed:            0\n" +
+				"cancelled:            0\n" +
+				"timed out:            1\n" +
+				"insufficient change:  0\n" +
+				"failed:               0\n" +
+				"revenue:              0 THB\n" +
+				"\nNo        Name      Units     Revenue\n" +
+				"-----------------------------------\n" +
+				"\nMoneyType   Name        Value       In          Out\n" +
+				"------------------------------------------------------\n" +
+				"-----------------------------------\n",
+			hasError: false,
+		},
+		{
+			description: "test_write_reports_csv_success",
+			format:      FORMAT_CSV,
+			expectedOutput: "date,metric,name,value\n" +
+				"2021-01-02,transactions,,1\n" +
+				"2021-01-02,completed,,0\n" +
+				"2021-01-02,cancellations,,0\n" +
+				"2021-01-02,timed-out,,1\n" +
+				"2021-01-02,insufficient-change,,0\n" +
+				"2021-01-02,failed,,0\n" +
+				"2021-01-02,revenue,,0\n",
+			hasError: false,
+		},
+		{
+			description: "test_write_reports_json_success",
+			format:      FORMAT_JSON,
+			expectedOutput: "[\n" +
+				"  {\n" +
+				"    \"date\": \"2021-01-02\",\n" +
+				"    \"transactions\": 1,\n" +
+				"    \"completed\": 0,\n" +
+				"    \"cancellations\": 0,\n" +
+				"    \"timedOut\": 1,\n" +
+				"    \"insufficientChange\": 0,\n" +
+				"    \"failed\": 0,\n" +
+				"    \"revenue\": 0,\n" +
+				"    \"units\": [],\n" +
+				"    \"money\": []\n" +
+				"  }\n" +
+				"]\n",
+			hasError: false,
+		},
+		{
+			description:   "test_write_reports_failed_unknown_format",
+			format:        "xml",
+			expectedError: errors.New("report format must be table, csv or json"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			err := WriteReports(output, DailyReports(reportPrepData(), "2021-01-02"), test.format)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expectedOutput, output.String())
+			}
+		})
+	}
+}
+
+func Test_WriteReports_units_and_money(t *testing.T) {
+	output := &bytes.Buffer{}
+	err := WriteReports(output, DailyReports(reportPrepData(), "2021-01-01"), FORMAT_CSV)
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "2021-01-01,revenue,,40\n"+
+		"2021-01-01,units,Lays,4\n"+
+		"2021-01-01,units,Hanami,2\n"+
+		"2021-01-01,money-in,10,4\n"+
+		"2021-01-01,money-out,10,0\n"+
+		"2021-01-01,money-in,1,2\n"+
+		"2021-01-01,money-out,1,2\n")
+
+	output.Reset()
+	err = WriteReports(output, DailyReports(reportPrepData(), "2021-01-01"), FORMAT_TABLE)
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "1         Lays      4         20        \n")
+	assert.Contains(t, output.String(), "coin        10          10          4           0           \n")
+}
@@ -1,45 +1,301 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"vending-machine/money"
+	"io"
+	"net/http"
+	"os"
+	"time"
+	"vending-machine/admin"
+	"vending-machine/api"
+	"vending-machine/cashaudit"
+	"vending-machine/config"
+	"vending-machine/eventlog"
+	"vending-machine/journal"
+	"vending-machine/ledger"
+	"vending-machine/metrics"
 	"vending-machine/payment"
-	"vending-machine/product"
+	"vending-machine/store"
 )
 
+//ADMIN_PIN_ENV - environment variable of admin mode's PIN, it overrides PIN of config
+const ADMIN_PIN_ENV = "VENDING_ADMIN_PIN"
+
 func main() {
+	configPath := flag.String("config", "", "path to JSON or YAML config file of product's stock and money's stock")
+	statePath := flag.String("state", "state.json", "path to file where machine's stock is saved between restarts")
+	journalPath := flag.String("journal", "journal.log", "path to write-ahead journal of transactions")
+	ledgerPath := flag.String("ledger", "ledger.log", "path to ledger of finished purchases")
+	eventLogPath := flag.String("events", "events.log", "path to JSON lines log of purchase events for diagnostics, \"-\" for stderr")
+	reportFormat := flag.String("report", "", "print daily report of the ledger in format table, csv or json, then exit")
+	reportDate := flag.String("report-date", "", "day of the report, e.g. 2021-01-31 (default every day)")
+	metricsAddr := flag.String("metrics", "", "serve Prometheus metrics at address (e.g. localhost:9100) on GET /metrics, disabled by default")
+	httpAddr := flag.String("http", "", "serve HTTP REST API at address (e.g. :8080) instead of the interactive prompt")
+	selectionTimeout := flag.Duration("selection-timeout", -1, "inactivity allowed while selecting products before the purchase is cancelled, e.g. 30s, 0 for waiting forever (overrides config, default 5m with -http)")
+	paymentTimeout := flag.Duration("payment-timeout", -1, "inactivity allowed while paying before the purchase is cancelled and money is returned, e.g. 1m, 0 for waiting forever (overrides config, default 5m with -http)")
+	reservationTTL := flag.Duration("reservation-ttl", -1, "how long selected products are held for a purchase without checkout, e.g. 5m, 0 for holding them until the purchase ends (overrides config)")
+	cashAuditPath := flag.String("cash-audits", "cash-audits.log", "path to cash audits of counted money")
+	auditLogPath := flag.String("audit-log", "audit.log", "path to audit log of admin actions")
+	changeStrategy := flag.String("change-strategy", "", "change strategy: greedy, fewest-coins, preserve-scarce, fullest-tube-first or prefer-coins (overrides config)")
+	flag.Parse()
+
+	//report of purchases recorded by previous runs, the machine doesn't start
+	if *reportFormat != "" {
+		err := printReport(*ledgerPath, *reportFormat, *reportDate)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	//if config file is not given then use default product's stock and money's stock
+	cfg := config.Default()
+	if *configPath != "" {
+		var err error
+		cfg, err = config.Load(*configPath)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+	if *changeStrategy != "" {
+		cfg.ChangeStrategy = *changeStrategy
+	}
+	//PIN is taken from environment rather than a flag so it isn't shown in the process list and shell history
+	if pin := os.Getenv(ADMIN_PIN_ENV); pin != "" {
+		cfg.AdminPIN = pin
+	}
+	if *selectionTimeout >= 0 {
+		cfg.SelectionTimeout = selectionTimeout.String()
+	}
+	if *paymentTimeout >= 0 {
+		cfg.PaymentTimeout = paymentTimeout.String()
+	}
+	if *reservationTTL >= 0 {
+		cfg.ReservationTTL = reservationTTL.String()
+	}
+
+	//sessions of HTTP REST API that clients abandon are cancelled unless timeouts are configured
+	if *httpAddr != "" && cfg.SelectionTimeout == "" {
+		cfg.SelectionTimeout = api.DEFAULT_SELECTION_TIMEOUT.String()
+	}
+	if *httpAddr != "" && cfg.PaymentTimeout == "" {
+		cfg.PaymentTimeout = api.DEFAULT_PAYMENT_TIMEOUT.String()
+	}
+
+	//build machine from config
+	machine, err := cfg.NewMachine()
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	//reload machine's stock saved by previous run, config's stock is used only on the first run
+	stateStore := store.NewFileStore(*statePath)
+	state, err := stateStore.Load()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	if err == nil {
+		machine.Restore(state)
+
+		//edits of config after the first run have no effect until the state file is removed
+		differences := cfg.Differences(state)
+		if len(differences) > 0 {
+			fmt.Printf("warning: products, money and slots are loaded from %v instead of config, remove it to use config again\n", *statePath)
+			for _, difference := range differences {
+				fmt.Println("  -", difference)
+			}
+		}
+	}
+	machine.Store = stateStore
+
+	//finish transactions that were incomplete when the machine stopped
+	transactionJournal, err := journal.Open(*journalPath)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer transactionJournal.Close()
+	machine.Journal = transactionJournal
+
+	replayed, reverted, err := machine.Recover()
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	for _, tx := range replayed {
+		fmt.Printf("warning: incomplete transaction %v was recovered\n", tx.ID)
+	}
+	for _, tx := range reverted {
+		fmt.Printf("warning: incomplete transaction %v was reverted\n", tx.ID)
+	}
+
+	//purchases that weren't sold by Recover left the stock as before them
+	for _, pending := range state.Interrupted(replayed) {
+		fmt.Printf("warning: transaction started at %v for %v THB was interrupted, products and money's stock are as before it\n", pending.StartedAt.Format(time.RFC3339), pending.TotalAmount)
+	}
+
+	//finished purchases are recorded for reports
+	salesLedger, err := ledger.Open(*ledgerPath)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer salesLedger.Close()
+	machine.Ledger = salesLedger
+
+	//events of purchases are written apart from the customer's prompts
+	eventLog := eventlog.New(os.Stderr)
+	if *eventLogPath != "-" {
+		eventLog, err = eventlog.Open(*eventLogPath)
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+	}
+	defer func() {
+		eventLog.Close()
+		if eventLog.Err() != nil {
+			fmt.Println("warning: some events can't be written:", eventLog.Err())
+		}
+	}()
+	machine.Events = eventLog
+
+	//admin actions are recorded so every change of stock by operators can be traced
+	auditLog, err := admin.OpenAuditLog(*auditLogPath)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer auditLog.Close()
+	maintenance := admin.NewAdmin(machine, cfg.AdminPIN, auditLog)
+	maintenance.Ledger = salesLedger
+
+	//cash audits are kept so discrepancies of counted money can be traced over time
+	cashAudits, err := cashaudit.Open(*cashAuditPath)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	defer cashAudits.Close()
+	maintenance.CashAudits = cashAudits
+
+	//activity is counted for monitoring from the stock the machine starts with
+	if *metricsAddr != "" {
+		machineMetrics := metrics.NewMachineMetrics()
+		machineMetrics.Stock(machine.State())
+		machine.Metrics = machineMetrics
+
+		go func() {
+			err := http.ListenAndServe(*metricsAddr, machineMetrics)
+			if err != nil {
+				fmt.Println("error: metrics can't be served:", err)
+			}
+		}()
+	}
+
+	err = stateStore.Save(machine.State())
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
+	//customer interacts with the machine through HTTP REST API
+	if *httpAddr != "" {
+		fmt.Println("serving HTTP REST API at", *httpAddr)
+		err = http.ListenAndServe(*httpAddr, api.NewServer(machine))
+		if err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	//customer interacts with the machine through stdin and stdout,
+	//lines of stdin are read by one line reader so a line typed after a timeout goes to the next prompt
+	userInput, output := payment.NewLineReader(os.Stdin), os.Stdout
+
+	//one purchase at a time, the session is reset after each purchase
+	session := machine.NewSession()
+
 	//loop until user want to exit
 	for {
 		//list of product's stock
-		product.ListAllProducts()
+		machine.ListAllProducts(output)
 
 		//list of money's stock
-		money.ListAvailableMoney()
+		machine.ListAvailableMoney(output)
 
 		//user select product
-		boughtProducts, totalAmount, err := product.SelectProduct(nil)
+		err = session.Start()
+		if err == nil {
+			err = session.ReadSelection(userInput, output)
+		}
 		if err != nil {
-			fmt.Print("error: ", err)
+			fmt.Fprint(output, "error: ", err)
 			return
 		}
 
-		//do payment process
-		receivedMoney, changeList, isSuccessful, err := payment.Payment(totalAmount, boughtProducts)
+		//do payment process, unless the selection timed out
+		if session.State() != payment.STATE_CANCELLED {
+			err = session.ReadPayment(userInput, output)
+		}
 		if err != nil {
-			fmt.Print("error: ", err)
+			fmt.Fprint(output, "error: ", err)
 			return
 		}
 
 		//purchase summary
-		payment.Summary(boughtProducts, totalAmount, receivedMoney, changeList, isSuccessful)
+		session.Summary(output)
 
 		//if user type "exit" then program will terminate
+		//if user type "admin" then operator can maintain the machine, then user can shop again
 		//if user ENTER then user can shop again
-		var userContinue string
-		fmt.Println("\nPress ENTER key to continue shopping or type \"exit\" to exit program")
-		fmt.Scanln(&userContinue)
-		if userContinue == "exit" {
-			break
+		//if the purchase timed out then the machine is ready for the next user
+		//if input ends or can't be read then program will terminate
+		if session.Receipt().Status != payment.STATUS_TIMED_OUT {
+			var userContinue string
+			fmt.Fprintln(output, "\nPress ENTER key to continue shopping, type \"admin\" for maintenance or \"exit\" to exit program")
+			_, err = fmt.Fscanln(userInput, &userContinue)
+			if userInput.Err() != nil {
+				fmt.Fprintln(output, "error:", userInput.Err())
+				return
+			}
+			if userContinue == "exit" || err == io.EOF {
+				break
+			}
+			if userContinue == "admin" {
+				err = maintenance.Run(userInput, output)
+				if err != nil {
+					fmt.Fprintln(output, "error:", err)
+				}
+			}
+		}
+
+		err = session.Reset()
+		if err != nil {
+			fmt.Fprint(output, "error: ", err)
+			return
 		}
 	}
 }
+
+//printReport - print daily report of purchases in ledger at ledgerPath
+func printReport(ledgerPath string, format string, date string) error {
+	salesLedger, err := ledger.Open(ledgerPath)
+	if err != nil {
+		return err
+	}
+	defer salesLedger.Close()
+
+	entries, err := salesLedger.Entries()
+	if err != nil {
+		return err
+	}
+	return ledger.WriteReports(os.Stdout, ledger.DailyReports(entries, date), format)
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+)
+
+//DurationBuckets - upper bounds in seconds of the payment duration histogram
+var DurationBuckets = []float64{1, 5, 10, 30, 60, 120, 300}
+
+//MachineMetrics - counters and gauges of a machine in Prometheus text format,
+//safe to be counted by the machine while it is scraped through HTTP
+type MachineMetrics struct {
+	mu sync.Mutex
+
+	transactions   map[string]int64       //finished purchases by status
+	revenue        money.Amount           //total price of successful purchases
+	unitsSold      map[productLabel]int64 //pieces sold by successful purchases
+	changeFailures map[string]int64       //change that can't be made by stage
+
+	productStock map[productLabel]int64
+	moneyStock   map[moneyLabel]int64
+	moneyCashBox map[moneyLabel]int64
+
+	durationBuckets []int64 //count of payments for each of DurationBuckets
+	durationSum     float64
+	durationCount   int64
+}
+
+type productLabel struct {
+	ProductNo int64
+	Name      string
+}
+
+type moneyLabel struct {
+	MoneyType string
+	Name      string
+}
+
+//NewMachineMetrics - create metrics where nothing is counted yet
+func NewMachineMetrics() *MachineMetrics {
+	return &MachineMetrics{
+		transactions:    make(map[string]int64),
+		unitsSold:       make(map[productLabel]int64),
+		changeFailures:  make(map[string]int64),
+		productStock:    make(map[productLabel]int64),
+		moneyStock:      make(map[moneyLabel]int64),
+		moneyCashBox:    make(map[moneyLabel]int64),
+		durationBuckets: make([]int64, len(DurationBuckets)),
+	}
+}
+
+//Stock - replace stock gauges with state, products and money that are gone are dropped
+func (mm *MachineMetrics) Stock(state payment.State) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.productStock = make(map[productLabel]int64)
+	for _, prod := range state.Products {
+		mm.productStock[productLabel{ProductNo: prod.ProductNo, Name: prod.Name}] = prod.Stock
+	}
+	mm.moneyStock = make(map[moneyLabel]int64)
+	mm.moneyCashBox = make(map[moneyLabel]int64)
+	for _, mon := range state.Money {
+		label := moneyLabel{MoneyType: mon.MoneyType, Name: mon.Name}
+		mm.moneyStock[label] = mon.Stock
+		mm.moneyCashBox[label] = mon.CashBox
+	}
+}
+
+//Purchase - count finished purchase, only successful purchases are counted to revenue and units sold
+func (mm *MachineMetrics) Purchase(receipt payment.Receipt, paymentDuration time.Duration) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.transactions[receipt.Status] = mm.transactions[receipt.Status] + 1
+	if receipt.Status == payment.STATUS_SUCCESSFUL {
+		mm.revenue = mm.revenue + receipt.TotalAmount
+		for _, boughtProduct := range receipt.Products {
+			label := productLabel{ProductNo: boughtProduct.ProductNo, Name: boughtProduct.Name}
+			mm.unitsSold[label] = mm.unitsSold[label] + boughtProduct.Quantity
+		}
+	}
+
+	//purchase that never awaited payment has no payment duration
+	if paymentDuration <= 0 {
+		return
+	}
+	seconds := paymentDuration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			mm.durationBuckets[i] = mm.durationBuckets[i] + 1
+		}
+	}
+	mm.durationSum = mm.durationSum + seconds
+	mm.durationCount = mm.durationCount + 1
+}
+
+//ChangeFailed - count change that can't be made at stage
+func (mm *MachineMetrics) ChangeFailed(stage string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	mm.changeFailures[stage] = mm.changeFailures[stage] + 1
+}
+
+//WriteTo - write every metric in Prometheus text format, ordered by name then labels
+func (mm *MachineMetrics) WriteTo(output io.Writer) (int64, error) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+
+	var b strings.Builder
+
+	writeHeader(&b, "vending_transactions_total", "counter", "Finished purchases by status.")
+	for _, status := range sortedKeys(mm.transactions) {
+		writeSample(&b, "vending_transactions_total", labels("status", status), float64(mm.transactions[status]))
+	}
+
+	writeHeader(&b, "vending_revenue_thb_total", "counter", "Total price of successful purchases in THB.")
+	writeSample(&b, "vending_revenue_thb_total", "", float64(mm.revenue)/money.SATANG_PER_BAHT)
+
+	writeHeader(&b, "vending_units_sold_total", "counter", "Pieces of products sold by successful purchases.")
+	writeProducts(&b, "vending_units_sold_total", mm.unitsSold)
+
+	writeHeader(&b, "vending_change_failures_total", "counter", "Change that can't be made, by stage of the purchase.")
+	for _, stage := range sortedKeys(mm.changeFailures) {
+		writeSample(&b, "vending_change_failures_total", labels("stage", stage), float64(mm.changeFailures[stage]))
+	}
+
+	writeHeader(&b, "vending_product_stock", "gauge", "Pieces of products in stock.")
+	writeProducts(&b, "vending_product_stock", mm.productStock)
+
+	writeHeader(&b, "vending_money_stock", "gauge", "Pieces of money that can be used for change.")
+	writeMoney(&b, "vending_money_stock", mm.moneyStock)
+
+	writeHeader(&b, "vending_money_cash_box", "gauge", "Pieces of money in the cash box.")
+	writeMoney(&b, "vending_money_cash_box", mm.moneyCashBox)
+
+	writeHeader(&b, "vending_payment_duration_seconds", "histogram", "Time from awaiting payment to the end of the purchase.")
+	for i, bound := range DurationBuckets {
+		writeSample(&b, "vending_payment_duration_seconds_bucket", labels("le", formatFloat(bound)), float64(mm.durationBuckets[i]))
+	}
+	writeSample(&b, "vending_payment_duration_seconds_bucket", labels("le", "+Inf"), float64(mm.durationCount))
+	writeSample(&b, "vending_payment_duration_seconds_sum", "", mm.durationSum)
+	writeSample(&b, "vending_payment_duration_seconds_count", "", float64(mm.durationCount))
+
+	n, err := io.WriteString(output, b.String())
+	return int64(n), err
+}
+
+//ServeHTTP - serve metrics for scraping at GET /metrics
+func (mm *MachineMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/metrics" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mm.WriteTo(w)
+}
+
+func writeHeader(b *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(b, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(b, "# TYPE %v %v\n", name, metricType)
+}
+
+func writeSample(b *strings.Builder, name string, labels string, value float64) {
+	fmt.Fprintf(b, "%v%v %v\n", name, labels, formatFloat(value))
+}
+
+//writeProducts - write a sample of each product ordered by product no.
+func writeProducts(b *strings.Builder, name string, values map[productLabel]int64) {
+	products := make([]productLabel, 0, len(values))
+	for label := range values {
+		products = append(products, label)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		if products[i].ProductNo != products[j].ProductNo {
+			return products[i].ProductNo < products[j].ProductNo
+		}
+		return products[i].Name < products[j].Name
+	})
+	for _, label := range products {
+		writeSample(b, name, labels("product_no", strconv.FormatInt(label.ProductNo, 10), "name", label.Name), float64(values[label]))
+	}
+}
+
+//writeMoney - write a sample of each money ordered by type then name
+func writeMoney(b *strings.Builder, name string, values map[moneyLabel]int64) {
+	moneyList := make([]moneyLabel, 0, len(values))
+	for label := range values {
+		moneyList = append(moneyList, label)
+	}
+	sort.Slice(moneyList, func(i, j int) bool {
+		if moneyList[i].MoneyType != moneyList[j].MoneyType {
+			return moneyList[i].MoneyType < moneyList[j].MoneyType
+		}
+		return moneyList[i].Name < moneyList[j].Name
+	})
+	for _, label := range moneyList {
+		writeSample(b, name, labels("type", label.MoneyType, "name", label.Name), float64(values[label]))
+	}
+}
+
+//labels - format pairs of label name and value as {name="value",...}
+func labels(pairs ...string) string {
+	parts := []string{}
+	for i := 0; i+1 < len(pairs); i = i + 2 {
+		parts = append(parts, pairs[i]+`="`+escapeLabelValue(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+//escapeLabelValue - escape backslash, double quote and line feed as Prometheus text format requires
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys(values map[string]int64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
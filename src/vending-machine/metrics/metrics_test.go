@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MachineMetrics_WriteTo(t *testing.T) {
+	machineMetrics := NewMachineMetrics()
+	machineMetrics.Stock(payment.State{
+		Products: []product.Product{
+			{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 3},
+			{ProductNo: 1, Name: `Lays "Nori"`, Price: money.Baht(5), Stock: 0},
+		},
+		Money: []money.Money{
+			{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 4},
+			{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), CashBox: 1},
+		},
+	})
+	machineMetrics.Purchase(payment.Receipt{
+		Status:      payment.STATUS_SUCCESSFUL,
+		TotalAmount: money.Baht(25),
+		Products: []payment.ReceiptProduct{
+			{ProductNo: 1, Name: `Lays "Nori"`, Price: money.Baht(5), Quantity: 1},
+			{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Quantity: 2},
+		},
+	}, 3*time.Second)
+	machineMetrics.Purchase(payment.Receipt{
+		Status:      payment.STATUS_CANCELLED,
+		TotalAmount: money.Baht(10),
+		Products:    []payment.ReceiptProduct{{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Quantity: 1}},
+	}, 90*time.Second)
+	machineMetrics.Purchase(payment.Receipt{Status: payment.STATUS_TIMED_OUT}, 0)
+	machineMetrics.ChangeFailed(payment.STAGE_INSERT)
+	machineMetrics.ChangeFailed(payment.STAGE_INSERT)
+	machineMetrics.ChangeFailed(payment.STAGE_CHECKOUT)
+
+	output := &bytes.Buffer{}
+	_, err := machineMetrics.WriteTo(output)
+	assert.NoError(t, err)
+	assert.Equal(t, `# HELP vending_transactions_total Finished purchases by status.
+# TYPE vending_transactions_total counter
+vending_transactions_total{status="cancelled"} 1
+vending_transactions_total{status="successful"} 1
+vending_transactions_total{status="timed-out"} 1
+# HELP vending_revenue_thb_total Total price of successful purchases in THB.
+# TYPE vending_revenue_thb_total counter
+vending_revenue_thb_total 25
+# HELP vending_units_sold_total Pieces of products sold by successful purchases.
+# TYPE vending_units_sold_total counter
+vending_units_sold_total{product_no="1",name="Lays \"Nori\""} 1
+vending_units_sold_total{product_no="2",name="Hanami"} 2
+# HELP vending_change_failures_total Change that can't be made, by stage of the purchase.
+# TYPE vending_change_failures_total counter
+vending_change_failures_total{stage="checkout"} 1
+vending_change_failures_total{stage="insert"} 2
+# HELP vending_product_stock Pieces of products in stock.
+# TYPE vending_product_stock gauge
+vending_product_stock{product_no="1",name="Lays \"Nori\""} 0
+vending_product_stock{product_no="2",name="Hanami"} 3
+# HELP vending_money_stock Pieces of money that can be used for change.
+# TYPE vending_money_stock gauge
+vending_money_stock{type="bank",name="1000"} 0
+vending_money_stock{type="coin",name="5"} 4
+# HELP vending_money_cash_box Pieces of money in the cash box.
+# TYPE vending_money_cash_box gauge
+vending_money_cash_box{type="bank",name="1000"} 1
+vending_money_cash_box{type="coin",name="5"} 0
+# HELP vending_payment_duration_seconds Time from awaiting payment to the end of the purchase.
+# TYPE vending_payment_duration_seconds histogram
+vending_payment_duration_seconds_bucket{le="1"} 0
+vending_payment_duration_seconds_bucket{le="5"} 1
+vending_payment_duration_seconds_bucket{le="10"} 1
+vending_payment_duration_seconds_bucket{le="30"} 1
+vending_payment_duration_seconds_bucket{le="60"} 1
+vending_payment_duration_seconds_bucket{le="120"} 2
+vending_payment_duration_seconds_bucket{le="300"} 2
+vending_payment_duration_seconds_bucket{le="+Inf"} 2
+vending_payment_duration_seconds_sum 93
+vending_payment_duration_seconds_count 2
+`, output.String())
+}
+
+func Test_MachineMetrics_Stock_drops_removed_product(t *testing.T) {
+	machineMetrics := NewMachineMetrics()
+	machineMetrics.Stock(payment.State{Products: []product.Product{{ProductNo: 1, Name: "Lays", Stock: 1}, {ProductNo: 2, Name: "Hanami", Stock: 2}}})
+	machineMetrics.Stock(payment.State{Products: []product.Product{{ProductNo: 2, Name: "Hanami", Stock: 1}}})
+
+	output := &bytes.Buffer{}
+	machineMetrics.WriteTo(output)
+	assert.Contains(t, output.String(), "vending_product_stock{product_no=\"2\",name=\"Hanami\"} 1\n")
+	assert.NotContains(t, output.String(), "vending_product_stock{product_no=\"1\"")
+}
+
+func Test_MachineMetrics_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		description        string
+		method             string
+		path               string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			description:        "test_metrics_success",
+			method:             http.MethodGet,
+			path:               "/metrics",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "vending_revenue_thb_total 0\n",
+		},
+		{
+			description:        "test_metrics_failed_method_not_allowed",
+			method:             http.MethodPost,
+			path:               "/metrics",
+			expectedStatusCode: http.StatusMethodNotAllowed,
+			expectedBody:       "method not allowed",
+		},
+		{
+			description:        "test_metrics_failed_not_found",
+			method:             http.MethodGet,
+			path:               "/products",
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       "not found",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			NewMachineMetrics().ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+			assert.Equal(t, test.expectedStatusCode, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), test.expectedBody)
+			if test.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//SATANG_PER_BAHT - satang, the minor unit of money, in a baht
+const SATANG_PER_BAHT = 100
+
+//Amount - amount of money in satang, written in baht with at most 2 decimal places like "12.50"
+type Amount int64
+
+//Baht - amount of whole baht
+func Baht(baht int64) Amount {
+	return Amount(baht * SATANG_PER_BAHT)
+}
+
+//Satang - amount of satang
+func Satang(satang int64) Amount {
+	return Amount(satang)
+}
+
+//ParseAmount - amount of text in baht like "12", "12.5" or "12.50"
+func ParseAmount(text string) (Amount, error) {
+	invalid := errors.New("invalid amount " + strconv.Quote(text) + ", it must be baht with at most 2 decimal places like 12.50")
+
+	digits := strings.TrimPrefix(text, "-")
+	negative := digits != text
+	whole, fraction := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		whole, fraction = digits[:i], digits[i+1:]
+		if fraction == "" || len(fraction) > 2 {
+			return 0, invalid
+		}
+	}
+	if whole == "" || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, invalid
+	}
+
+	baht, err := strconv.ParseInt(whole, 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, errors.New("amount " + text + " is out of range")
+	}
+	if err != nil {
+		return 0, invalid
+	}
+	var satang int64
+	if fraction != "" {
+		satang, err = strconv.ParseInt(fraction+strings.Repeat("0", 2-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, invalid
+		}
+	}
+
+	//baht*100+satang must fit in int64
+	if baht > (math.MaxInt64-satang)/SATANG_PER_BAHT {
+		return 0, errors.New("amount " + text + " is out of range")
+	}
+	amount := Amount(baht*SATANG_PER_BAHT + satang)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+//String - amount in baht, whole baht has no decimal places like "12" and the rest has 2 like "12.50"
+func (a Amount) String() string {
+	sign := ""
+	satang := uint64(a)
+	if a < 0 {
+		sign = "-"
+		satang = -satang
+	}
+
+	baht, fraction := satang/SATANG_PER_BAHT, satang%SATANG_PER_BAHT
+	if fraction == 0 {
+		return sign + strconv.FormatUint(baht, 10)
+	}
+	return fmt.Sprintf("%v%v.%02d", sign, baht, fraction)
+}
+
+//Times - amount of quantity pieces that each is a
+func (a Amount) Times(quantity int64) Amount {
+	return a * Amount(quantity)
+}
+
+//MarshalJSON - amount as a number of baht, so JSON of whole baht is the same as before amounts had satang
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+//UnmarshalJSON - amount from a number or a string of baht
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+
+	amount, err := ParseAmount(text)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+//UnmarshalYAML - amount from a number or a string of baht
+func (a *Amount) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %v: amount must be a number of baht", value.Line)
+	}
+
+	amount, err := ParseAmount(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %v: %v", value.Line, err)
+	}
+	*a = amount
+	return nil
+}
@@ -0,0 +1,125 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_ParseAmount(t *testing.T) {
+	tests := []struct {
+		description   string
+		input         string
+		expected      Amount
+		expectedError error
+	}{
+		{description: "test_parse_amount_whole_baht", input: "12", expected: Baht(12)},
+		{description: "test_parse_amount_one_decimal_place", input: "12.5", expected: Satang(1250)},
+		{description: "test_parse_amount_two_decimal_places", input: "12.50", expected: Satang(1250)},
+		{description: "test_parse_amount_satang_only", input: "0.25", expected: Satang(25)},
+		{description: "test_parse_amount_negative", input: "-1.05", expected: Satang(-105)},
+		{description: "test_parse_amount_largest", input: "92233720368547758.07", expected: Amount(math.MaxInt64)},
+		{
+			description:   "test_parse_amount_failed_too_many_decimal_places",
+			input:         "12.505",
+			expectedError: errors.New(`invalid amount "12.505", it must be baht with at most 2 decimal places like 12.50`),
+		},
+		{
+			description:   "test_parse_amount_failed_no_decimal_places",
+			input:         "12.",
+			expectedError: errors.New(`invalid amount "12.", it must be baht with at most 2 decimal places like 12.50`),
+		},
+		{
+			description:   "test_parse_amount_failed_exponent",
+			input:         "1e3",
+			expectedError: errors.New(`invalid amount "1e3", it must be baht with at most 2 decimal places like 12.50`),
+		},
+		{
+			description:   "test_parse_amount_failed_plus_sign",
+			input:         "+5",
+			expectedError: errors.New(`invalid amount "+5", it must be baht with at most 2 decimal places like 12.50`),
+		},
+		{
+			description:   "test_parse_amount_failed_empty",
+			input:         "",
+			expectedError: errors.New(`invalid amount "", it must be baht with at most 2 decimal places like 12.50`),
+		},
+		{
+			description:   "test_parse_amount_failed_out_of_range",
+			input:         "92233720368547758.08",
+			expectedError: errors.New("amount 92233720368547758.08 is out of range"),
+		},
+		{
+			description:   "test_parse_amount_failed_baht_out_of_range",
+			input:         "9223372036854775808",
+			expectedError: errors.New("amount 9223372036854775808 is out of range"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output, err := ParseAmount(test.input)
+			if test.expectedError != nil {
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, Amount(0), output)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, output)
+			}
+		})
+	}
+}
+
+func Test_Amount_String(t *testing.T) {
+	tests := []struct {
+		input    Amount
+		expected string
+	}{
+		{input: Baht(12), expected: "12"},
+		{input: Satang(1250), expected: "12.50"},
+		{input: Satang(5), expected: "0.05"},
+		{input: Satang(-105), expected: "-1.05"},
+		{input: 0, expected: "0"},
+		{input: Amount(math.MinInt64), expected: "-92233720368547758.08"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.input.String())
+		})
+	}
+}
+
+func Test_Amount_JSON(t *testing.T) {
+	data, err := json.Marshal(map[string]Amount{"whole": Baht(5), "fraction": Satang(1250)})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"fraction":12.50,"whole":5}`, string(data))
+
+	var amounts map[string]Amount
+	assert.NoError(t, json.Unmarshal([]byte(`{"number": 12.5, "string": "0.25", "whole": 5}`), &amounts))
+	assert.Equal(t, map[string]Amount{"number": Satang(1250), "string": Satang(25), "whole": Baht(5)}, amounts)
+
+	err = json.Unmarshal([]byte(`{"number": 12.505}`), &amounts)
+	assert.EqualError(t, err, `invalid amount "12.505", it must be baht with at most 2 decimal places like 12.50`)
+}
+
+func Test_Amount_YAML(t *testing.T) {
+	var amounts map[string]Amount
+	assert.NoError(t, yaml.Unmarshal([]byte("whole: 5\nfraction: 12.50\nquoted: \"0.25\"\n"), &amounts))
+	assert.Equal(t, map[string]Amount{"whole": Baht(5), "fraction": Satang(1250), "quoted": Satang(25)}, amounts)
+
+	err := yaml.Unmarshal([]byte("whole: 5\nlist: [1, 2]\n"), &amounts)
+	assert.EqualError(t, err, "line 2: amount must be a number of baht")
+
+	err = yaml.Unmarshal([]byte("fraction: 0.125\n"), &amounts)
+	assert.EqualError(t, err, `line 1: invalid amount "0.125", it must be baht with at most 2 decimal places like 12.50`)
+}
+
+func Test_Amount_Times(t *testing.T) {
+	assert.Equal(t, Satang(3750), Satang(1250).Times(3))
+	assert.Equal(t, Amount(0), Baht(5).Times(0))
+}
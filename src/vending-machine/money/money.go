@@ -3,7 +3,12 @@ package money
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"sort"
+	"strconv"
+	"sync"
 )
 
 const (
@@ -14,28 +19,43 @@ const (
 type Money struct {
 	MoneyType string
 	Name      string
-	Value     int64
-	Stock     int64
+	Value     Amount
+
+	//Stock - pieces that can be used for change
+	Stock int64
+
+	//Capacity - the most pieces that Stock can hold (coin tube or banknote recycler),
+	//received money beyond it overflows to the cash box, zero for no limit
+	Capacity int64
+
+	//NonRecyclable - received money always goes to the cash box, so it's never used for change
+	NonRecyclable bool
+
+	//CashBox - pieces received that can't be used for change
+	CashBox int64
 }
 
 var MoneyStock = []Money{
 	{
 		MoneyType: COIN,
 		Name:      "1",
-		Value:     1,
+		Value:     Baht(1),
 		Stock:     2,
+		Capacity:  100,
 	},
 	{
 		MoneyType: COIN,
 		Name:      "5",
-		Value:     5,
+		Value:     Baht(5),
 		Stock:     2,
+		Capacity:  80,
 	},
 	{
 		MoneyType: COIN,
 		Name:      "10",
-		Value:     10,
+		Value:     Baht(10),
 		Stock:     10,
+		Capacity:  60,
 	},
 }
 
@@ -47,19 +67,117 @@ func init() {
 	})
 }
 
-func ListAvailableMoney() {
-	fmt.Println("List of money")
-	fmt.Println("MoneyType   Name        Value       Stock")
-	fmt.Println("------------------------------------------")
-	for _, money := range MoneyStock {
-		fmt.Printf("%-12v%-12v%-12v%-12v\n", money.MoneyType, money.Name, money.Value, money.Stock)
+//Deposit - money after amount pieces are received from user,
+//they go to stock while there is room and the rest goes to cash box,
+//money's pieces must fit in int64 wherever they go
+func (mon Money) Deposit(amount int64) (Money, error) {
+	if amount > math.MaxInt64-mon.Stock-mon.CashBox {
+		return mon, errors.New(mon.Name + "'s stock is exceeded")
+	}
+
+	toStock := amount
+	if mon.NonRecyclable {
+		toStock = 0
+	} else if mon.Capacity > 0 && amount > mon.Capacity-mon.Stock {
+		toStock = mon.Capacity - mon.Stock
+		if toStock < 0 {
+			toStock = 0
+		}
+	}
+
+	mon.Stock = mon.Stock + toStock
+	mon.CashBox = mon.CashBox + amount - toStock
+	return mon, nil
+}
+
+//Bank - money's stock owned by a single machine, safe for concurrent use through its methods
+type Bank struct {
+	Money []Money
+
+	mu sync.RWMutex
+}
+
+//NewBank - create bank from a copy of moneyList sorted descending by value
+func NewBank(moneyList []Money) *Bank {
+	bankMoney := make([]Money, len(moneyList))
+	copy(bankMoney, moneyList)
+
+	//sort descending to prioritize the change from the most valuable amount to the lowest
+	sort.Slice(bankMoney, func(i, j int) bool {
+		return bankMoney[i].Value > bankMoney[j].Value
+	})
+
+	return &Bank{Money: bankMoney}
+}
+
+//globalBank - the only bank of the global MoneyStock, so every use of it is synchronized
+var globalBank = &Bank{}
+
+//DefaultBank - bank that operates on the global MoneyStock
+func DefaultBank() *Bank {
+	globalBank.mu.Lock()
+	defer globalBank.mu.Unlock()
+	globalBank.Money = MoneyStock
+	return globalBank
+}
+
+//List - copy of every money, changing it doesn't change the bank
+func (b *Bank) List() []Money {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	moneyList := make([]Money, len(b.Money))
+	copy(moneyList, b.Money)
+	return moneyList
+}
+
+//Replace - replace every money with moneyList, in place if the number of money is the same
+//so stock shared with the global MoneyStock stays shared
+func (b *Bank) Replace(moneyList []Money) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.Money) != len(moneyList) {
+		b.Money = make([]Money, len(moneyList))
+	}
+	copy(b.Money, moneyList)
+}
+
+func ListAvailableMoney(output io.Writer) {
+	DefaultBank().ListAvailableMoney(output)
+}
+
+func (b *Bank) ListAvailableMoney(output io.Writer) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
 	}
-	fmt.Println("-----------------------------------")
+
+	fmt.Fprintln(output, "List of money")
+	fmt.Fprintln(output, "MoneyType   Name        Value       Stock       Capacity    CashBox")
+	fmt.Fprintln(output, "------------------------------------------------------------------")
+	for _, money := range b.List() {
+		//stock is the coin tube or banknote recycler that change is made from
+		capacity := "-"
+		if money.Capacity > 0 {
+			capacity = strconv.FormatInt(money.Capacity, 10)
+		}
+		fmt.Fprintf(output, "%-12v%-12v%-12v%-12v%-12v%-12v\n", money.MoneyType, money.Name, money.Value, money.Stock, capacity, money.CashBox)
+	}
+	fmt.Fprintln(output, "-----------------------------------")
 }
 
 //CheckMoney - for validate money is existed in stock or not
 func CheckMoney(moneyName string) (Money, error) {
-	for _, availMoney := range MoneyStock {
+	return DefaultBank().CheckMoney(moneyName)
+}
+
+//CheckMoney - for validate money is existed in bank's stock or not
+func (b *Bank) CheckMoney(moneyName string) (Money, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, availMoney := range b.Money {
 		if moneyName == availMoney.Name {
 			return availMoney, nil
 		}
@@ -68,31 +186,104 @@ func CheckMoney(moneyName string) (Money, error) {
 }
 
 //IncreaseStock - increase global money's stock from receivedMoney map (money received from user)
-func IncreaseStock(receivedMoney map[Money]int8) error {
+func IncreaseStock(receivedMoney map[Money]int64) error {
+	return DefaultBank().IncreaseStock(receivedMoney)
+}
+
+//IncreaseStock - increase bank's stock from receivedMoney map (money received from user),
+//non-recyclable money and money beyond capacity go to cash box instead,
+//every money is validated before any stock is increased, so stock is never partly increased
+func (b *Bank) IncreaseStock(receivedMoney map[Money]int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	increasedMoney := make([]Money, len(b.Money))
+	copy(increasedMoney, b.Money)
+
 	for recMoney, amount := range receivedMoney {
-		for i, availMoney := range MoneyStock {
+		for i, availMoney := range increasedMoney {
 			if recMoney.Name == availMoney.Name {
-				MoneyStock[i].Stock = MoneyStock[i].Stock + int64(amount)
+				deposited, err := availMoney.Deposit(amount)
+				if err != nil {
+					return err
+				}
+				increasedMoney[i] = deposited
 				break
 			}
 		}
 	}
+
+	copy(b.Money, increasedMoney)
 	return nil
 }
 
 //DecreaseStock - decrease global money's stock from changeList (money thaT changed to user)
 func DecreaseStock(changeList []Money) error {
+	return DefaultBank().DecreaseStock(changeList)
+}
+
+//DecreaseStock - decrease bank's stock from changeList (money that changed to user)
+//every money is validated before any stock is decreased, so stock is never partly decreased
+func (b *Bank) DecreaseStock(changeList []Money) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	decreasedMoney := make([]Money, len(b.Money))
+	copy(decreasedMoney, b.Money)
+
 	for _, change := range changeList {
-		for i, availMoney := range MoneyStock {
+		for i, availMoney := range decreasedMoney {
 			if change.Name == availMoney.Name {
-				if MoneyStock[i].Stock-1 < 0 {
+				if decreasedMoney[i].Stock-1 < 0 {
 					return errors.New(availMoney.Name + "'s stock is less than zero")
 				}
-				MoneyStock[i].Stock = MoneyStock[i].Stock - 1
+				decreasedMoney[i].Stock = decreasedMoney[i].Stock - 1
 				break
 			}
 		}
 	}
 
+	copy(b.Money, decreasedMoney)
 	return nil
 }
+
+//Restock - add amount to the stock of money named moneyName
+func (b *Bank) Restock(moneyName string, amount int64) error {
+	if amount <= 0 {
+		return errors.New("restock amount must be greater than zero")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, availMoney := range b.Money {
+		if moneyName == availMoney.Name {
+			if availMoney.NonRecyclable {
+				return errors.New(availMoney.Name + " can't be used for change")
+			}
+			if availMoney.Capacity > 0 && amount > availMoney.Capacity-availMoney.Stock {
+				return errors.New(availMoney.Name + "'s capacity is exceeded")
+			}
+			if amount > math.MaxInt64-availMoney.Stock {
+				return errors.New(availMoney.Name + "'s stock is exceeded")
+			}
+			b.Money[i].Stock = b.Money[i].Stock + amount
+			return nil
+		}
+	}
+	return errors.New("money doesn't excepted")
+}
+
+//EmptyCashBox - take every piece out of the cash box, return money that was in it with CashBox as pieces taken
+func (b *Bank) EmptyCashBox() []Money {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	emptied := []Money{}
+	for i, availMoney := range b.Money {
+		if availMoney.CashBox > 0 {
+			emptied = append(emptied, availMoney)
+			b.Money[i].CashBox = 0
+		}
+	}
+	return emptied
+}
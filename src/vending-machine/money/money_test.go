@@ -1,7 +1,11 @@
 package money
 
 import (
+	"bytes"
 	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,13 +31,13 @@ func Test_checkMoney(t *testing.T) {
 					{
 						MoneyType: COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     Baht(5),
 						Stock:     10,
 					},
 				}
@@ -44,7 +48,7 @@ func Test_checkMoney(t *testing.T) {
 			expected: Money{
 				MoneyType: COIN,
 				Name:      "1",
-				Value:     1,
+				Value:     Baht(1),
 				Stock:     10,
 			},
 			hasError: false,
@@ -56,13 +60,13 @@ func Test_checkMoney(t *testing.T) {
 					{
 						MoneyType: COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     Baht(5),
 						Stock:     10,
 					},
 				}
@@ -95,7 +99,7 @@ func Test_IncreaseStock(t *testing.T) {
 	tests := []struct {
 		description string
 		prepData    func()
-		input       map[Money]int8
+		input       map[Money]int64
 		expected    []Money
 		hasError    bool
 	}{
@@ -106,30 +110,30 @@ func Test_IncreaseStock(t *testing.T) {
 					{
 						MoneyType: COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     Baht(1),
 						Stock:     0,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     Baht(5),
 						Stock:     0,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     Baht(10),
 						Stock:     0,
 					},
 					{
 						MoneyType: BANK,
 						Name:      "20",
-						Value:     20,
+						Value:     Baht(20),
 						Stock:     10,
 					},
 				}
 			},
-			input: map[Money]int8{
+			input: map[Money]int64{
 				{
 					Name: "1",
 				}: 1,
@@ -144,30 +148,184 @@ func Test_IncreaseStock(t *testing.T) {
 				{
 					MoneyType: COIN,
 					Name:      "1",
-					Value:     1,
+					Value:     Baht(1),
 					Stock:     1,
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
 					Stock:     2,
 				},
 				{
 					MoneyType: COIN,
 					Name:      "10",
-					Value:     10,
+					Value:     Baht(10),
 					Stock:     3,
 				},
 				{
 					MoneyType: BANK,
 					Name:      "20",
-					Value:     20,
+					Value:     Baht(20),
 					Stock:     10,
 				},
 			},
 			hasError: false,
 		},
+		{
+			description: "test_increse_stock_success_coin_tube_overflows_to_cash_box",
+			prepData: func() {
+				MoneyStock = []Money{
+					{
+						MoneyType: COIN,
+						Name:      "5",
+						Value:     Baht(5),
+						Stock:     79,
+						Capacity:  80,
+					},
+					{
+						MoneyType: COIN,
+						Name:      "1",
+						Value:     Baht(1),
+						Stock:     100,
+						Capacity:  100,
+						CashBox:   7,
+					},
+				}
+			},
+			input: map[Money]int64{
+				{
+					Name: "5",
+				}: 3,
+				{
+					Name: "1",
+				}: 1,
+			},
+			expected: []Money{
+				{
+					MoneyType: COIN,
+					Name:      "5",
+					Value:     Baht(5),
+					Stock:     80,
+					Capacity:  80,
+					CashBox:   2,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "1",
+					Value:     Baht(1),
+					Stock:     100,
+					Capacity:  100,
+					CashBox:   8,
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_increse_stock_success_to_cash_box",
+			prepData: func() {
+				MoneyStock = []Money{
+					{
+						MoneyType: COIN,
+						Name:      "10",
+						Value:     Baht(10),
+						Stock:     0,
+					},
+					{
+						MoneyType: BANK,
+						Name:      "100",
+						Value:     Baht(100),
+						Stock:     9,
+						Capacity:  10,
+					},
+					{
+						MoneyType:     BANK,
+						Name:          "1000",
+						Value:         Baht(1000),
+						NonRecyclable: true,
+					},
+				}
+			},
+			input: map[Money]int64{
+				{
+					Name: "10",
+				}: 1,
+				{
+					Name: "100",
+				}: 3,
+				{
+					Name: "1000",
+				}: 2,
+			},
+			expected: []Money{
+				{
+					MoneyType: COIN,
+					Name:      "10",
+					Value:     Baht(10),
+					Stock:     1,
+				},
+				{
+					MoneyType: BANK,
+					Name:      "100",
+					Value:     Baht(100),
+					Stock:     10,
+					Capacity:  10,
+					CashBox:   2,
+				},
+				{
+					MoneyType:     BANK,
+					Name:          "1000",
+					Value:         Baht(1000),
+					NonRecyclable: true,
+					CashBox:       2,
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_increse_stock_failed_stock_is_exceeded",
+			prepData: func() {
+				MoneyStock = []Money{
+					{
+						MoneyType: COIN,
+						Name:      "5",
+						Value:     Baht(5),
+						Stock:     1,
+					},
+					{
+						MoneyType: COIN,
+						Name:      "10",
+						Value:     Baht(10),
+						Stock:     10,
+						CashBox:   2,
+					},
+				}
+			},
+			input: map[Money]int64{
+				{
+					Name: "5",
+				}: 1,
+				{
+					Name: "10",
+				}: math.MaxInt64 - 11,
+			},
+			expected: []Money{
+				{
+					MoneyType: COIN,
+					Name:      "5",
+					Value:     Baht(5),
+					Stock:     1,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "10",
+					Value:     Baht(10),
+					Stock:     10,
+					CashBox:   2,
+				},
+			},
+			hasError: true,
+		},
 	}
 
 	for _, test := range tests {
@@ -178,8 +336,8 @@ func Test_IncreaseStock(t *testing.T) {
 				assert.Error(t, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, test.expected, MoneyStock)
 			}
+			assert.Equal(t, test.expected, MoneyStock)
 		})
 	}
 }
@@ -200,25 +358,25 @@ func Test_DecreaseStock(t *testing.T) {
 					{
 						MoneyType: COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     Baht(1),
 						Stock:     1,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     Baht(5),
 						Stock:     3,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     Baht(10),
 						Stock:     5,
 					},
 					{
 						MoneyType: BANK,
 						Name:      "20",
-						Value:     20,
+						Value:     Baht(20),
 						Stock:     10,
 					},
 				}
@@ -227,57 +385,57 @@ func Test_DecreaseStock(t *testing.T) {
 				{
 					MoneyType: COIN,
 					Name:      "1",
-					Value:     1,
+					Value:     Baht(1),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "10",
-					Value:     10,
+					Value:     Baht(10),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "10",
-					Value:     10,
+					Value:     Baht(10),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "10",
-					Value:     10,
+					Value:     Baht(10),
 				},
 			},
 			expected: []Money{
 				{
 					MoneyType: COIN,
 					Name:      "1",
-					Value:     1,
+					Value:     Baht(1),
 					Stock:     0,
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
 					Stock:     1,
 				},
 				{
 					MoneyType: COIN,
 					Name:      "10",
-					Value:     10,
+					Value:     Baht(10),
 					Stock:     2,
 				},
 				{
 					MoneyType: BANK,
 					Name:      "20",
-					Value:     20,
+					Value:     Baht(20),
 					Stock:     10,
 				},
 			},
@@ -290,19 +448,19 @@ func Test_DecreaseStock(t *testing.T) {
 					{
 						MoneyType: COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     Baht(1),
 						Stock:     1,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     Baht(5),
 						Stock:     1,
 					},
 					{
 						MoneyType: COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     Baht(10),
 						Stock:     5,
 					},
 				}
@@ -311,17 +469,37 @@ func Test_DecreaseStock(t *testing.T) {
 				{
 					MoneyType: COIN,
 					Name:      "1",
-					Value:     1,
+					Value:     Baht(1),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
 				},
 				{
 					MoneyType: COIN,
 					Name:      "5",
-					Value:     5,
+					Value:     Baht(5),
+				},
+			},
+			expected: []Money{
+				{
+					MoneyType: COIN,
+					Name:      "1",
+					Value:     Baht(1),
+					Stock:     1,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "5",
+					Value:     Baht(5),
+					Stock:     1,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "10",
+					Value:     Baht(10),
+					Stock:     5,
 				},
 			},
 			expectedError: errors.New("5's stock is less than zero"),
@@ -336,6 +514,7 @@ func Test_DecreaseStock(t *testing.T) {
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expectedError.Error(), err.Error())
+				assert.Equal(t, test.expected, MoneyStock)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, test.expected, MoneyStock)
@@ -343,3 +522,281 @@ func Test_DecreaseStock(t *testing.T) {
 		})
 	}
 }
+
+func Test_Bank_Restock(t *testing.T) {
+	type inputArgs struct {
+		moneyName string
+		amount    int64
+	}
+
+	tests := []struct {
+		description   string
+		input         inputArgs
+		expected      []Money
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_restock_success",
+			input: inputArgs{
+				moneyName: "5",
+				amount:    3,
+			},
+			expected: []Money{
+				{
+					MoneyType: COIN,
+					Name:      "10",
+					Value:     Baht(10),
+					Stock:     1,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "5",
+					Value:     Baht(5),
+					Stock:     4,
+				},
+				{
+					MoneyType: BANK,
+					Name:      "100",
+					Value:     Baht(100),
+					Stock:     9,
+					Capacity:  10,
+				},
+				{
+					MoneyType:     BANK,
+					Name:          "1000",
+					Value:         Baht(1000),
+					NonRecyclable: true,
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_restock_failed_money_does_not_accepted",
+			input: inputArgs{
+				moneyName: "20",
+				amount:    3,
+			},
+			expectedError: errors.New("money doesn't excepted"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_failed_capacity_is_exceeded",
+			input: inputArgs{
+				moneyName: "100",
+				amount:    2,
+			},
+			expectedError: errors.New("100's capacity is exceeded"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_failed_capacity_is_exceeded_without_overflow",
+			input: inputArgs{
+				moneyName: "100",
+				amount:    math.MaxInt64,
+			},
+			expectedError: errors.New("100's capacity is exceeded"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_failed_stock_is_exceeded",
+			input: inputArgs{
+				moneyName: "5",
+				amount:    math.MaxInt64,
+			},
+			expectedError: errors.New("5's stock is exceeded"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_failed_non_recyclable",
+			input: inputArgs{
+				moneyName: "1000",
+				amount:    1,
+			},
+			expectedError: errors.New("1000 can't be used for change"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_failed_amount_is_not_positive",
+			input: inputArgs{
+				moneyName: "5",
+				amount:    0,
+			},
+			expectedError: errors.New("restock amount must be greater than zero"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			bank := NewBank([]Money{
+				{
+					MoneyType: COIN,
+					Name:      "5",
+					Value:     Baht(5),
+					Stock:     1,
+				},
+				{
+					MoneyType: COIN,
+					Name:      "10",
+					Value:     Baht(10),
+					Stock:     1,
+				},
+			})
+			bank.Money = append(bank.Money, Money{MoneyType: BANK, Name: "100", Value: Baht(100), Stock: 9, Capacity: 10}, Money{MoneyType: BANK, Name: "1000", Value: Baht(1000), NonRecyclable: true})
+			err := bank.Restock(test.input.moneyName, test.input.amount)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, bank.Money)
+			}
+		})
+	}
+}
+
+func Test_Bank_EmptyCashBox(t *testing.T) {
+	bank := NewBank([]Money{
+		{
+			MoneyType: COIN,
+			Name:      "10",
+			Value:     Baht(10),
+			Stock:     60,
+			Capacity:  60,
+			CashBox:   3,
+		},
+		{
+			MoneyType: COIN,
+			Name:      "5",
+			Value:     Baht(5),
+			Stock:     1,
+		},
+		{
+			MoneyType:     BANK,
+			Name:          "1000",
+			Value:         Baht(1000),
+			NonRecyclable: true,
+			CashBox:       2,
+		},
+	})
+
+	emptied := bank.EmptyCashBox()
+	assert.Equal(t, []Money{
+		{MoneyType: BANK, Name: "1000", Value: Baht(1000), NonRecyclable: true, CashBox: 2},
+		{MoneyType: COIN, Name: "10", Value: Baht(10), Stock: 60, Capacity: 60, CashBox: 3},
+	}, emptied)
+	assert.Equal(t, []Money{
+		{MoneyType: BANK, Name: "1000", Value: Baht(1000), NonRecyclable: true},
+		{MoneyType: COIN, Name: "10", Value: Baht(10), Stock: 60, Capacity: 60},
+		{MoneyType: COIN, Name: "5", Value: Baht(5), Stock: 1},
+	}, bank.Money)
+
+	assert.Equal(t, []Money{}, bank.EmptyCashBox())
+}
+
+func Test_NewBank_does_not_share_stock(t *testing.T) {
+	moneyList := []Money{
+		{
+			MoneyType: COIN,
+			Name:      "1",
+			Value:     Baht(1),
+			Stock:     1,
+		},
+	}
+	first := NewBank(moneyList)
+	second := NewBank(moneyList)
+
+	err := first.DecreaseStock([]Money{{Name: "1"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(0), first.Money[0].Stock)
+	assert.Equal(t, int64(1), second.Money[0].Stock)
+	assert.Equal(t, int64(1), moneyList[0].Stock)
+}
+
+func Test_ListAvailableMoney(t *testing.T) {
+	output := &bytes.Buffer{}
+	NewBank([]Money{
+		{
+			MoneyType: COIN,
+			Name:      "1",
+			Value:     Baht(1),
+			Stock:     2,
+		},
+		{
+			MoneyType: COIN,
+			Name:      "10",
+			Value:     Baht(10),
+			Stock:     3,
+			Capacity:  3,
+			CashBox:   4,
+		},
+	}).ListAvailableMoney(output)
+
+	assert.Equal(t, "List of money\n"+
+		"MoneyType   Name        Value       Stock       Capacity    CashBox\n"+
+		"------------------------------------------------------------------\n"+
+		"coin        10          10          3           3           4           \n"+
+		"coin        1           1           2           -           0           \n"+
+		"-----------------------------------\n", output.String())
+}
+
+func Test_Bank_concurrent_use(t *testing.T) {
+	const workers = 50
+	const rounds = 100
+	b := NewBank([]Money{
+		{MoneyType: COIN, Name: "10", Value: Baht(10), Stock: 0, Capacity: 1000},
+		{MoneyType: COIN, Name: "5", Value: Baht(5), Stock: 1000},
+	})
+
+	//every worker receives a 10 THB coin and changes a 5 THB coin while it lasts,
+	//while others empty the cash box and list the stock
+	var changed, emptied int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				assert.NoError(t, b.IncreaseStock(map[Money]int64{{Name: "10"}: 1}))
+				err := b.DecreaseStock([]Money{{Name: "5"}})
+				if err == nil {
+					atomic.AddInt64(&changed, 1)
+				}
+				if i%10 == 0 {
+					for _, mon := range b.EmptyCashBox() {
+						atomic.AddInt64(&emptied, mon.CashBox)
+					}
+				}
+				b.ListAvailableMoney(&bytes.Buffer{})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	//every piece received is in stock, in cash box or emptied from it, and every piece changed is out of stock
+	moneyList := b.List()
+	assert.Equal(t, int64(workers*rounds), moneyList[0].Stock+moneyList[0].CashBox+emptied)
+	assert.Equal(t, int64(1000), moneyList[0].Stock)
+	assert.Equal(t, int64(1000), changed)
+	assert.Equal(t, int64(0), moneyList[1].Stock)
+}
+
+func Test_IncreaseStock_concurrent_use(t *testing.T) {
+	MoneyStock = []Money{{MoneyType: COIN, Name: "10", Value: Baht(10), Stock: 0}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, IncreaseStock(map[Money]int64{{Name: "10"}: 1}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(200), MoneyStock[0].Stock)
+}
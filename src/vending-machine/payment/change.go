@@ -0,0 +1,341 @@
+package payment
+
+import (
+	"errors"
+	"sort"
+	"vending-machine/money"
+)
+
+const (
+	GREEDY             = "greedy"
+	FEWEST_COINS       = "fewest-coins"
+	PRESERVE_SCARCE    = "preserve-scarce"
+	FULLEST_TUBE_FIRST = "fullest-tube-first"
+	PREFER_COINS       = "prefer-coins"
+)
+
+//ChangeMaker - policy that decides which money from the stock is used to change changeAmount to the user
+//availableMoney is a snapshot of the money's stock and receivedMoney is money received from user that can be used too,
+//implementations must not change availableMoney
+type ChangeMaker interface {
+	MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error)
+}
+
+//NewChangeMaker - create change maker for strategy name
+func NewChangeMaker(strategy string) (ChangeMaker, error) {
+	switch strategy {
+	case GREEDY:
+		return GreedyChangeMaker{}, nil
+	case FEWEST_COINS:
+		return FewestCoinsChangeMaker{}, nil
+	case PRESERVE_SCARCE:
+		return PreserveScarceChangeMaker{}, nil
+	case FULLEST_TUBE_FIRST:
+		return FullestTubeFirstChangeMaker{}, nil
+	case PREFER_COINS:
+		return PreferCoinsChangeMaker{}, nil
+	}
+	return nil, errors.New("change strategy doesn't exist")
+}
+
+//GreedyChangeMaker - change from the most valuable money to the lowest, availableMoney must be sorted descending
+type GreedyChangeMaker struct{}
+
+func (GreedyChangeMaker) MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	return change(changeAmount, availableMoney, receivedMoney)
+}
+
+//FewestCoinsChangeMaker - change with the fewest pieces of money
+type FewestCoinsChangeMaker struct{}
+
+func (FewestCoinsChangeMaker) MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	return optimalChange(changeAmount, availableMoney, receivedMoney)
+}
+
+//PreserveScarceChangeMaker - change with as little as possible of the money that has low stock
+type PreserveScarceChangeMaker struct{}
+
+func (PreserveScarceChangeMaker) MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	tmpAvailableMoney, err := addReceivedMoney(availableMoney, receivedMoney)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxStock int64
+	for _, tmpAvailMoney := range tmpAvailableMoney {
+		if tmpAvailMoney.Stock > maxStock {
+			maxStock = tmpAvailMoney.Stock
+		}
+	}
+
+	//a piece of money costs more the scarcer it is, so the fullest money costs 2 and scarce money costs up to maxStock+1
+	return weightedChange(changeAmount, tmpAvailableMoney, func(availMoney money.Money) int64 {
+		return 1 + maxStock/availMoney.Stock
+	})
+}
+
+//PreferCoinsChangeMaker - change with as few banknotes as possible, then with the fewest coins
+type PreferCoinsChangeMaker struct{}
+
+func (PreferCoinsChangeMaker) MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	tmpAvailableMoney, err := addReceivedMoney(availableMoney, receivedMoney)
+	if err != nil {
+		return nil, err
+	}
+
+	//a banknote costs more than any set of coins could, since there are at most changeAmount coins in the change
+	return weightedChange(changeAmount, tmpAvailableMoney, func(availMoney money.Money) int64 {
+		if availMoney.MoneyType == money.BANK {
+			return int64(changeAmount) + 1
+		}
+		return 1
+	})
+}
+
+//FullestTubeFirstChangeMaker - change with as much as possible of the money that has the highest stock,
+//then the next highest and so on
+type FullestTubeFirstChangeMaker struct{}
+
+func (FullestTubeFirstChangeMaker) MakeChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	tmpAvailableMoney, err := addReceivedMoney(availableMoney, receivedMoney)
+	if err != nil {
+		return nil, err
+	}
+
+	//no change
+	if changeAmount == 0 {
+		return []money.Money{}, nil
+	}
+	if changeAmount < 0 {
+		return []money.Money{}, errors.New("insufficient change")
+	}
+
+	//order money from the fullest to the emptiest, the most valuable first when stock is equal
+	order := make([]int, len(tmpAvailableMoney))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if tmpAvailableMoney[order[i]].Stock != tmpAvailableMoney[order[j]].Stock {
+			return tmpAvailableMoney[order[i]].Stock > tmpAvailableMoney[order[j]].Stock
+		}
+		return tmpAvailableMoney[order[i]].Value > tmpAvailableMoney[order[j]].Value
+	})
+
+	orderedMoney := make([]money.Money, len(order))
+	for i, index := range order {
+		orderedMoney[i] = tmpAvailableMoney[index]
+	}
+
+	//take as many pieces from each money as possible while the remaining amount can still be changed by the rest
+	usedCount := make([]int64, len(orderedMoney))
+	remainingAmount := changeAmount
+	for i, orderedMon := range orderedMoney {
+		if orderedMon.Value <= 0 {
+			continue
+		}
+		used := orderedMon.Stock
+		if int64(remainingAmount/orderedMon.Value) < used {
+			used = int64(remainingAmount / orderedMon.Value)
+		}
+		for ; used >= 0; used-- {
+			if canChange(remainingAmount-orderedMon.Value.Times(used), orderedMoney[i+1:]) {
+				break
+			}
+		}
+		if used < 0 {
+			return []money.Money{}, errors.New("insufficient change")
+		}
+		usedCount[i] = used
+		remainingAmount = remainingAmount - orderedMon.Value.Times(used)
+	}
+
+	return toChangeList(orderedMoney, usedCount), nil
+}
+
+//optimalChange - change the remaining money to the user with the fewest pieces of money
+//it has the same input and output as change, but it always finds a combination
+//when one exists within the money's stock, even with non-canonical values (ex. 2, 20, 50)
+func optimalChange(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	tmpAvailableMoney, err := addReceivedMoney(availableMoney, receivedMoney)
+	if err != nil {
+		return nil, err
+	}
+
+	return weightedChange(changeAmount, tmpAvailableMoney, func(money.Money) int64 {
+		return 1
+	})
+}
+
+//addReceivedMoney - create tmpAvailableMoney from receiving money's stock plus money received from user
+//because we'll change the real stock when everything is success,
+//received money that goes to cash box (non-recyclable or beyond capacity) can't be used for change
+func addReceivedMoney(availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	tmpAvailableMoney := make([]money.Money, len(availableMoney))
+	copy(tmpAvailableMoney, availableMoney)
+
+	for i, tmpAvailMoney := range tmpAvailableMoney {
+		var amount int64
+		for recMoney, recAmount := range receivedMoney {
+			if recMoney.Name == tmpAvailMoney.Name {
+				amount = amount + recAmount
+			}
+		}
+		deposited, err := tmpAvailMoney.Deposit(amount)
+		if err != nil {
+			return nil, err
+		}
+		tmpAvailableMoney[i] = deposited
+	}
+
+	return tmpAvailableMoney, nil
+}
+
+//weightedChange - change changeAmount from tmpAvailableMoney's stock with the lowest total weight of pieces of money
+func weightedChange(changeAmount money.Amount, tmpAvailableMoney []money.Money, weight func(money.Money) int64) ([]money.Money, error) {
+
+	//no change
+	if changeAmount == 0 {
+		return []money.Money{}, nil
+	}
+	if changeAmount < 0 {
+		return []money.Money{}, errors.New("insufficient change")
+	}
+
+	//amounts are counted in units of the greatest common divisor of the values, so whole baht doesn't need a table of satang
+	unit := changeUnit(changeAmount, tmpAvailableMoney)
+	changeUnits := int64(changeAmount / unit)
+
+	//lowestCost[units] is the lowest total weight of pieces of money that sum to units using the money processed so far,
+	//-1 when units can't be made
+	lowestCost := make([]int64, changeUnits+1)
+	for units := range lowestCost {
+		lowestCost[units] = -1
+	}
+	lowestCost[0] = 0
+
+	//usedPieces[i][units] is how many pieces of tmpAvailableMoney[i] are used for the best way to make units
+	usedPieces := make([][]int64, len(tmpAvailableMoney))
+
+	for i, availMoney := range tmpAvailableMoney {
+		usedPieces[i] = make([]int64, changeUnits+1)
+		if availMoney.Value <= 0 || availMoney.Stock <= 0 {
+			continue
+		}
+		pieceCost := weight(availMoney)
+		valueUnits := int64(availMoney.Value / unit)
+
+		nextLowestCost := make([]int64, changeUnits+1)
+		for units := range nextLowestCost {
+			nextLowestCost[units] = -1
+		}
+
+		for units, cost := range lowestCost {
+			if cost < 0 {
+				continue
+			}
+			for used := int64(0); used <= availMoney.Stock; used++ {
+				target := int64(units) + used*valueUnits
+				if target > changeUnits {
+					break
+				}
+				if nextLowestCost[target] < 0 || cost+used*pieceCost < nextLowestCost[target] {
+					nextLowestCost[target] = cost + used*pieceCost
+					usedPieces[i][target] = used
+				}
+			}
+		}
+		lowestCost = nextLowestCost
+	}
+
+	//if there is no combination that meet criteria
+	if lowestCost[changeUnits] < 0 {
+		return []money.Money{}, errors.New("insufficient change")
+	}
+
+	//walk back through usedPieces to find how many pieces of each money are used
+	usedCount := make([]int64, len(tmpAvailableMoney))
+	remainingUnits := changeUnits
+	for i := len(tmpAvailableMoney) - 1; i >= 0; i-- {
+		usedCount[i] = usedPieces[i][remainingUnits]
+		remainingUnits = remainingUnits - usedCount[i]*int64(tmpAvailableMoney[i].Value/unit)
+	}
+
+	return toChangeList(tmpAvailableMoney, usedCount), nil
+}
+
+//canChange - check changeAmount can be made from tmpAvailableMoney's stock
+func canChange(changeAmount money.Amount, tmpAvailableMoney []money.Money) bool {
+	if changeAmount == 0 {
+		return true
+	}
+	if changeAmount < 0 {
+		return false
+	}
+
+	unit := changeUnit(changeAmount, tmpAvailableMoney)
+	changeUnits := int64(changeAmount / unit)
+
+	reachable := make([]bool, changeUnits+1)
+	reachable[0] = true
+	for _, availMoney := range tmpAvailableMoney {
+		if availMoney.Value <= 0 || availMoney.Stock <= 0 {
+			continue
+		}
+		valueUnits := int64(availMoney.Value / unit)
+		for units := changeUnits; units >= 0; units-- {
+			if !reachable[units] {
+				continue
+			}
+			for used := int64(1); used <= availMoney.Stock; used++ {
+				target := units + used*valueUnits
+				if target > changeUnits {
+					break
+				}
+				reachable[target] = true
+			}
+		}
+	}
+
+	return reachable[changeUnits]
+}
+
+//changeUnit - the greatest common divisor of changeAmount and the values of money in stock, changeAmount must be positive
+//every amount that can be made from the stock is a multiple of it
+func changeUnit(changeAmount money.Amount, tmpAvailableMoney []money.Money) money.Amount {
+	unit := changeAmount
+	for _, availMoney := range tmpAvailableMoney {
+		if availMoney.Value <= 0 || availMoney.Stock <= 0 {
+			continue
+		}
+		value := availMoney.Value
+		for value != 0 {
+			unit, value = value, unit%value
+		}
+	}
+	return unit
+}
+
+//toChangeList - list usedCount[i] pieces of tmpAvailableMoney[i] from the lowest value to the highest, same order as change
+func toChangeList(tmpAvailableMoney []money.Money, usedCount []int64) []money.Money {
+	order := make([]int, len(tmpAvailableMoney))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return tmpAvailableMoney[order[i]].Value < tmpAvailableMoney[order[j]].Value
+	})
+
+	changeList := []money.Money{}
+	for _, i := range order {
+		for used := int64(0); used < usedCount[i]; used++ {
+			changeList = append(changeList, money.Money{
+				MoneyType: tmpAvailableMoney[i].MoneyType,
+				Name:      tmpAvailableMoney[i].Name,
+				Value:     tmpAvailableMoney[i].Value,
+			})
+		}
+	}
+
+	return changeList
+}
@@ -0,0 +1,638 @@
+package payment
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"testing/quick"
+	"vending-machine/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_optimalChange(t *testing.T) {
+	type inputArgs struct {
+		changeAmount   money.Amount
+		availableMoney []money.Money
+		recievedMoney  map[money.Money]int64
+	}
+
+	type expectedArgs struct {
+		expectedMoney []money.Money
+		expectedError error
+	}
+
+	tests := []struct {
+		description string
+		input       inputArgs
+		expected    expectedArgs
+		hasError    bool
+	}{
+		{
+			description: "test_optimal_change_success_where_greedy_fails",
+			input: inputArgs{
+				changeAmount: money.Baht(60),
+				availableMoney: []money.Money{
+					{
+						MoneyType: money.BANK,
+						Name:      "50",
+						Value:     money.Baht(50),
+						Stock:     1,
+					},
+					{
+						MoneyType: money.BANK,
+						Name:      "20",
+						Value:     money.Baht(20),
+						Stock:     3,
+					},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: expectedArgs{
+				expectedMoney: []money.Money{
+					{
+						MoneyType: money.BANK,
+						Name:      "20",
+						Value:     money.Baht(20),
+					},
+					{
+						MoneyType: money.BANK,
+						Name:      "20",
+						Value:     money.Baht(20),
+					},
+					{
+						MoneyType: money.BANK,
+						Name:      "20",
+						Value:     money.Baht(20),
+					},
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_optimal_change_success_with_fewest_coins",
+			input: inputArgs{
+				changeAmount: money.Baht(6),
+				availableMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "5",
+						Value:     money.Baht(5),
+						Stock:     1,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "2",
+						Value:     money.Baht(2),
+						Stock:     10,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+						Stock:     10,
+					},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: expectedArgs{
+				expectedMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "5",
+						Value:     money.Baht(5),
+					},
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_optimal_change_success_with_recieve_money",
+			input: inputArgs{
+				changeAmount: money.Baht(8),
+				availableMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "10",
+						Value:     money.Baht(10),
+						Stock:     10,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "5",
+						Value:     money.Baht(5),
+						Stock:     10,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+						Stock:     0,
+					},
+				},
+				recievedMoney: map[money.Money]int64{
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+					}: 3,
+				},
+			},
+			expected: expectedArgs{
+				expectedMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "5",
+						Value:     money.Baht(5),
+					},
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_optimal_change_success_with_change_amount_equal_to_zero",
+			input: inputArgs{
+				changeAmount: money.Baht(0),
+				availableMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+						Stock:     10,
+					},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: expectedArgs{
+				expectedMoney: []money.Money{},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_optimal_change_failed_insufficient_change",
+			input: inputArgs{
+				changeAmount: money.Baht(8),
+				availableMoney: []money.Money{
+					{
+						MoneyType: money.COIN,
+						Name:      "10",
+						Value:     money.Baht(10),
+						Stock:     10,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "5",
+						Value:     money.Baht(5),
+						Stock:     10,
+					},
+					{
+						MoneyType: money.COIN,
+						Name:      "1",
+						Value:     money.Baht(1),
+						Stock:     0,
+					},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: expectedArgs{
+				expectedMoney: []money.Money{},
+				expectedError: errors.New("insufficient change"),
+			},
+			hasError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			availableMoney := make([]money.Money, len(test.input.availableMoney))
+			copy(availableMoney, test.input.availableMoney)
+
+			output, err := optimalChange(test.input.changeAmount, test.input.availableMoney, test.input.recievedMoney)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expected.expectedError, err)
+				assert.Equal(t, test.expected.expectedMoney, output)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected.expectedMoney, output)
+			}
+
+			//money's stock snapshot must not be changed
+			assert.Equal(t, availableMoney, test.input.availableMoney)
+		})
+	}
+}
+
+//bruteForceFewestPieces - try every combination of money's stock and return the fewest pieces that sum to changeAmount,
+//-1 when there is no combination
+func bruteForceFewestPieces(changeAmount money.Amount, availableMoney []money.Money) int64 {
+	if len(availableMoney) == 0 {
+		if changeAmount == 0 {
+			return 0
+		}
+		return -1
+	}
+
+	fewestPieces := int64(-1)
+	current := availableMoney[0]
+	for used := int64(0); used <= current.Stock && current.Value.Times(used) <= changeAmount; used++ {
+		otherPieces := bruteForceFewestPieces(changeAmount-current.Value.Times(used), availableMoney[1:])
+		if otherPieces < 0 {
+			continue
+		}
+		if fewestPieces < 0 || otherPieces+used < fewestPieces {
+			fewestPieces = otherPieces + used
+		}
+	}
+	return fewestPieces
+}
+
+func Test_optimalChange_matches_brute_force(t *testing.T) {
+	values := []int64{50, 20, 10, 5, 2, 1}
+
+	property := func(stocks [6]uint8, received [6]uint8, amount uint8) bool {
+		availableMoney := []money.Money{}
+		receivedMoney := map[money.Money]int64{}
+		totalMoney := []money.Money{}
+		for i, value := range values {
+			name := strconv.FormatInt(value, 10)
+			availMoney := money.Money{
+				MoneyType: money.COIN,
+				Name:      name,
+				Value:     money.Baht(value),
+				Stock:     int64(stocks[i] % 4),
+			}
+			availableMoney = append(availableMoney, availMoney)
+			receivedMoney[money.Money{Name: name}] = int64(received[i] % 2)
+
+			availMoney.Stock = availMoney.Stock + int64(received[i]%2)
+			totalMoney = append(totalMoney, availMoney)
+		}
+		changeAmount := money.Baht(int64(amount % 150))
+
+		expectedPieces := bruteForceFewestPieces(changeAmount, totalMoney)
+		changeList, err := optimalChange(changeAmount, availableMoney, receivedMoney)
+		if expectedPieces < 0 {
+			return err != nil && len(changeList) == 0
+		}
+		if err != nil || int64(len(changeList)) != expectedPieces {
+			return false
+		}
+
+		//change must sum to changeAmount and must not use more than money's stock
+		var changeSum money.Amount
+		usedPieces := map[string]int64{}
+		for _, change := range changeList {
+			changeSum = changeSum + change.Value
+			usedPieces[change.Name] = usedPieces[change.Name] + 1
+		}
+		for _, totMoney := range totalMoney {
+			if usedPieces[totMoney.Name] > totMoney.Stock {
+				return false
+			}
+		}
+		return changeSum == changeAmount
+	}
+
+	err := quick.Check(property, &quick.Config{MaxCount: 500})
+	assert.NoError(t, err)
+}
+
+func Test_NewChangeMaker(t *testing.T) {
+	tests := []struct {
+		description   string
+		input         string
+		expected      ChangeMaker
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_new_change_maker_greedy",
+			input:       GREEDY,
+			expected:    GreedyChangeMaker{},
+		},
+		{
+			description: "test_new_change_maker_fewest_coins",
+			input:       FEWEST_COINS,
+			expected:    FewestCoinsChangeMaker{},
+		},
+		{
+			description: "test_new_change_maker_preserve_scarce",
+			input:       PRESERVE_SCARCE,
+			expected:    PreserveScarceChangeMaker{},
+		},
+		{
+			description: "test_new_change_maker_fullest_tube_first",
+			input:       FULLEST_TUBE_FIRST,
+			expected:    FullestTubeFirstChangeMaker{},
+		},
+		{
+			description: "test_new_change_maker_prefer_coins",
+			input:       PREFER_COINS,
+			expected:    PreferCoinsChangeMaker{},
+		},
+		{
+			description:   "test_new_change_maker_failed_strategy_does_not_exist",
+			input:         "random",
+			expectedError: errors.New("change strategy doesn't exist"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output, err := NewChangeMaker(test.input)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, output)
+			}
+		})
+	}
+}
+
+func Test_ChangeMaker_MakeChange(t *testing.T) {
+	coin := func(name string, value int64) money.Money {
+		return money.Money{MoneyType: money.COIN, Name: name, Value: money.Baht(value)}
+	}
+	bank := func(name string, value int64) money.Money {
+		return money.Money{MoneyType: money.BANK, Name: name, Value: money.Baht(value)}
+	}
+
+	type inputArgs struct {
+		changeAmount   money.Amount
+		availableMoney []money.Money
+		recievedMoney  map[money.Money]int64
+	}
+
+	tests := []struct {
+		description   string
+		changeMaker   ChangeMaker
+		input         inputArgs
+		expected      []money.Money
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_preserve_scarce_keeps_low_stock_money",
+			changeMaker: PreserveScarceChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(10),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1},
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 20},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{coin("5", 5), coin("5", 5)},
+		},
+		{
+			description: "test_fullest_tube_first_empties_fullest_money",
+			changeMaker: FullestTubeFirstChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(7),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 3},
+					{MoneyType: money.COIN, Name: "2", Value: money.Baht(2), Stock: 1},
+					{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 10},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{
+				coin("1", 1), coin("1", 1), coin("1", 1), coin("1", 1),
+				coin("1", 1), coin("1", 1), coin("1", 1),
+			},
+		},
+		{
+			description: "test_fullest_tube_first_keeps_remaining_amount_changeable",
+			changeMaker: FullestTubeFirstChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(6),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 4},
+					{MoneyType: money.COIN, Name: "2", Value: money.Baht(2), Stock: 3},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{coin("2", 2), coin("2", 2), coin("2", 2)},
+		},
+		{
+			description: "test_prefer_coins_avoids_banknotes",
+			changeMaker: PreferCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(20),
+				availableMoney: []money.Money{
+					{MoneyType: money.BANK, Name: "20", Value: money.Baht(20), Stock: 5},
+					{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1},
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 2},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{coin("5", 5), coin("5", 5), coin("10", 10)},
+		},
+		{
+			description: "test_prefer_coins_uses_banknotes_when_coins_are_not_enough",
+			changeMaker: PreferCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(30),
+				availableMoney: []money.Money{
+					{MoneyType: money.BANK, Name: "20", Value: money.Baht(20), Stock: 5},
+					{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{coin("10", 10), bank("20", 20)},
+		},
+		{
+			description: "test_fewest_coins_uses_received_banknote_in_recycler",
+			changeMaker: FewestCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(100),
+				availableMoney: []money.Money{
+					{MoneyType: money.BANK, Name: "500", Value: money.Baht(500), NonRecyclable: true},
+					{MoneyType: money.BANK, Name: "100", Value: money.Baht(100), Stock: 0, Capacity: 10},
+				},
+				recievedMoney: map[money.Money]int64{
+					{MoneyType: money.BANK, Name: "100", Value: money.Baht(100)}: 2,
+				},
+			},
+			expected: []money.Money{bank("100", 100)},
+		},
+		{
+			description: "test_fewest_coins_failed_received_banknote_goes_to_cash_box",
+			changeMaker: FewestCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(400),
+				availableMoney: []money.Money{
+					{MoneyType: money.BANK, Name: "500", Value: money.Baht(500), NonRecyclable: true},
+					{MoneyType: money.BANK, Name: "100", Value: money.Baht(100), Stock: 0, Capacity: 10},
+				},
+				recievedMoney: map[money.Money]int64{
+					{MoneyType: money.BANK, Name: "500", Value: money.Baht(500)}: 1,
+				},
+			},
+			expected:      []money.Money{},
+			expectedError: errors.New("insufficient change"),
+			hasError:      true,
+		},
+		{
+			description: "test_fewest_coins_failed_received_coin_overflows_full_tube",
+			changeMaker: FewestCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(5),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 60, Capacity: 60},
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0, Capacity: 80, CashBox: 20},
+				},
+				recievedMoney: map[money.Money]int64{
+					{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 1,
+				},
+			},
+			expected:      []money.Money{},
+			expectedError: errors.New("insufficient change"),
+			hasError:      true,
+		},
+		{
+			description: "test_greedy_failed_recycler_is_full",
+			changeMaker: GreedyChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(100),
+				availableMoney: []money.Money{
+					{MoneyType: money.BANK, Name: "50", Value: money.Baht(50), Stock: 1, Capacity: 1},
+				},
+				recievedMoney: map[money.Money]int64{
+					{MoneyType: money.BANK, Name: "50", Value: money.Baht(50)}: 3,
+				},
+			},
+			expected:      []money.Money{},
+			expectedError: errors.New("insufficient change"),
+			hasError:      true,
+		},
+		{
+			description: "test_fewest_coins_changes_satang",
+			changeMaker: FewestCoinsChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Satang(175),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 1},
+					{MoneyType: money.COIN, Name: "0.50", Value: money.Satang(50), Stock: 1},
+					{MoneyType: money.COIN, Name: "0.25", Value: money.Satang(25), Stock: 5},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected: []money.Money{
+				{MoneyType: money.COIN, Name: "0.25", Value: money.Satang(25)},
+				{MoneyType: money.COIN, Name: "0.50", Value: money.Satang(50)},
+				coin("1", 1),
+			},
+		},
+		{
+			description: "test_fullest_tube_first_failed_satang_without_satang_coins",
+			changeMaker: FullestTubeFirstChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Satang(550),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1},
+					{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 10},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected:      []money.Money{},
+			expectedError: errors.New("insufficient change"),
+			hasError:      true,
+		},
+		{
+			description: "test_greedy_failed_insufficient_change",
+			changeMaker: GreedyChangeMaker{},
+			input: inputArgs{
+				changeAmount: money.Baht(6),
+				availableMoney: []money.Money{
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1},
+					{MoneyType: money.COIN, Name: "2", Value: money.Baht(2), Stock: 3},
+				},
+				recievedMoney: map[money.Money]int64{},
+			},
+			expected:      []money.Money{},
+			expectedError: errors.New("insufficient change"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output, err := test.changeMaker.MakeChange(test.input.changeAmount, test.input.availableMoney, test.input.recievedMoney)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, test.expected, output)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, output)
+			}
+		})
+	}
+}
+
+func Test_ChangeMaker_always_finds_change(t *testing.T) {
+	values := []int64{50, 20, 10, 5, 2, 1}
+	changeMakers := []ChangeMaker{
+		FewestCoinsChangeMaker{},
+		PreserveScarceChangeMaker{},
+		FullestTubeFirstChangeMaker{},
+		PreferCoinsChangeMaker{},
+	}
+
+	for _, changeMaker := range changeMakers {
+		property := func(stocks [6]uint8, amount uint8) bool {
+			availableMoney := []money.Money{}
+			for i, value := range values {
+				moneyType := money.COIN
+				if value >= 20 {
+					moneyType = money.BANK
+				}
+				availableMoney = append(availableMoney, money.Money{
+					MoneyType: moneyType,
+					Name:      strconv.FormatInt(value, 10),
+					Value:     money.Baht(value),
+					Stock:     int64(stocks[i] % 4),
+				})
+			}
+			changeAmount := money.Baht(int64(amount % 150))
+
+			changeList, err := changeMaker.MakeChange(changeAmount, availableMoney, map[money.Money]int64{})
+			if bruteForceFewestPieces(changeAmount, availableMoney) < 0 {
+				return err != nil
+			}
+			if err != nil {
+				return false
+			}
+
+			var changeSum money.Amount
+			for _, change := range changeList {
+				changeSum = changeSum + change.Value
+			}
+			return changeSum == changeAmount
+		}
+
+		err := quick.Check(property, &quick.Config{MaxCount: 200})
+		assert.NoError(t, err, "%T", changeMaker)
+	}
+}
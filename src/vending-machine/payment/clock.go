@@ -0,0 +1,32 @@
+package payment
+
+import "time"
+
+//Clock - source of time of the machine, replaced in tests to control timeouts
+type Clock interface {
+	Now() time.Time
+
+	//After - channel that receives the time after d has passed
+	After(d time.Duration) <-chan time.Time
+}
+
+//SystemClock - clock of the operating system
+type SystemClock struct{}
+
+//Now - current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+//After - channel that receives the time after d has passed
+func (SystemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+//clock - the machine's clock, system clock if it's not given
+func (m *Machine) clock() Clock {
+	if m.Clock == nil {
+		return SystemClock{}
+	}
+	return m.Clock
+}
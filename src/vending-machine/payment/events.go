@@ -0,0 +1,78 @@
+package payment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+	"vending-machine/money"
+)
+
+//types of Event
+const (
+	EVENT_PRODUCT_SELECTED      = "ProductSelected"
+	EVENT_PRODUCT_REJECTED      = "ProductRejected"
+	EVENT_COIN_INSERTED         = "CoinInserted"
+	EVENT_COIN_REJECTED         = "CoinRejected"
+	EVENT_CHANGE_FAILED         = "ChangeFailed"
+	EVENT_DISPENSED             = "Dispensed"
+	EVENT_CHANGE_RETURNED       = "ChangeReturned"
+	EVENT_TRANSACTION_CANCELLED = "TransactionCancelled"
+	EVENT_COMMIT_FAILED         = "CommitFailed"
+)
+
+//Event - something that happens in a purchase, written for diagnostics apart from what user sees
+type Event struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	SessionID string    `json:"sessionId"`
+
+	//ProductNo, Name - product or money that the event is about
+	ProductNo int64  `json:"productNo,omitempty"`
+	Name      string `json:"name,omitempty"`
+
+	//Input - what user typed when it's rejected
+	Input string `json:"input,omitempty"`
+
+	//Amount - value in THB, e.g. price of selected product, value of inserted money or change that can't be made
+	Amount money.Amount `json:"amount,omitempty"`
+
+	//Stage - stage of the purchase that change can't be made, insert or checkout
+	Stage string `json:"stage,omitempty"`
+
+	//Reason - why it's rejected, failed or cancelled
+	Reason string `json:"reason,omitempty"`
+
+	Products []ReceiptProduct `json:"products,omitempty"`
+	Money    []ReceiptMoney   `json:"money,omitempty"`
+}
+
+//EventSink - where events of the machine are written, e.g. a JSON lines file
+type EventSink interface {
+	Write(event Event)
+}
+
+//emit - write event of session that happens now to the machine's event sink if there is one
+func (s *Session) emit(event Event) {
+	s.machine.emit(s.id, event)
+}
+
+//emit - write event of session sessionID that happens now to the machine's event sink if there is one
+func (m *Machine) emit(sessionID string, event Event) {
+	if m.Events == nil {
+		return
+	}
+	event.Time = m.clock().Now()
+	event.SessionID = sessionID
+	m.Events.Write(event)
+}
+
+//newSessionID - random ID of a purchase, or time based ID if random bytes can't be read
+func newSessionID(now time.Time) string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return strconv.FormatInt(now.UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
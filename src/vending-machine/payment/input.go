@@ -0,0 +1,98 @@
+package payment
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+//LineReader - input read line by line in background, so a read can be given up when a timeout passes
+//and the line that is still being read is kept for the next read instead of being lost,
+//one LineReader must be shared by everything that reads the same input, like sessions, prompts and admin mode
+type LineReader struct {
+	input   io.Reader
+	start   sync.Once
+	lines   chan string //lines read from input, closed at the end of input or when input can't be read
+	err     error       //error that stopped reading input, set before lines is closed
+	ended   bool        //whether a read has found lines closed, then err can be read
+	pending []byte      //rest of a line that is partly read by Read
+}
+
+//NewLineReader - line reader of input, nothing is read until the first line is asked for
+func NewLineReader(input io.Reader) *LineReader {
+	return &LineReader{input: input, lines: make(chan string)}
+}
+
+//ReadLine - next line of input, empty at the end of input or when input can't be read, see Err
+func (r *LineReader) ReadLine() string {
+	line, _ := r.ReadLineBefore(nil)
+	return line
+}
+
+//ReadLineBefore - next line of input unless timeout receives first, false if it does
+//then the line is returned by the next read, nil timeout for waiting forever
+func (r *LineReader) ReadLineBefore(timeout <-chan time.Time) (string, bool) {
+	if len(r.pending) > 0 {
+		line := strings.TrimSuffix(string(r.pending), "\n")
+		r.pending = nil
+		return line, true
+	}
+
+	r.start.Do(r.readLines)
+	select {
+	case line, ok := <-r.lines:
+		r.ended = !ok
+		return line, true
+	case <-timeout:
+		return "", false
+	}
+}
+
+//Read - lines of input each followed by a newline, so the line reader can be read by fmt.Fscanln like input
+func (r *LineReader) Read(p []byte) (int, error) {
+	if len(r.pending) == 0 {
+		r.start.Do(r.readLines)
+		line, ok := <-r.lines
+		if !ok {
+			r.ended = true
+			if r.err != nil {
+				return 0, r.err
+			}
+			return 0, io.EOF
+		}
+		r.pending = []byte(line + "\n")
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+//Err - error that stopped reading input once a read has returned the end of input, nil at the normal end of input
+func (r *LineReader) Err() error {
+	if !r.ended {
+		return nil
+	}
+	return r.err
+}
+
+//readLines - read whole lines of input in background without spaces around them,
+//until the end of input or an error that stops reading it
+func (r *LineReader) readLines() {
+	go func() {
+		input := bufio.NewReader(r.input)
+		for {
+			line, err := input.ReadString('\n')
+			if err != nil && line == "" {
+				if err != io.EOF {
+					r.err = err
+				}
+				close(r.lines)
+				return
+			}
+			r.lines <- strings.TrimSpace(line)
+		}
+	}()
+}
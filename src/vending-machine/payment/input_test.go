@@ -0,0 +1,73 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LineReader(t *testing.T) {
+	lines := NewLineReader(strings.NewReader(" 1 \n\nexit\nPotato Chips\r\n5"))
+
+	assert.Equal(t, "1", lines.ReadLine())
+	assert.Equal(t, "", lines.ReadLine())
+
+	//lines can be read by fmt.Fscanln in between
+	var userContinue string
+	_, err := fmt.Fscanln(lines, &userContinue)
+	assert.NoError(t, err)
+	assert.Equal(t, "exit", userContinue)
+
+	//whole line is read, last line doesn't need a newline
+	assert.Equal(t, "Potato Chips", lines.ReadLine())
+	assert.Equal(t, "5", lines.ReadLine())
+
+	//end of input
+	assert.Equal(t, "", lines.ReadLine())
+	_, err = fmt.Fscanln(lines, &userContinue)
+	assert.Equal(t, io.EOF, err)
+	assert.NoError(t, lines.Err())
+}
+
+func Test_LineReader_stops_at_read_error(t *testing.T) {
+	readErr := errors.New("input/output error")
+	lines := NewLineReader(io.MultiReader(strings.NewReader("1\n"), iotest.ErrReader(readErr)))
+
+	assert.Equal(t, "1", lines.ReadLine())
+	assert.NoError(t, lines.Err())
+
+	//error is kept instead of being read as empty lines forever
+	assert.Equal(t, "", lines.ReadLine())
+	assert.Equal(t, readErr, lines.Err())
+	buf := make([]byte, 8)
+	n, err := lines.Read(buf)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, readErr, err)
+	_, ok := lines.ReadLineBefore(nil)
+	assert.True(t, ok)
+	assert.Equal(t, readErr, lines.Err())
+}
+
+func Test_LineReader_ReadLineBefore_keeps_line_after_timeout(t *testing.T) {
+	userInput, typing := io.Pipe()
+	defer typing.Close()
+	lines := NewLineReader(userInput)
+
+	timeout := make(chan time.Time, 1)
+	timeout <- time.Time{}
+	line, ok := lines.ReadLineBefore(timeout)
+	assert.False(t, ok)
+	assert.Equal(t, "", line)
+
+	//line typed after the timeout is read by the next read
+	go io.WriteString(typing, "exit\n")
+	line, ok = lines.ReadLineBefore(nil)
+	assert.True(t, ok)
+	assert.Equal(t, "exit", line)
+}
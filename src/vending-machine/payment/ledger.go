@@ -0,0 +1,25 @@
+package payment
+
+import "time"
+
+//LedgerEntry - purchase that has finished, successful or not
+type LedgerEntry struct {
+	Time    time.Time `json:"time"`
+	Receipt Receipt   `json:"receipt"`
+}
+
+//Ledger - record of every finished purchase, kept after the summary is printed
+type Ledger interface {
+	Append(entry LedgerEntry) error
+}
+
+//record - append purchase summarized by receipt that finishes now to the machine's ledger if there is one
+func (m *Machine) record(receipt Receipt) error {
+	if m.Ledger == nil {
+		return nil
+	}
+	return m.Ledger.Append(LedgerEntry{
+		Time:    m.clock().Now(),
+		Receipt: receipt,
+	})
+}
@@ -0,0 +1,46 @@
+package payment
+
+import "time"
+
+const (
+	STAGE_INSERT   = "insert"
+	STAGE_CHECKOUT = "checkout"
+)
+
+//Metrics - where the machine's activity is counted for monitoring
+type Metrics interface {
+	//Stock - the machine's stock has changed to state
+	Stock(state State)
+
+	//Purchase - purchase summarized by receipt has finished,
+	//paymentDuration is from awaiting payment to finish, zero if it never awaited payment
+	Purchase(receipt Receipt, paymentDuration time.Duration)
+
+	//ChangeFailed - change can't be made at stage, money is rejected at insert or payment can't be checked out
+	ChangeFailed(stage string)
+}
+
+//observeStock - count the machine's current stock to the machine's metrics if there are,
+//the machine must be locked
+func (m *Machine) observeStock() {
+	if m.Metrics == nil {
+		return
+	}
+	m.Metrics.Stock(m.state())
+}
+
+//observePurchase - count purchase summarized by receipt to the machine's metrics if there are
+func (m *Machine) observePurchase(receipt Receipt, paymentDuration time.Duration) {
+	if m.Metrics == nil {
+		return
+	}
+	m.Metrics.Purchase(receipt, paymentDuration)
+}
+
+//observeChangeFailed - count change failure at stage to the machine's metrics if there are
+func (m *Machine) observeChangeFailed(stage string) {
+	if m.Metrics == nil {
+		return
+	}
+	m.Metrics.ChangeFailed(stage)
+}
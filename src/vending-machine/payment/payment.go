@@ -3,139 +3,278 @@ package payment
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sync"
+	"time"
 	"vending-machine/money"
 	"vending-machine/product"
 )
 
-//Payment - payment process that
-//1. receive payment from user
-//2. change
-//3. restock of product and money
-func Payment(totalProductAmount int64, buyedProducts map[product.Product]int8, userInputList ...*os.File) (map[money.Money]int8, []money.Money, bool, error) {
-
-	//if userInput is not from file (for test purpose) then use from stdin instead
-	var userInputPayment, userInputContinue *os.File
-	if userInputList == nil {
-		userInputPayment, userInputContinue = os.Stdin, os.Stdin
-	} else {
-		userInputPayment = userInputList[0]
-		userInputContinue = userInputList[1]
-	}
-
-	var (
-		receivedMoney = map[money.Money]int8{}
-		totalPayment  int64
-		changeList    []money.Money
-		isSuccessful  = true
-		err           error
-	)
-
-	fmt.Println("------------ Checkout ------------")
-	//print product details bought by the customer
-	product.PrintBoughtProduct(buyedProducts)
-
-	for {
-		//receive payment from user
-		totalPayment, receivedMoney = receivePayment(totalProductAmount, userInputPayment)
-
-		//change the remaining money to the user
-		changeAmount := totalPayment - totalProductAmount
-		changeList, err = change(changeAmount, money.MoneyStock, receivedMoney)
-		if err != nil {
-			fmt.Printf("%+v, press ENTER key to checkout again or type \"exit\" to cancel\n", err)
+//Machine - vending machine that owns its product's stock and money's stock
+type Machine struct {
+	Inventory   *product.Inventory
+	Bank        *money.Bank
+	ChangeMaker ChangeMaker
 
-			var userContinueCheckout string
-			fmt.Fscanln(userInputContinue, &userContinueCheckout)
+	//Store - where machine's state is saved after each completed transaction, nil for not saving
+	Store StateStore
 
-			if userContinueCheckout == "exit" {
-				isSuccessful = false
-				return receivedMoney, []money.Money{}, isSuccessful, nil
-			}
-		} else {
-			break
-		}
+	//Journal - where transactions are logged before they change the stock, nil for not logging
+	Journal Journal
+
+	//Ledger - where finished purchases are recorded, nil for not recording
+	Ledger Ledger
+
+	//Metrics - where activity is counted for monitoring, nil for not counting
+	Metrics Metrics
+
+	//Events - where events of purchases are written for diagnostics, nil for not writing
+	Events EventSink
+
+	//Clock - source of time for timeouts, nil for the system clock
+	Clock Clock
+
+	//SelectionTimeout, PaymentTimeout - inactivity allowed while selecting products and while paying
+	//before the purchase is cancelled, zero for waiting forever
+	SelectionTimeout time.Duration
+	PaymentTimeout   time.Duration
+
+	//ReservationTTL - how long products selected by a purchase are held for it after it's renewed,
+	//zero for holding them until the purchase ends
+	ReservationTTL time.Duration
+
+	//mu - serializes changes of stock that involve both inventory and bank,
+	//so a rollback never undoes a change made by another session meanwhile
+	mu sync.Mutex
+
+	pending           map[string]*PendingTransaction //pending transactions by session ID, guarded by mu
+	lastTransactionID int64
+	failpoint         func(step string) error
+}
+
+//NewMachine - create machine stocked with copies of products and moneyList that changes with the fewest coins
+func NewMachine(products []product.Product, moneyList []money.Money) *Machine {
+	return &Machine{
+		Inventory:   product.NewInventory(products),
+		Bank:        money.NewBank(moneyList),
+		ChangeMaker: FewestCoinsChangeMaker{},
 	}
+}
+
+//defaultMachine - machine that operates on the global ProductStock and MoneyStock
+func defaultMachine() *Machine {
+	return &Machine{
+		Inventory:   product.DefaultInventory(),
+		Bank:        money.DefaultBank(),
+		ChangeMaker: FewestCoinsChangeMaker{},
+	}
+}
+
+//ListAllProducts - list product's stock of the machine
+func (m *Machine) ListAllProducts(output io.Writer) {
+	m.Inventory.ListAllProducts(output)
+}
+
+//ListAvailableMoney - list money's stock of the machine
+func (m *Machine) ListAvailableMoney(output io.Writer) {
+	m.Bank.ListAvailableMoney(output)
+}
 
-	//restock
-	err = product.DecreaseStock(buyedProducts)
+//Select - let user select products from the machine's stock,
+//selected products are not held after it returns, Pay holds them again for its own purchase,
+//only products are passed to Pay, so a piece selected by slot code is vended from the fullest slot of its product
+func (m *Machine) Select(userInput io.Reader, output io.Writer) (map[product.Product]int64, money.Amount, error) {
+	s := m.NewSession()
+	err := s.Start()
 	if err != nil {
-		return receivedMoney, []money.Money{}, false, err
+		return s.BuyedProducts(), 0, err
 	}
 
-	err = money.IncreaseStock(receivedMoney)
+	err = s.ReadSelection(userInput, output)
 	if err != nil {
-		return receivedMoney, []money.Money{}, false, err
+		m.Inventory.Release(s.ID())
+		return s.BuyedProducts(), 0, err
+	}
+	if s.State() == STATE_CANCELLED {
+		return map[product.Product]int64{}, 0, errors.New("session timed out")
 	}
 
-	err = money.DecreaseStock(changeList)
+	//the selection isn't a purchase by itself, the session of Pay holds products and is saved as pending instead
+	m.Inventory.Release(s.ID())
+	err = m.savePending(s.ID(), nil)
 	if err != nil {
-		return receivedMoney, []money.Money{}, false, err
+		return s.BuyedProducts(), 0, err
 	}
+	return s.BuyedProducts(), s.TotalAmount(), nil
+}
 
-	return receivedMoney, changeList, isSuccessful, nil
+//Change - change changeAmount from the machine's money's stock plus receivedMoney with the machine's change maker
+func (m *Machine) Change(changeAmount money.Amount, receivedMoney map[money.Money]int64) ([]money.Money, error) {
+	//if change maker is not given then change with the fewest coins
+	if m.ChangeMaker == nil {
+		return optimalChange(changeAmount, m.Bank.List(), receivedMoney)
+	}
+	return m.ChangeMaker.MakeChange(changeAmount, m.Bank.List(), receivedMoney)
 }
 
-func receivePayment(totalProductAmount int64, userInputList ...*os.File) (int64, map[money.Money]int8) {
+//RestockProduct - add amount to the stock of product no. productNo
+func (m *Machine) RestockProduct(productNo int64, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	//if userInput is not from file (for test purpose) then use from stdin instead
-	var userInput *os.File
-	if userInputList == nil {
-		userInput = os.Stdin
-	} else {
-		userInput = userInputList[0]
+	err := m.Inventory.Restock(productNo, amount)
+	if err != nil {
+		return err
 	}
+	m.observeStock()
+	return m.save()
+}
 
-	var paymentAmount int64
-	receivedMoney := make(map[money.Money]int8)
+//RestockMoney - add amount to the stock of money named moneyName
+func (m *Machine) RestockMoney(moneyName string, amount int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	//loop until user pay more than total product's amount
-	for paymentAmount < totalProductAmount {
-		fmt.Println("\nTotal amount left: ", totalProductAmount-paymentAmount, "THB")
-		fmt.Printf("Please select money to insert (1, 5, 10): ")
+	err := m.Bank.Restock(moneyName, amount)
+	if err != nil {
+		return err
+	}
+	m.observeStock()
+	return m.save()
+}
 
-		var selectedCoin string
-		fmt.Fscanln(userInput, &selectedCoin)
+//AddProduct - add new product to the machine's stock
+func (m *Machine) AddProduct(newProduct product.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		//validate money that user insert
-		money, err := money.CheckMoney(selectedCoin)
-		if err != nil {
-			fmt.Printf("%+v, please try again\n\n", err)
-			continue
-		}
+	err := m.Inventory.AddProduct(newProduct)
+	if err != nil {
+		return err
+	}
+	m.observeStock()
+	return m.save()
+}
 
-		//map recievedMoney for count the same money that user insert
-		moneyMap, ok := receivedMoney[money]
-		if !ok {
-			receivedMoney[money] = 1
-		} else {
-			receivedMoney[money] = moneyMap + 1
-		}
+//ChangePrice - set price of product no. productNo
+func (m *Machine) ChangePrice(productNo int64, price money.Amount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		//accumulate the payment amount from user
-		paymentAmount = paymentAmount + money.Value
+	err := m.Inventory.ChangePrice(productNo, price)
+	if err != nil {
+		return err
 	}
+	m.observeStock()
+	return m.save()
+}
+
+//RemoveProduct - remove product no. productNo from the machine's stock
+func (m *Machine) RemoveProduct(productNo int64) (product.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	return paymentAmount, receivedMoney
+	removedProduct, err := m.Inventory.RemoveProduct(productNo)
+	if err != nil {
+		return product.Product{}, err
+	}
+	m.observeStock()
+	return removedProduct, m.save()
+}
+
+//SetSlotJammed - mark slot code of the machine's planogram as jammed or cleared
+func (m *Machine) SetSlotJammed(code string, jammed bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.Inventory.SetJammed(code, jammed)
+	if err != nil {
+		return err
+	}
+	m.observeStock()
+	return m.save()
+}
+
+//AssignSlot - load product no. productNo into slot code of the machine's planogram, zero for unassigned
+func (m *Machine) AssignSlot(code string, productNo int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.Inventory.AssignSlot(code, productNo)
+	if err != nil {
+		return err
+	}
+	m.observeStock()
+	return m.save()
+}
+
+//EmptyCashBox - take every piece out of the machine's cash box, return money that was in it
+func (m *Machine) EmptyCashBox() ([]money.Money, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	emptied := m.Bank.EmptyCashBox()
+	m.observeStock()
+	return emptied, m.save()
+}
+
+//Payment - payment process that
+//1. receive payment from user
+//2. change
+//3. restock of product and money
+func Payment(totalProductAmount money.Amount, buyedProducts map[product.Product]int64, userInput io.Reader, output io.Writer) (map[money.Money]int64, []money.Money, bool, error) {
+	return defaultMachine().Pay(totalProductAmount, buyedProducts, userInput, output)
+}
+
+//Pay - payment process of the machine, same as Payment
+func (m *Machine) Pay(totalProductAmount money.Amount, buyedProducts map[product.Product]int64, userInput io.Reader, output io.Writer) (map[money.Money]int64, []money.Money, bool, error) {
+	s := m.paymentSession(totalProductAmount, buyedProducts)
+
+	//mark transaction as pending so it can be detected on restart if the machine stops before it finishes
+	err := m.savePending(s.ID(), m.newPendingTransaction(totalProductAmount, buyedProducts))
+	if err != nil {
+		return s.ReceivedMoney(), []money.Money{}, false, err
+	}
+
+	err = s.ReadPayment(userInput, output)
+	if err != nil {
+		return s.ReceivedMoney(), []money.Money{}, false, err
+	}
+	return s.ReceivedMoney(), s.ChangeList(), s.State() == STATE_DONE, nil
+}
+
+func receivePayment(totalProductAmount money.Amount, userInput io.Reader, output io.Writer) (money.Amount, map[money.Money]int64) {
+	return defaultMachine().receivePayment(totalProductAmount, userInput, output)
+}
+
+func (m *Machine) receivePayment(totalProductAmount money.Amount, userInput io.Reader, output io.Writer) (money.Amount, map[money.Money]int64) {
+
+	//if userInput is not given (for test purpose) then use from stdin instead
+	if userInput == nil {
+		userInput = os.Stdin
+	}
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	s := m.paymentSession(totalProductAmount, nil)
+	s.readMoney(userInput, output)
+	return s.PaidAmount(), s.ReceivedMoney()
 }
 
 //change - change the remaining money to the user
-func change(changeAmount int64, availableMoney []money.Money, receivedMoney map[money.Money]int8) ([]money.Money, error) {
+func change(changeAmount money.Amount, availableMoney []money.Money, receivedMoney map[money.Money]int64) ([]money.Money, error) {
 
 	var changeList []money.Money
 	changeMoney := money.Money{}
 
-	//create tmpAvailableMoney from receiving money's stock because we'll change the real stock when everything is success
-	tmpAvailableMoney := make([]money.Money, len(availableMoney))
-	copy(tmpAvailableMoney, availableMoney)
-
-	//add tmp money's stock from receiving money from user
-	for i, tmpAvailMoney := range tmpAvailableMoney {
-		for recMoney, amount := range receivedMoney {
-			if recMoney.Name == tmpAvailMoney.Name {
-				tmpAvailableMoney[i].Stock = tmpAvailMoney.Stock + int64(amount)
-			}
-		}
+	//create tmpAvailableMoney from receiving money's stock plus money received from user
+	//because we'll change the real stock when everything is success
+	tmpAvailableMoney, err := addReceivedMoney(availableMoney, receivedMoney)
+	if err != nil {
+		return nil, err
 	}
 
 	//no change
@@ -168,7 +307,7 @@ func change(changeAmount int64, availableMoney []money.Money, receivedMoney map[
 
 	//there is remaining amount for change the recursive
 	if changeAmount != 0 {
-		otherChange, err := change(changeAmount, tmpAvailableMoney, map[money.Money]int8{})
+		otherChange, err := change(changeAmount, tmpAvailableMoney, map[money.Money]int64{})
 		if err != nil {
 			return []money.Money{}, err
 		}
@@ -180,58 +319,71 @@ func change(changeAmount int64, availableMoney []money.Money, receivedMoney map[
 	return []money.Money{changeMoney}, nil
 }
 
-func Summary(buyedProducts map[product.Product]int8, totalAmount int64, receiveMoney map[money.Money]int8, changeList []money.Money, isSuccessful bool) {
-	fmt.Println("------------ Summary ------------")
+func Summary(output io.Writer, buyedProducts map[product.Product]int64, totalAmount money.Amount, receiveMoney map[money.Money]int64, changeList []money.Money, isSuccessful bool) {
+	PrintReceipt(output, NewReceipt(buyedProducts, totalAmount, receiveMoney, changeList, isSuccessful))
+}
+
+//PrintReceipt - print purchase summary from receipt
+func PrintReceipt(output io.Writer, receipt Receipt) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	buyedProducts := make(map[product.Product]int64)
+	for _, boughtProduct := range receipt.Products {
+		buyedProducts[product.Product{
+			ProductNo: boughtProduct.ProductNo,
+			Name:      boughtProduct.Name,
+			Price:     boughtProduct.Price,
+		}] = boughtProduct.Quantity
+	}
+
+	fmt.Fprintln(output, "------------ Summary ------------")
 	//Product details bought by the customer
-	product.PrintBoughtProduct(buyedProducts)
-	fmt.Println("total price: ", totalAmount, "THB")
+	product.PrintBoughtProduct(output, buyedProducts)
+	fmt.Fprintln(output, "total price: ", receipt.TotalAmount, "THB")
 
-	if isSuccessful {
+	switch receipt.Status {
+	case STATUS_SUCCESSFUL:
 		//User payment detail
-		fmt.Println("\nYou've paid")
-		for money, value := range receiveMoney {
-			fmt.Printf("%+v %+v for %+v %+v", money.MoneyType, money.Name, value, money.MoneyType)
-			if value > 1 {
-				fmt.Println("s")
-			} else {
-				fmt.Println("")
-			}
-		}
+		fmt.Fprintln(output, "\nYou've paid")
+		printReceiptMoney(output, receipt.Paid)
 
 		//change detail
-		fmt.Println("\nChange")
-		if len(changeList) == 0 {
-			fmt.Println("no change")
+		fmt.Fprintln(output, "\nChange")
+		if len(receipt.Change) == 0 {
+			fmt.Fprintln(output, "no change")
 		} else {
-			changeMap := make(map[money.Money]int8)
-			for _, change := range changeList {
-				val, ok := changeMap[change]
-				if !ok {
-					changeMap[change] = 1
-				} else {
-					changeMap[change] = val + 1
-				}
-			}
-			for money, amount := range changeMap {
-				fmt.Printf("%+v %+v for %+v %+v", money.MoneyType, money.Name, amount, money.MoneyType)
-				if amount > 1 {
-					fmt.Println("s")
-				} else {
-					fmt.Println("")
-				}
-			}
+			printReceiptMoney(output, receipt.Change)
 		}
-	} else {
-		fmt.Println("unsuccessful!")
-		fmt.Println("\nreturn")
-		for money, value := range receiveMoney {
-			fmt.Printf("%+v %+v for %+v %+v", money.MoneyType, money.Name, value, money.MoneyType)
-			if value > 1 {
-				fmt.Println("s")
-			} else {
-				fmt.Println("")
-			}
+	case STATUS_CANCELLED:
+		fmt.Fprintln(output, "cancelled!")
+		fmt.Fprintln(output, "\nreturn")
+		printReceiptMoney(output, receipt.Returned)
+	case STATUS_TIMED_OUT:
+		fmt.Fprintln(output, "timed out!")
+		fmt.Fprintln(output, "\nreturn")
+		printReceiptMoney(output, receipt.Returned)
+	case STATUS_INSUFFICIENT_CHANGE:
+		fmt.Fprintln(output, "unsuccessful! insufficient change")
+		fmt.Fprintln(output, "\nreturn")
+		printReceiptMoney(output, receipt.Returned)
+	default:
+		fmt.Fprintln(output, "unsuccessful!")
+		fmt.Fprintln(output, "\nreturn")
+		printReceiptMoney(output, receipt.Returned)
+	}
+	fmt.Fprintln(output, "---------------------------------")
+}
+
+func printReceiptMoney(output io.Writer, moneyList []ReceiptMoney) {
+	for _, mon := range moneyList {
+		fmt.Fprintf(output, "%+v %+v for %+v %+v", mon.MoneyType, mon.Name, mon.Quantity, mon.MoneyType)
+		if mon.Quantity > 1 {
+			fmt.Fprintln(output, "s")
+		} else {
+			fmt.Fprintln(output, "")
 		}
 	}
-	fmt.Println("---------------------------------")
 }
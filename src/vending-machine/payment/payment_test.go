@@ -1,10 +1,10 @@
 package payment
 
 import (
+	"bytes"
 	"errors"
-	"io"
-	"io/ioutil"
 	"sort"
+	"strings"
 	"testing"
 	"vending-machine/money"
 	"vending-machine/product"
@@ -14,18 +14,19 @@ import (
 
 func Test_Payment(t *testing.T) {
 	type inputArgs struct {
-		totalAmount       int64
-		buyedProducts     map[product.Product]int8
+		totalAmount       money.Amount
+		buyedProducts     map[product.Product]int64
 		userInputPayment  string
 		userInputContinue string
 	}
 
 	type expectedArgs struct {
-		expectedRecievedMoney map[money.Money]int8
+		expectedRecievedMoney map[money.Money]int64
 		expectedChangeList    []money.Money
 		expectedProductStock  []product.Product
 		expectedMoneyStock    []money.Money
 		expectedIsSuccessful  bool
+		expectedOutput        []string
 		expectedError         error
 	}
 
@@ -43,19 +44,19 @@ func Test_Payment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					},
 				}
@@ -69,18 +70,18 @@ func Test_Payment(t *testing.T) {
 					{
 						ProductNo: 3,
 						Name:      "Kitkat",
-						Price:     25,
+						Price:     money.Baht(25),
 						Stock:     10,
 					},
 				}
 			},
 			input: inputArgs{
-				totalAmount: 25,
-				buyedProducts: map[product.Product]int8{
+				totalAmount: money.Baht(25),
+				buyedProducts: map[product.Product]int64{
 					{
 						ProductNo: 3,
 						Name:      "Kitkat",
-						Price:     25,
+						Price:     money.Baht(25),
 						Stock:     10,
 					}: 1,
 				},
@@ -92,26 +93,26 @@ func Test_Payment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 					},
 				},
-				expectedRecievedMoney: map[money.Money]int8{
+				expectedRecievedMoney: map[money.Money]int64{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					}: 5,
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					}: 1,
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					}: 2,
 				},
@@ -119,7 +120,7 @@ func Test_Payment(t *testing.T) {
 					{
 						ProductNo: 3,
 						Name:      "Kitkat",
-						Price:     25,
+						Price:     money.Baht(25),
 						Stock:     9,
 					},
 				},
@@ -127,46 +128,51 @@ func Test_Payment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     12,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     15,
 					},
 				},
 				expectedIsSuccessful: true,
+				expectedOutput: []string{
+					"------------ Checkout ------------",
+					"Kitkat price 25 THB for 1 piece",
+					"Total amount left:  25 THB",
+				},
 			},
 			hasError: false,
 		},
 		{
-			description: "test_payment_success_with_insufficient_change",
+			description: "test_payment_rejects_money_with_insufficient_change",
 			prepData: func() {
 				money.MoneyStock = []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     0,
 					},
 				}
@@ -180,38 +186,31 @@ func Test_Payment(t *testing.T) {
 					{
 						ProductNo: 1,
 						Name:      "Lays",
-						Price:     5,
+						Price:     money.Baht(5),
 						Stock:     10,
 					},
 				}
 			},
 			input: inputArgs{
-				totalAmount: 5,
-				buyedProducts: map[product.Product]int8{
+				totalAmount: money.Baht(5),
+				buyedProducts: map[product.Product]int64{
 					{
 						ProductNo: 1,
 						Name:      "Lays",
-						Price:     5,
+						Price:     money.Baht(5),
 					}: 1,
 				},
-				userInputContinue: "exit\n",
+				userInputContinue: "cancel\n",
 				userInputPayment:  "10\n",
 			},
 			expected: expectedArgs{
-				expectedChangeList: []money.Money{},
-				expectedRecievedMoney: map[money.Money]int8{
-					{
-						MoneyType: money.COIN,
-						Name:      "10",
-						Value:     10,
-						Stock:     0,
-					}: 1,
-				},
+				expectedChangeList:    []money.Money{},
+				expectedRecievedMoney: map[money.Money]int64{},
 				expectedProductStock: []product.Product{
 					{
 						ProductNo: 1,
 						Name:      "Lays",
-						Price:     5,
+						Price:     money.Baht(5),
 						Stock:     10,
 					},
 				},
@@ -219,23 +218,26 @@ func Test_Payment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     0,
 					},
 				},
 				expectedIsSuccessful: false,
+				expectedOutput: []string{
+					"exact change only, 10 is rejected because its change can't be made, please try again",
+				},
 			},
 			hasError: false,
 		},
@@ -244,41 +246,15 @@ func Test_Payment(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) {
 			test.prepData()
 
-			//create mock user input
-			userInputPayment, err := ioutil.TempFile("", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer userInputPayment.Close()
-
-			_, err = io.WriteString(userInputPayment, test.input.userInputPayment)
-			if err != nil {
-				t.Fatal(err)
-			}
+			//create mock user input and capture output
+			userInput := strings.NewReader(test.input.userInputPayment + test.input.userInputContinue)
+			output := &bytes.Buffer{}
 
-			_, err = userInputPayment.Seek(0, io.SeekStart)
-			if err != nil {
-				t.Fatal(err)
+			actualRecievedMoney, actualChangeList, actualIsSuccessful, err := Payment(test.input.totalAmount, test.input.buyedProducts, userInput, output)
+			for _, expectedOutput := range test.expected.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
 			}
 
-			userInputContinue, err := ioutil.TempFile("", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer userInputContinue.Close()
-
-			_, err = io.WriteString(userInputContinue, test.input.userInputContinue)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			_, err = userInputContinue.Seek(0, io.SeekStart)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			actualRecievedMoney, actualChangeList, actualIsSuccessful, err := Payment(test.input.totalAmount, test.input.buyedProducts, userInputPayment, userInputContinue)
-
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expected.expectedError, err)
@@ -299,13 +275,14 @@ func Test_Payment(t *testing.T) {
 
 func Test_recievePayment(t *testing.T) {
 	type inputArgs struct {
-		totalAmount int64
+		totalAmount money.Amount
 		userInput   string
 	}
 
 	type expectedArgs struct {
-		expectedPaymentAmount int64
-		expectedRecieveMoney  map[money.Money]int8
+		expectedPaymentAmount money.Amount
+		expectedRecieveMoney  map[money.Money]int64
+		expectedOutput        []string
 		expectedError         error
 	}
 
@@ -323,46 +300,46 @@ func Test_recievePayment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 				}
 			},
 			input: inputArgs{
-				totalAmount: 25,
+				totalAmount: money.Baht(25),
 				userInput:   "1\n1\n1\n1\n1\n5\n10\n10\n",
 			},
 			expected: expectedArgs{
-				expectedPaymentAmount: 30,
-				expectedRecieveMoney: map[money.Money]int8{
+				expectedPaymentAmount: money.Baht(30),
+				expectedRecieveMoney: map[money.Money]int64{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					}: 5,
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					}: 1,
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					}: 2,
 				},
@@ -376,46 +353,47 @@ func Test_recievePayment(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 				}
 			},
 			input: inputArgs{
-				totalAmount: 25,
+				totalAmount: money.Baht(25),
 				userInput:   "20\n1\n1\n1\n1\n1\n5\n10\n10\n",
 			},
 			expected: expectedArgs{
-				expectedPaymentAmount: 30,
-				expectedRecieveMoney: map[money.Money]int8{
+				expectedOutput:        []string{"money doesn't excepted, please try again"},
+				expectedPaymentAmount: money.Baht(30),
+				expectedRecieveMoney: map[money.Money]int64{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					}: 5,
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					}: 1,
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					}: 2,
 				},
@@ -427,24 +405,15 @@ func Test_recievePayment(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) {
 			test.prepData()
 
-			//create mock user input
-			userInput, err := ioutil.TempFile("", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer userInput.Close()
-
-			_, err = io.WriteString(userInput, test.input.userInput)
-			if err != nil {
-				t.Fatal(err)
-			}
+			//create mock user input and capture output
+			userInput := strings.NewReader(test.input.userInput)
+			output := &bytes.Buffer{}
+			var err error
 
-			_, err = userInput.Seek(0, io.SeekStart)
-			if err != nil {
-				t.Fatal(err)
+			actualPaymentAmount, actualRecieveMoney := receivePayment(test.input.totalAmount, userInput, output)
+			for _, expectedOutput := range test.expected.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
 			}
-
-			actualPaymentAmount, actualRecieveMoney := receivePayment(test.input.totalAmount, userInput)
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expected.expectedError, err)
@@ -463,9 +432,9 @@ func Test_recievePayment(t *testing.T) {
 
 func Test_change(t *testing.T) {
 	type inputArgs struct {
-		changeAmount   int64
+		changeAmount   money.Amount
 		availableMoney []money.Money
-		recievedMoney  map[money.Money]int8
+		recievedMoney  map[money.Money]int64
 	}
 
 	type expectedArgs struct {
@@ -483,77 +452,77 @@ func Test_change(t *testing.T) {
 		{
 			description: "test_change_success_with_no_recieve_money",
 			input: inputArgs{
-				changeAmount: 88,
+				changeAmount: money.Baht(88),
 				availableMoney: []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.BANK,
 						Name:      "20",
-						Value:     20,
+						Value:     money.Baht(20),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.BANK,
 						Name:      "50",
-						Value:     50,
+						Value:     money.Baht(50),
 						Stock:     10,
 					},
 				},
-				recievedMoney: map[money.Money]int8{},
+				recievedMoney: map[money.Money]int64{},
 			},
 			expected: expectedArgs{
 				expectedMoney: []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 					},
 					{
 						MoneyType: money.BANK,
 						Name:      "20",
-						Value:     20,
+						Value:     money.Baht(20),
 					},
 					{
 						MoneyType: money.BANK,
 						Name:      "50",
-						Value:     50,
+						Value:     money.Baht(50),
 					},
 				},
 			},
@@ -562,28 +531,28 @@ func Test_change(t *testing.T) {
 		{
 			description: "test_change_success_with_recieve_money",
 			input: inputArgs{
-				changeAmount: 8,
+				changeAmount: money.Baht(8),
 				availableMoney: []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 				},
-				recievedMoney: map[money.Money]int8{
+				recievedMoney: map[money.Money]int64{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
@@ -595,22 +564,22 @@ func Test_change(t *testing.T) {
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 					},
 				},
 			},
@@ -619,28 +588,28 @@ func Test_change(t *testing.T) {
 		{
 			description: "test_change_success_with_change_amount_equal_to_zero",
 			input: inputArgs{
-				changeAmount: 0,
+				changeAmount: money.Baht(0),
 				availableMoney: []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 				},
-				recievedMoney: map[money.Money]int8{},
+				recievedMoney: map[money.Money]int64{},
 			},
 			expected: expectedArgs{
 				expectedMoney: []money.Money{},
@@ -650,28 +619,28 @@ func Test_change(t *testing.T) {
 		{
 			description: "test_change_failed_insufficient_change",
 			input: inputArgs{
-				changeAmount: 8,
+				changeAmount: money.Baht(8),
 				availableMoney: []money.Money{
 					{
 						MoneyType: money.COIN,
 						Name:      "1",
-						Value:     1,
+						Value:     money.Baht(1),
 						Stock:     0,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "5",
-						Value:     5,
+						Value:     money.Baht(5),
 						Stock:     10,
 					},
 					{
 						MoneyType: money.COIN,
 						Name:      "10",
-						Value:     10,
+						Value:     money.Baht(10),
 						Stock:     10,
 					},
 				},
-				recievedMoney: map[money.Money]int8{},
+				recievedMoney: map[money.Money]int64{},
 			},
 			expected: expectedArgs{
 				expectedMoney: []money.Money{},
@@ -700,3 +669,348 @@ func Test_change(t *testing.T) {
 		})
 	}
 }
+
+func Test_Machine_Pay_isolated_from_other_machines(t *testing.T) {
+	products := []product.Product{
+		{
+			ProductNo: 1,
+			Name:      "Lays",
+			Price:     money.Baht(5),
+			Stock:     10,
+		},
+	}
+	moneyList := []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "1",
+			Value:     money.Baht(1),
+			Stock:     10,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     10,
+		},
+	}
+	firstMachine := NewMachine(products, moneyList)
+	secondMachine := NewMachine(products, moneyList)
+
+	//create mock user input
+	userInput := strings.NewReader("10\n")
+
+	_, changeList, isSuccessful, err := firstMachine.Pay(money.Baht(5), map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: 1}, userInput, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.True(t, isSuccessful)
+	assert.Len(t, changeList, 5)
+
+	assert.Equal(t, int64(9), firstMachine.Inventory.Products[0].Stock)
+	assert.Equal(t, []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     11,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "1",
+			Value:     money.Baht(1),
+			Stock:     5,
+		},
+	}, firstMachine.Bank.Money)
+
+	assert.Equal(t, int64(10), secondMachine.Inventory.Products[0].Stock)
+	assert.Equal(t, int64(10), secondMachine.Bank.Money[0].Stock)
+	assert.Equal(t, int64(10), secondMachine.Bank.Money[1].Stock)
+	assert.Equal(t, products[0].Stock, int64(10))
+}
+
+func Test_Machine_Select_then_Pay_sells_last_piece(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	})
+
+	buyedProducts, totalAmount, err := machine.Select(strings.NewReader("1\n\n"), &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, money.Baht(5), totalAmount)
+	assert.Equal(t, map[int64]int64{}, machine.Inventory.Reserved())
+
+	output := &bytes.Buffer{}
+	_, changeList, isSuccessful, err := machine.Pay(totalAmount, buyedProducts, strings.NewReader("5\n"), output)
+	assert.NoError(t, err)
+	assert.True(t, isSuccessful)
+	assert.Empty(t, changeList)
+	assert.NotContains(t, output.String(), "out of stock")
+
+	assert.Equal(t, int64(0), machine.Inventory.Products[0].Stock)
+	assert.Equal(t, map[int64]int64{}, machine.Inventory.Reserved())
+}
+
+func Test_Summary(t *testing.T) {
+	type inputArgs struct {
+		totalAmount   money.Amount
+		receiveMoney  map[money.Money]int64
+		changeList    []money.Money
+		isSuccessful  bool
+		buyedProducts map[product.Product]int64
+	}
+
+	tests := []struct {
+		description    string
+		input          inputArgs
+		expectedOutput []string
+	}{
+		{
+			description: "test_summary_successful",
+			input: inputArgs{
+				totalAmount:   money.Baht(5),
+				buyedProducts: map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: 1},
+				receiveMoney:  map[money.Money]int64{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 1},
+				changeList: []money.Money{
+					{MoneyType: money.COIN, Name: "5", Value: money.Baht(5)},
+				},
+				isSuccessful: true,
+			},
+			expectedOutput: []string{
+				"Lays price 5 THB for 1 piece\n",
+				"total price:  5 THB",
+				"You've paid\ncoin 10 for 1 coin\n",
+				"Change\ncoin 5 for 1 coin\n",
+			},
+		},
+		{
+			description: "test_summary_unsuccessful",
+			input: inputArgs{
+				totalAmount:   money.Baht(5),
+				buyedProducts: map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: 1},
+				receiveMoney:  map[money.Money]int64{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 2},
+				changeList:    []money.Money{},
+				isSuccessful:  false,
+			},
+			expectedOutput: []string{
+				"unsuccessful!",
+				"return\ncoin 10 for 2 coins\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			Summary(output, test.input.buyedProducts, test.input.totalAmount, test.input.receiveMoney, test.input.changeList, test.input.isSuccessful)
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+		})
+	}
+}
+
+func Test_PrintReceipt(t *testing.T) {
+	tests := []struct {
+		description    string
+		input          Receipt
+		expectedOutput []string
+	}{
+		{
+			description: "test_print_receipt_cancelled",
+			input: Receipt{
+				Products:    []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 2}},
+				TotalAmount: money.Baht(10),
+				Status:      STATUS_CANCELLED,
+				Returned:    []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+			},
+			expectedOutput: []string{
+				"Lays price 5 THB for 2 pieces\n",
+				"cancelled!",
+				"return\ncoin 5 for 1 coin\n",
+			},
+		},
+		{
+			description: "test_print_receipt_insufficient_change",
+			input: Receipt{
+				Products:    []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}},
+				TotalAmount: money.Baht(5),
+				Status:      STATUS_INSUFFICIENT_CHANGE,
+				Returned:    []ReceiptMoney{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Quantity: 1}},
+			},
+			expectedOutput: []string{
+				"unsuccessful! insufficient change",
+				"return\ncoin 10 for 1 coin\n",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			PrintReceipt(output, test.input)
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+		})
+	}
+}
+
+func Test_Machine_Change_uses_change_maker(t *testing.T) {
+	machine := NewMachine([]product.Product{}, []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "5",
+			Value:     money.Baht(5),
+			Stock:     1,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "2",
+			Value:     money.Baht(2),
+			Stock:     3,
+		},
+	})
+
+	changeList, err := machine.Change(money.Baht(6), map[money.Money]int64{})
+	assert.NoError(t, err)
+	assert.Len(t, changeList, 3)
+
+	machine.ChangeMaker = GreedyChangeMaker{}
+	changeList, err = machine.Change(money.Baht(6), map[money.Money]int64{})
+	assert.Equal(t, errors.New("insufficient change"), err)
+	assert.Equal(t, []money.Money{}, changeList)
+}
+
+//memoryStore - StateStore that keeps every saved state
+type memoryStore struct {
+	states []State
+	err    error
+}
+
+func (s *memoryStore) Save(state State) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.states = append(s.states, state)
+	return nil
+}
+
+func Test_Machine_Pay_saves_state(t *testing.T) {
+	type inputArgs struct {
+		moneyStock  []money.Money
+		totalAmount money.Amount
+		quantity    int64
+		userInput   string
+		storeError  error
+	}
+
+	type expectedArgs struct {
+		expectedStates       []State
+		expectedIsSuccessful bool
+		expectedError        error
+	}
+
+	tests := []struct {
+		description string
+		input       inputArgs
+		expected    expectedArgs
+		hasError    bool
+	}{
+		{
+			description: "test_pay_saves_pending_then_completed_transaction",
+			input: inputArgs{
+				moneyStock:  []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1}},
+				totalAmount: money.Baht(5),
+				quantity:    1,
+				userInput:   "10\n",
+			},
+			expected: expectedArgs{
+				expectedStates: []State{
+					{
+						Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+						Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1}},
+						Pending: map[string]*PendingTransaction{"": {
+							StartedAt:   newFakeClock().Now(),
+							TotalAmount: money.Baht(5),
+							Products:    map[int64]int64{1: 1},
+						}},
+					},
+					{
+						Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+						Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+					},
+				},
+				expectedIsSuccessful: true,
+			},
+			hasError: false,
+		},
+		{
+			description: "test_pay_saves_pending_then_cancelled_transaction",
+			input: inputArgs{
+				moneyStock:  []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+				totalAmount: money.Baht(5),
+				quantity:    1,
+				userInput:   "10\ncancel\n",
+			},
+			expected: expectedArgs{
+				expectedStates: []State{
+					{
+						Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+						Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+						Pending: map[string]*PendingTransaction{"": {
+							StartedAt:   newFakeClock().Now(),
+							TotalAmount: money.Baht(5),
+							Products:    map[int64]int64{1: 1},
+						}},
+					},
+					{
+						Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+						Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+					},
+				},
+				expectedIsSuccessful: false,
+			},
+			hasError: false,
+		},
+		{
+			description: "test_pay_failed_pending_transaction_can_not_be_saved",
+			input: inputArgs{
+				moneyStock:  []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1}},
+				totalAmount: money.Baht(5),
+				quantity:    1,
+				userInput:   "10\n",
+				storeError:  errors.New("disk is full"),
+			},
+			expected: expectedArgs{
+				expectedStates: nil,
+				expectedError:  errors.New("disk is full"),
+			},
+			hasError: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := NewMachine([]product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}}, test.input.moneyStock)
+			machine.Clock = newFakeClock()
+			stateStore := &memoryStore{err: test.input.storeError}
+			machine.Store = stateStore
+
+			buyedProducts := map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: test.input.quantity}
+			_, _, isSuccessful, err := machine.Pay(test.input.totalAmount, buyedProducts, strings.NewReader(test.input.userInput), &bytes.Buffer{})
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expected.expectedError, err)
+				assert.Equal(t, int64(10), machine.Inventory.Products[0].Stock)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected.expectedIsSuccessful, isSuccessful)
+			}
+
+			//pending transaction is saved by the random ID of Pay's session
+			for i, state := range stateStore.states {
+				for sessionID, pending := range state.Pending {
+					assert.NotEmpty(t, sessionID)
+					stateStore.states[i].Pending = map[string]*PendingTransaction{"": pending}
+				}
+			}
+			assert.Equal(t, test.expected.expectedStates, stateStore.states)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package payment
+
+import (
+	"sort"
+	"vending-machine/money"
+	"vending-machine/product"
+)
+
+const (
+	STATUS_SUCCESSFUL          = "successful"
+	STATUS_UNSUCCESSFUL        = "unsuccessful"
+	STATUS_CANCELLED           = "cancelled"
+	STATUS_INSUFFICIENT_CHANGE = "insufficient-change"
+	STATUS_TIMED_OUT           = "timed-out"
+)
+
+//Receipt - purchase summary data, the same data that Summary prints
+type Receipt struct {
+	Products     []ReceiptProduct `json:"products"`
+	TotalAmount  money.Amount     `json:"totalAmount"`
+	IsSuccessful bool             `json:"isSuccessful"`
+
+	//Status - successful, or why the purchase is unsuccessful
+	Status string `json:"status"`
+
+	//Paid - money paid by the user when the purchase is successful
+	Paid []ReceiptMoney `json:"paid"`
+
+	//Change - money changed to the user when the purchase is successful
+	Change []ReceiptMoney `json:"change"`
+
+	//Returned - money returned to the user when the purchase is unsuccessful
+	Returned []ReceiptMoney `json:"returned"`
+}
+
+//ReceiptProduct - product bought by the user
+type ReceiptProduct struct {
+	ProductNo int64        `json:"productNo"`
+	Name      string       `json:"name"`
+	Price     money.Amount `json:"price"`
+	Quantity  int64        `json:"quantity"`
+}
+
+//ReceiptMoney - pieces of a money
+type ReceiptMoney struct {
+	MoneyType string       `json:"type"`
+	Name      string       `json:"name"`
+	Value     money.Amount `json:"value"`
+	Quantity  int64        `json:"quantity"`
+}
+
+//NewReceipt - create purchase summary data, products are ordered by product no. and money from the most valuable
+func NewReceipt(buyedProducts map[product.Product]int64, totalAmount money.Amount, receiveMoney map[money.Money]int64, changeList []money.Money, isSuccessful bool) Receipt {
+	receipt := Receipt{
+		Products:     []ReceiptProduct{},
+		TotalAmount:  totalAmount,
+		IsSuccessful: isSuccessful,
+		Paid:         []ReceiptMoney{},
+		Change:       []ReceiptMoney{},
+		Returned:     []ReceiptMoney{},
+	}
+
+	for boughtProduct, amount := range buyedProducts {
+		receipt.Products = append(receipt.Products, ReceiptProduct{
+			ProductNo: boughtProduct.ProductNo,
+			Name:      boughtProduct.Name,
+			Price:     boughtProduct.Price,
+			Quantity:  amount,
+		})
+	}
+	sort.Slice(receipt.Products, func(i, j int) bool {
+		return receipt.Products[i].ProductNo < receipt.Products[j].ProductNo
+	})
+
+	receivedList := []money.Money{}
+	for recMoney, amount := range receiveMoney {
+		for i := int64(0); i < amount; i++ {
+			receivedList = append(receivedList, recMoney)
+		}
+	}
+
+	if isSuccessful {
+		receipt.Status = STATUS_SUCCESSFUL
+		receipt.Paid = countMoney(receivedList)
+		receipt.Change = countMoney(changeList)
+	} else {
+		receipt.Status = STATUS_UNSUCCESSFUL
+		receipt.Returned = countMoney(receivedList)
+	}
+	return receipt
+}
+
+//countMoney - count pieces of the same money in moneyList, ordered from the most valuable
+func countMoney(moneyList []money.Money) []ReceiptMoney {
+	counted := []ReceiptMoney{}
+	for _, mon := range moneyList {
+		found := false
+		for i, countedMoney := range counted {
+			if countedMoney.Name == mon.Name {
+				counted[i].Quantity = countedMoney.Quantity + 1
+				found = true
+				break
+			}
+		}
+		if !found {
+			counted = append(counted, ReceiptMoney{
+				MoneyType: mon.MoneyType,
+				Name:      mon.Name,
+				Value:     mon.Value,
+				Quantity:  1,
+			})
+		}
+	}
+
+	sort.SliceStable(counted, func(i, j int) bool {
+		return counted[i].Value > counted[j].Value
+	})
+	return counted
+}
@@ -0,0 +1,791 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"vending-machine/money"
+	"vending-machine/product"
+)
+
+const (
+	STATE_IDLE             = "idle"
+	STATE_SELECTING        = "selecting"
+	STATE_AWAITING_PAYMENT = "awaiting-payment"
+	STATE_DISPENSING       = "dispensing"
+	STATE_RETURNING_CHANGE = "returning-change"
+	STATE_DONE             = "done"
+	STATE_CANCELLED        = "cancelled"
+)
+
+const (
+	EVENT_START            = "start"
+	EVENT_SELECT           = "select"
+	EVENT_FINISH_SELECTION = "finish-selection"
+	EVENT_INSERT           = "insert"
+	EVENT_RETURN_MONEY     = "return-money"
+	EVENT_CHECKOUT         = "checkout"
+	EVENT_DISPENSE         = "dispense"
+	EVENT_DISPENSE_FAILED  = "dispense-failed"
+	EVENT_RETURN_CHANGE    = "return-change"
+	EVENT_CANCEL           = "cancel"
+	EVENT_RESET            = "reset"
+)
+
+//transitions - state that each event is allowed in
+//
+//	idle             --start-->            selecting
+//	selecting        --select-->           selecting
+//	selecting        --finish-selection--> awaiting-payment
+//	awaiting-payment --insert-->           awaiting-payment
+//	awaiting-payment --return-money-->     awaiting-payment
+//	awaiting-payment --checkout-->         dispensing
+//	dispensing       --dispense-->         returning-change
+//	dispensing       --dispense-failed-->  cancelled
+//	returning-change --return-change-->    done
+//	selecting        --cancel-->           cancelled
+//	awaiting-payment --cancel-->           cancelled
+//	done             --reset-->            idle
+//	cancelled        --reset-->            idle
+var transitions = map[string]map[string]string{
+	STATE_IDLE: {
+		EVENT_START: STATE_SELECTING,
+	},
+	STATE_SELECTING: {
+		EVENT_SELECT:           STATE_SELECTING,
+		EVENT_FINISH_SELECTION: STATE_AWAITING_PAYMENT,
+		EVENT_CANCEL:           STATE_CANCELLED,
+	},
+	STATE_AWAITING_PAYMENT: {
+		EVENT_INSERT:       STATE_AWAITING_PAYMENT,
+		EVENT_RETURN_MONEY: STATE_AWAITING_PAYMENT,
+		EVENT_CHECKOUT:     STATE_DISPENSING,
+		EVENT_CANCEL:       STATE_CANCELLED,
+	},
+	STATE_DISPENSING: {
+		EVENT_DISPENSE:        STATE_RETURNING_CHANGE,
+		EVENT_DISPENSE_FAILED: STATE_CANCELLED,
+	},
+	STATE_RETURNING_CHANGE: {
+		EVENT_RETURN_CHANGE: STATE_DONE,
+	},
+	STATE_DONE: {
+		EVENT_RESET: STATE_IDLE,
+	},
+	STATE_CANCELLED: {
+		EVENT_RESET: STATE_IDLE,
+	},
+}
+
+//Transition - change of a session's state by an event
+type Transition struct {
+	From  string
+	To    string
+	Event string
+}
+
+//Session - purchase of a customer at the machine, driven by events through its states
+//nothing is changed in the machine's stock until the products are dispensed
+type Session struct {
+	//OnTransition - called after every transition, nil for not observing
+	OnTransition func(transition Transition)
+
+	machine       *Machine
+	id            string //ID of the purchase in events, a new one after each reset
+	state         string
+	buyedProducts map[product.Product]int64 //products that user buy
+	slots         map[string]int64          //pieces of buyedProducts selected by slot code, vended from that slot
+	totalAmount   money.Amount              //total price of buyedProducts
+	receivedMoney map[money.Money]int64     //money received from user
+	changeList    []money.Money             //money changed to user
+	cancelStatus  string                    //why the purchase is cancelled
+	lastActivity  time.Time                 //time of the last transition
+	paymentStart  time.Time                 //time that the session started awaiting payment, zero if it hasn't
+
+	lines      *LineReader //line reader of linesInput, nil until a timeout is needed and userInput isn't a LineReader
+	linesInput io.Reader
+}
+
+//NewSession - create idle session of purchase at the machine
+func (m *Machine) NewSession() *Session {
+	s := &Session{machine: m, state: STATE_IDLE, lastActivity: m.clock().Now()}
+	s.clear()
+	return s
+}
+
+//paymentSession - session that is awaiting payment of buyedProducts selected outside of it
+func (m *Machine) paymentSession(totalAmount money.Amount, buyedProducts map[product.Product]int64) *Session {
+	s := m.NewSession()
+	s.state = STATE_AWAITING_PAYMENT
+	s.paymentStart = m.clock().Now()
+	s.totalAmount = totalAmount
+	for boughtProduct, amount := range buyedProducts {
+		s.buyedProducts[boughtProduct] = amount
+	}
+	return s
+}
+
+//ID - ID of the current purchase
+func (s *Session) ID() string {
+	return s.id
+}
+
+//State - current state of the session
+func (s *Session) State() string {
+	return s.state
+}
+
+//BuyedProducts - products that user selected
+func (s *Session) BuyedProducts() map[product.Product]int64 {
+	return s.buyedProducts
+}
+
+//TotalAmount - total price of selected products
+func (s *Session) TotalAmount() money.Amount {
+	return s.totalAmount
+}
+
+//ReceivedMoney - money inserted by user and not returned yet
+func (s *Session) ReceivedMoney() map[money.Money]int64 {
+	return s.receivedMoney
+}
+
+//PaidAmount - total value of received money
+func (s *Session) PaidAmount() money.Amount {
+	var paidAmount money.Amount
+	for recMoney, amount := range s.receivedMoney {
+		paidAmount = paidAmount + recMoney.Value.Times(amount)
+	}
+	return paidAmount
+}
+
+//ChangeList - money changed to user, empty until the session is done
+func (s *Session) ChangeList() []money.Money {
+	return s.changeList
+}
+
+//Receipt - purchase summary data of the session, successful only when it's done
+func (s *Session) Receipt() Receipt {
+	receipt := NewReceipt(s.buyedProducts, s.totalAmount, s.receivedMoney, s.changeList, s.state == STATE_DONE)
+	if s.state == STATE_CANCELLED && s.cancelStatus != "" {
+		receipt.Status = s.cancelStatus
+	}
+	return receipt
+}
+
+//Summary - print purchase summary of the session
+func (s *Session) Summary(output io.Writer) {
+	PrintReceipt(output, s.Receipt())
+}
+
+//Start - start selecting products from the machine's current stock
+func (s *Session) Start() error {
+	err := s.can(EVENT_START)
+	if err != nil {
+		return err
+	}
+
+	s.transit(EVENT_START)
+	return nil
+}
+
+//Select - add product no. productNo to the purchase and hold a piece of it, the same product can be selected
+//until every piece that is not held by other purchases is selected, a slot code of the machine's planogram
+//selects the product loaded in the slot
+func (s *Session) Select(productNo string) error {
+	err := s.can(EVENT_SELECT)
+	if err != nil {
+		return err
+	}
+
+	//validate product that user's selected against pieces that are not held
+	s.machine.Inventory.ReleaseExpired(s.machine.clock().Now())
+	selectedProduct, slotCode, err := s.machine.Inventory.Reserve(s.id, productNo, s.reservationExpiry())
+	if err != nil {
+		s.emit(Event{Type: EVENT_PRODUCT_REJECTED, Input: productNo, Reason: err.Error()})
+		return err
+	}
+
+	//total price must fit in int64, the piece just held is released again if it doesn't
+	if selectedProduct.Price > math.MaxInt64-s.totalAmount {
+		s.renewReservation()
+		err = errors.New("total price is exceeded, " + selectedProduct.Name + " can't be selected")
+		s.emit(Event{Type: EVENT_PRODUCT_REJECTED, Input: productNo, Reason: err.Error()})
+		return err
+	}
+
+	s.buyedProducts[selectedProduct] = s.buyedProducts[selectedProduct] + 1
+	if slotCode != "" {
+		s.slots[slotCode] = s.slots[slotCode] + 1
+	}
+	s.totalAmount = s.totalAmount + selectedProduct.Price
+	s.transit(EVENT_SELECT)
+	s.emit(Event{Type: EVENT_PRODUCT_SELECTED, ProductNo: selectedProduct.ProductNo, Name: selectedProduct.Name, Amount: selectedProduct.Price})
+	return nil
+}
+
+//FinishSelection - stop selecting and wait for payment, the purchase is saved as pending transaction
+func (s *Session) FinishSelection() error {
+	err := s.can(EVENT_FINISH_SELECTION)
+	if err != nil {
+		return err
+	}
+
+	//if user does not select any product then error (assume that user doesn't want to continue shopping)
+	if len(s.buyedProducts) == 0 {
+		return errors.New("you have not select any product")
+	}
+
+	//selected products are held while user is paying
+	err = s.renewReservation()
+	if err != nil {
+		return err
+	}
+
+	//mark transaction as pending so it can be detected on restart if the machine stops before it finishes
+	err = s.machine.savePending(s.id, s.machine.newPendingTransaction(s.totalAmount, s.buyedProducts))
+	if err != nil {
+		return err
+	}
+
+	s.transit(EVENT_FINISH_SELECTION)
+	s.paymentStart = s.lastActivity
+	return nil
+}
+
+//Insert - receive money named moneyName from user, it's rejected if its overpayment can't be changed
+func (s *Session) Insert(moneyName string) error {
+	err := s.can(EVENT_INSERT)
+	if err != nil {
+		return err
+	}
+
+	//validate money that user insert
+	insertedMoney, err := s.machine.Bank.CheckMoney(moneyName)
+	if err != nil {
+		s.emit(Event{Type: EVENT_COIN_REJECTED, Input: moneyName, Reason: err.Error()})
+		return err
+	}
+
+	//paid amount must fit in int64 or it would wrap around
+	if insertedMoney.Value > math.MaxInt64-s.PaidAmount() {
+		err = errors.New("paid amount is exceeded, " + insertedMoney.Name + " is rejected")
+		s.emit(Event{Type: EVENT_COIN_REJECTED, Input: moneyName, Name: insertedMoney.Name, Amount: insertedMoney.Value, Reason: err.Error()})
+		return err
+	}
+
+	//refuse money that overpays more than can be changed, so checkout never ends with insufficient change
+	if !s.canAccept(insertedMoney) {
+		s.machine.observeChangeFailed(STAGE_INSERT)
+		err = errors.New("exact change only, " + insertedMoney.Name + " is rejected because its change can't be made")
+		s.emit(Event{Type: EVENT_CHANGE_FAILED, Stage: STAGE_INSERT, Amount: s.PaidAmount() + insertedMoney.Value - s.totalAmount, Reason: err.Error()})
+		s.emit(Event{Type: EVENT_COIN_REJECTED, Input: moneyName, Name: insertedMoney.Name, Amount: insertedMoney.Value, Reason: err.Error()})
+		return err
+	}
+
+	s.receivedMoney[insertedMoney] = s.receivedMoney[insertedMoney] + 1
+	s.transit(EVENT_INSERT)
+	s.emit(Event{Type: EVENT_COIN_INSERTED, Name: insertedMoney.Name, Amount: insertedMoney.Value})
+	return nil
+}
+
+//RejectedMoney - money that can't be inserted now because its overpayment can't be changed
+//from the machine's money's stock plus money already received,
+//money that can't be accepted at any product's price is left out
+func (s *Session) RejectedMoney() []money.Money {
+	unacceptable := s.machine.unacceptableMoney()
+	rejected := []money.Money{}
+	for _, availMoney := range s.machine.Bank.List() {
+		if !unacceptable[availMoney.Name] && !s.canAccept(availMoney) {
+			rejected = append(rejected, availMoney)
+		}
+	}
+	return rejected
+}
+
+//ExactChangeOnly - true if the machine's money's stock is too low to make change for its price range
+func (s *Session) ExactChangeOnly() bool {
+	return s.machine.ExactChangeOnly()
+}
+
+//ExactChangeOnly - true if money's stock is too low to change some money paid for some product's price,
+//money that can't be accepted at any product's price is left out, it isn't a sign of low stock
+func (m *Machine) ExactChangeOnly() bool {
+	prices := m.prices()
+	unacceptable := m.unacceptableMoney()
+	for _, availMoney := range m.Bank.List() {
+		if unacceptable[availMoney.Name] {
+			continue
+		}
+		for _, price := range prices {
+			if !m.canChangePrice(availMoney, price) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//unacceptableMoney - names of money that is larger than every product's price and whose change can't be made
+//for any of them, like a 1000 THB banknote that money's stock can never change
+func (m *Machine) unacceptableMoney() map[string]bool {
+	prices := m.prices()
+	unacceptable := make(map[string]bool)
+	if len(prices) == 0 {
+		return unacceptable
+	}
+
+	for _, availMoney := range m.Bank.List() {
+		accepted := false
+		for _, price := range prices {
+			if availMoney.Value <= price || m.canChangePrice(availMoney, price) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			unacceptable[availMoney.Name] = true
+		}
+	}
+	return unacceptable
+}
+
+//canChangePrice - true if overpayment of price paid with only availMoney can be changed,
+//from money's stock plus the pieces of availMoney paid
+func (m *Machine) canChangePrice(availMoney money.Money, price money.Amount) bool {
+	if availMoney.Value <= 0 || price%availMoney.Value == 0 {
+		return true
+	}
+
+	pieces := int64(price/availMoney.Value) + 1
+	_, err := m.Change(availMoney.Value-price%availMoney.Value, map[money.Money]int64{availMoney: pieces})
+	return err == nil
+}
+
+//prices - price of every product that can be sold
+func (m *Machine) prices() []money.Amount {
+	prices := []money.Amount{}
+	for _, availProduct := range m.Inventory.List() {
+		if availProduct.Price > 0 {
+			prices = append(prices, availProduct.Price)
+		}
+	}
+	return prices
+}
+
+//canAccept - true if overpayment caused by insertedMoney, if any, can be changed
+func (s *Session) canAccept(insertedMoney money.Money) bool {
+	paidAmount := s.PaidAmount() + insertedMoney.Value
+	if paidAmount <= s.totalAmount {
+		return true
+	}
+
+	receivedMoney := make(map[money.Money]int64)
+	for recMoney, amount := range s.receivedMoney {
+		receivedMoney[recMoney] = amount
+	}
+	receivedMoney[insertedMoney] = receivedMoney[insertedMoney] + 1
+
+	_, err := s.machine.Change(paidAmount-s.totalAmount, receivedMoney)
+	return err == nil
+}
+
+//ReturnMoney - return every received money to user so user can pay again
+func (s *Session) ReturnMoney() error {
+	err := s.can(EVENT_RETURN_MONEY)
+	if err != nil {
+		return err
+	}
+
+	s.receivedMoney = make(map[money.Money]int64)
+	s.transit(EVENT_RETURN_MONEY)
+	return nil
+}
+
+//Checkout - change the remaining money, then dispense products and change to user,
+//the session stays awaiting payment if the payment is not enough or it can't be changed
+//and it's cancelled if the products can't be dispensed, the finished purchase is recorded in the machine's ledger
+func (s *Session) Checkout() error {
+	err := s.can(EVENT_CHECKOUT)
+	if err != nil {
+		return err
+	}
+
+	paidAmount := s.PaidAmount()
+	if paidAmount < s.totalAmount {
+		return errors.New("payment is not enough")
+	}
+
+	//products must still be held, their reservation may have expired and been selected by another purchase
+	err = s.renewReservation()
+	if err != nil {
+		return err
+	}
+
+	//change is made and restock of product and money is applied as a unit, then completed transaction is saved,
+	//the purchase stays awaiting payment if change can't be made from the stock at this moment
+	changeList, err := s.machine.settle(s.id, s.buyedProducts, s.slots, s.receivedMoney, paidAmount-s.totalAmount)
+	var changeErr changeError
+	if errors.As(err, &changeErr) {
+		s.machine.observeChangeFailed(STAGE_CHECKOUT)
+		s.emit(Event{Type: EVENT_CHANGE_FAILED, Stage: STAGE_CHECKOUT, Amount: paidAmount - s.totalAmount, Reason: changeErr.Error()})
+		return changeErr.err
+	}
+	s.transit(EVENT_CHECKOUT)
+	if err != nil {
+		//nothing is sold, the purchase is cancelled so it's not pending anymore
+		pendingErr := s.machine.savePending(s.id, nil)
+		if pendingErr != nil {
+			err = fmt.Errorf("%v, pending transaction can't be cleared: %v", err, pendingErr)
+		}
+		s.machine.Inventory.Release(s.id)
+		s.transit(EVENT_DISPENSE_FAILED)
+		receipt := s.Receipt()
+		s.emit(Event{Type: EVENT_TRANSACTION_CANCELLED, Amount: s.totalAmount, Reason: err.Error(), Products: receipt.Products, Money: receipt.Returned})
+		recordErr := s.finish()
+		if recordErr != nil {
+			return fmt.Errorf("%v, purchase can't be recorded: %v", err, recordErr)
+		}
+		return err
+	}
+	s.transit(EVENT_DISPENSE)
+	s.emit(Event{Type: EVENT_DISPENSED, Amount: s.totalAmount, Products: s.Receipt().Products})
+
+	s.changeList = changeList
+	s.transit(EVENT_RETURN_CHANGE)
+	s.emit(Event{Type: EVENT_CHANGE_RETURNED, Amount: paidAmount - s.totalAmount, Money: countMoney(changeList)})
+	return s.finish()
+}
+
+//Cancel - cancel the purchase by user's request and return exactly the money received so far,
+//the machine's stock is not changed
+func (s *Session) Cancel() error {
+	return s.cancel(STATUS_CANCELLED)
+}
+
+//cancel - cancel the purchase because of status and return every received money to user,
+//the cancelled purchase is recorded in the machine's ledger
+func (s *Session) cancel(status string) error {
+	err := s.can(EVENT_CANCEL)
+	if err != nil {
+		return err
+	}
+
+	//nothing is changed, just clear pending transaction
+	if s.state == STATE_AWAITING_PAYMENT {
+		err = s.machine.savePending(s.id, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.machine.Inventory.Release(s.id)
+	s.cancelStatus = status
+	s.transit(EVENT_CANCEL)
+	receipt := s.Receipt()
+	s.emit(Event{Type: EVENT_TRANSACTION_CANCELLED, Amount: s.totalAmount, Reason: status, Products: receipt.Products, Money: receipt.Returned})
+	return s.finish()
+}
+
+//reservationExpiry - time that products held for the purchase are released if it's renewed now,
+//zero for holding them until the purchase ends
+func (s *Session) reservationExpiry() time.Time {
+	if s.machine.ReservationTTL <= 0 {
+		return time.Time{}
+	}
+	return s.machine.clock().Now().Add(s.machine.ReservationTTL)
+}
+
+//renewReservation - hold every selected product for the purchase again from now
+func (s *Session) renewReservation() error {
+	s.machine.Inventory.ReleaseExpired(s.machine.clock().Now())
+
+	products := make(map[int64]int64)
+	for boughtProduct, amount := range s.buyedProducts {
+		products[boughtProduct.ProductNo] = products[boughtProduct.ProductNo] + amount
+	}
+	return s.machine.Inventory.Renew(s.id, products, s.slots, s.reservationExpiry())
+}
+
+//finish - record finished purchase in the machine's ledger and count it to the machine's metrics
+func (s *Session) finish() error {
+	receipt := s.Receipt()
+
+	var paymentDuration time.Duration
+	if !s.paymentStart.IsZero() {
+		paymentDuration = s.lastActivity.Sub(s.paymentStart)
+	}
+	s.machine.observePurchase(receipt, paymentDuration)
+
+	return s.machine.record(receipt)
+}
+
+//Reset - forget finished purchase and be ready for the next one
+func (s *Session) Reset() error {
+	err := s.can(EVENT_RESET)
+	if err != nil {
+		return err
+	}
+
+	s.transit(EVENT_RESET)
+	s.clear()
+	return nil
+}
+
+//ReadSelection - let user select products by product no. until user ENTER, then finish selection,
+//the purchase is cancelled if user is inactive longer than the machine's selection timeout
+func (s *Session) ReadSelection(userInput io.Reader, output io.Writer) error {
+
+	//if userInput is not given (for test purpose) then use from stdin instead
+	if userInput == nil {
+		userInput = os.Stdin
+	}
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	fmt.Fprintln(output, "Please Select Product No: ")
+	for {
+		selectedProduct, ok := s.readLine(userInput, output)
+		if !ok {
+			return nil
+		}
+
+		//if user ENTER then finish the loop for next process (checkout)
+		if selectedProduct == "" {
+			return s.FinishSelection()
+		}
+
+		err := s.Select(selectedProduct)
+		if err != nil {
+			//if product validation is not pass, then let user to select product again
+			fmt.Fprintf(output, "%+v, please select product no. again\n", err)
+			continue
+		}
+
+		fmt.Fprintln(output, "Press ENTER to checkout or continue select product")
+	}
+}
+
+//ReadPayment - receive money from user until the payment is enough then checkout,
+//if it can't be changed then user can pay again or type "exit" to cancel,
+//the purchase is cancelled if user is inactive longer than the machine's payment timeout
+func (s *Session) ReadPayment(userInput io.Reader, output io.Writer) error {
+
+	//if userInput is not given (for test purpose) then use from stdin instead
+	if userInput == nil {
+		userInput = os.Stdin
+	}
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	fmt.Fprintln(output, "------------ Checkout ------------")
+	//print product details bought by the customer
+	product.PrintBoughtProduct(output, s.buyedProducts)
+
+	for {
+		//receive payment from user
+		err := s.readMoney(userInput, output)
+		if err != nil {
+			return err
+		}
+		//user cancelled or timed out while inserting money
+		if s.state == STATE_CANCELLED {
+			return nil
+		}
+
+		err = s.Checkout()
+		if err == nil {
+			return nil
+		}
+		//products can't be dispensed, nothing is changed
+		if s.state == STATE_CANCELLED {
+			return err
+		}
+
+		fmt.Fprintf(output, "%+v, press ENTER key to checkout again or type \"exit\" to cancel\n", err)
+
+		userContinueCheckout, ok := s.readLine(userInput, output)
+		if !ok {
+			return nil
+		}
+
+		if userContinueCheckout == "exit" {
+			return s.cancel(STATUS_INSUFFICIENT_CHANGE)
+		}
+
+		err = s.ReturnMoney()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//readMoney - receive money from user until the payment is enough or user type "cancel"
+func (s *Session) readMoney(userInput io.Reader, output io.Writer) error {
+
+	//loop until user pay more than total product's amount
+	for s.PaidAmount() < s.totalAmount {
+		fmt.Fprintln(output, "\nTotal amount left: ", s.totalAmount-s.PaidAmount(), "THB")
+
+		//money's stock is low, show the indicator and what can't be inserted now
+		names := []string{}
+		for _, rejected := range s.RejectedMoney() {
+			names = append(names, rejected.Name)
+		}
+		switch {
+		case s.ExactChangeOnly() && len(names) > 0:
+			fmt.Fprintf(output, "EXACT CHANGE ONLY, %v can't be accepted now\n", strings.Join(names, ", "))
+		case s.ExactChangeOnly():
+			fmt.Fprintln(output, "EXACT CHANGE ONLY")
+		case len(names) > 0:
+			fmt.Fprintf(output, "%v can't be accepted now\n", strings.Join(names, ", "))
+		}
+		fmt.Fprintf(output, "Please select money to insert (%v) or type \"cancel\" to get your money back: ", s.acceptedMoney())
+
+		selectedCoin, ok := s.readLine(userInput, output)
+		if !ok {
+			return nil
+		}
+
+		//return money inserted so far
+		if selectedCoin == "cancel" {
+			return s.Cancel()
+		}
+
+		err := s.Insert(selectedCoin)
+		if err != nil {
+			//money is not accepted, let user insert again
+			if s.state == STATE_AWAITING_PAYMENT {
+				fmt.Fprintf(output, "%+v, please try again\n\n", err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+//Expire - cancel the purchase and return every received money to user if there is no activity
+//longer than the timeout of the current phase, return true if it's cancelled
+func (s *Session) Expire() bool {
+	timeout := s.timeout()
+	if timeout <= 0 {
+		return false
+	}
+	if s.machine.clock().Now().Sub(s.lastActivity) < timeout {
+		return false
+	}
+	s.cancel(STATUS_TIMED_OUT)
+
+	//it's cancelled even if it can't be recorded
+	return s.state == STATE_CANCELLED
+}
+
+//timeout - inactivity allowed in the current phase, zero for waiting forever
+func (s *Session) timeout() time.Duration {
+	switch s.state {
+	case STATE_SELECTING:
+		return s.machine.SelectionTimeout
+	case STATE_AWAITING_PAYMENT:
+		return s.machine.PaymentTimeout
+	}
+	return 0
+}
+
+//readLine - read a line from userInput within the timeout of the current phase,
+//false if the timeout has passed or input of a LineReader has ended then the purchase is cancelled,
+//userInput should be a LineReader shared with every other reader of the same input when there is a timeout
+func (s *Session) readLine(userInput io.Reader, output io.Writer) (string, bool) {
+	timeout := s.timeout()
+	lines, shared := userInput.(*LineReader)
+
+	//no timeout, read in place as usual
+	if timeout <= 0 && !shared && s.lines == nil {
+		var line string
+		fmt.Fscanln(userInput, &line)
+		return line, true
+	}
+
+	//fmt.Fscanln can't be interrupted, so lines are read in background and the same reader is kept
+	//for the next reads because a line may still be being read when the timeout passes
+	if !shared {
+		if s.lines == nil || s.linesInput != userInput {
+			s.lines = NewLineReader(userInput)
+			s.linesInput = userInput
+		}
+		lines = s.lines
+	}
+
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timedOut = s.machine.clock().After(timeout)
+	}
+	line, ok := lines.ReadLineBefore(timedOut)
+	if !ok {
+		fmt.Fprintln(output, "\nsession timed out, please take your money back")
+		s.cancel(STATUS_TIMED_OUT)
+		return "", false
+	}
+
+	//nothing more can be read, so the purchase can't go on and the error of input is left to its next reader
+	if lines.ended {
+		fmt.Fprintln(output, "\ninput has ended, please take your money back")
+		s.cancel(STATUS_CANCELLED)
+		return "", false
+	}
+	return line, true
+}
+
+//acceptedMoney - names of the machine's money from the lowest value, e.g. "1, 5, 10, 20"
+func (s *Session) acceptedMoney() string {
+	names := []string{}
+	moneyList := s.machine.Bank.List()
+	for i := len(moneyList) - 1; i >= 0; i-- {
+		names = append(names, moneyList[i].Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+//can - error if event is not allowed in the current state
+func (s *Session) can(event string) error {
+	_, ok := transitions[s.state][event]
+	if !ok {
+		return fmt.Errorf("%v is not allowed while session is %v", event, s.state)
+	}
+	return nil
+}
+
+//transit - move to the next state by event, event must be allowed
+func (s *Session) transit(event string) {
+	transition := Transition{
+		From:  s.state,
+		To:    transitions[s.state][event],
+		Event: event,
+	}
+	s.state = transition.To
+	s.lastActivity = s.machine.clock().Now()
+	if s.OnTransition != nil {
+		s.OnTransition(transition)
+	}
+}
+
+//clear - forget everything of the purchase and release products held for it, the next purchase has a new ID
+func (s *Session) clear() {
+	s.machine.Inventory.Release(s.id)
+	s.id = newSessionID(s.machine.clock().Now())
+	s.buyedProducts = make(map[product.Product]int64)
+	s.slots = make(map[string]int64)
+	s.totalAmount = 0
+	s.receivedMoney = make(map[money.Money]int64)
+	s.changeList = []money.Money{}
+	s.cancelStatus = ""
+	s.paymentStart = time.Time{}
+}
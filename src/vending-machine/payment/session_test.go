@@ -0,0 +1,1142 @@
+package payment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+	"vending-machine/money"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Session_transitions(t *testing.T) {
+	tests := []struct {
+		description         string
+		prepData            func(s *Session) error
+		expectedState       string
+		expectedTransitions []Transition
+		expectedError       error
+		hasError            bool
+	}{
+		{
+			description: "test_session_success_done",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("10")
+				return s.Checkout()
+			},
+			expectedState: STATE_DONE,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+				{From: STATE_SELECTING, To: STATE_SELECTING, Event: EVENT_SELECT},
+				{From: STATE_SELECTING, To: STATE_AWAITING_PAYMENT, Event: EVENT_FINISH_SELECTION},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_AWAITING_PAYMENT, Event: EVENT_INSERT},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_DISPENSING, Event: EVENT_CHECKOUT},
+				{From: STATE_DISPENSING, To: STATE_RETURNING_CHANGE, Event: EVENT_DISPENSE},
+				{From: STATE_RETURNING_CHANGE, To: STATE_DONE, Event: EVENT_RETURN_CHANGE},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_session_success_cancelled",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				return s.Cancel()
+			},
+			expectedState: STATE_CANCELLED,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+				{From: STATE_SELECTING, To: STATE_SELECTING, Event: EVENT_SELECT},
+				{From: STATE_SELECTING, To: STATE_AWAITING_PAYMENT, Event: EVENT_FINISH_SELECTION},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_AWAITING_PAYMENT, Event: EVENT_INSERT},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_CANCELLED, Event: EVENT_CANCEL},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_session_success_reset",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Cancel()
+				return s.Reset()
+			},
+			expectedState: STATE_IDLE,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+				{From: STATE_SELECTING, To: STATE_CANCELLED, Event: EVENT_CANCEL},
+				{From: STATE_CANCELLED, To: STATE_IDLE, Event: EVENT_RESET},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_session_failed_insert_while_selecting",
+			prepData: func(s *Session) error {
+				s.Start()
+				return s.Insert("10")
+			},
+			expectedState: STATE_SELECTING,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+			},
+			expectedError: errors.New("insert is not allowed while session is selecting"),
+			hasError:      true,
+		},
+		{
+			description: "test_session_failed_select_before_start",
+			prepData: func(s *Session) error {
+				return s.Select("1")
+			},
+			expectedState:       STATE_IDLE,
+			expectedTransitions: []Transition{},
+			expectedError:       errors.New("select is not allowed while session is idle"),
+			hasError:            true,
+		},
+		{
+			description: "test_session_failed_finish_selection_without_product",
+			prepData: func(s *Session) error {
+				s.Start()
+				return s.FinishSelection()
+			},
+			expectedState: STATE_SELECTING,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+			},
+			expectedError: errors.New("you have not select any product"),
+			hasError:      true,
+		},
+		{
+			description: "test_session_failed_payment_is_not_enough",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				return s.Checkout()
+			},
+			expectedState: STATE_AWAITING_PAYMENT,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+				{From: STATE_SELECTING, To: STATE_SELECTING, Event: EVENT_SELECT},
+				{From: STATE_SELECTING, To: STATE_SELECTING, Event: EVENT_SELECT},
+				{From: STATE_SELECTING, To: STATE_AWAITING_PAYMENT, Event: EVENT_FINISH_SELECTION},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_AWAITING_PAYMENT, Event: EVENT_INSERT},
+			},
+			expectedError: errors.New("payment is not enough"),
+			hasError:      true,
+		},
+		{
+			description: "test_session_failed_cancel_when_done",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				s.Checkout()
+				return s.Cancel()
+			},
+			expectedState: STATE_DONE,
+			expectedTransitions: []Transition{
+				{From: STATE_IDLE, To: STATE_SELECTING, Event: EVENT_START},
+				{From: STATE_SELECTING, To: STATE_SELECTING, Event: EVENT_SELECT},
+				{From: STATE_SELECTING, To: STATE_AWAITING_PAYMENT, Event: EVENT_FINISH_SELECTION},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_AWAITING_PAYMENT, Event: EVENT_INSERT},
+				{From: STATE_AWAITING_PAYMENT, To: STATE_DISPENSING, Event: EVENT_CHECKOUT},
+				{From: STATE_DISPENSING, To: STATE_RETURNING_CHANGE, Event: EVENT_DISPENSE},
+				{From: STATE_RETURNING_CHANGE, To: STATE_DONE, Event: EVENT_RETURN_CHANGE},
+			},
+			expectedError: errors.New("cancel is not allowed while session is done"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			session := transactionPrepData().NewSession()
+			transitions := []Transition{}
+			session.OnTransition = func(transition Transition) {
+				transitions = append(transitions, transition)
+			}
+
+			err := test.prepData(session)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedState, session.State())
+			assert.Equal(t, test.expectedTransitions, transitions)
+		})
+	}
+}
+
+func Test_Session_dispense_failed(t *testing.T) {
+	machine := transactionPrepData()
+	machine.failpoint = func(step string) error {
+		if step == STEP_PAYOUT {
+			return errors.New("machine stopped after " + step)
+		}
+		return nil
+	}
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("10")
+
+	err := session.Checkout()
+	assert.Equal(t, errors.New("machine stopped after "+STEP_PAYOUT), err)
+	assert.Equal(t, STATE_CANCELLED, session.State())
+	assert.Equal(t, transactionPrepData().State(), machine.State())
+	assert.False(t, session.Receipt().IsSuccessful)
+}
+
+func Test_Session_ReadPayment(t *testing.T) {
+	tests := []struct {
+		description          string
+		quantity             int
+		input                string
+		fiveCoinStock        int64
+		expectedState        string
+		expectedReceipt      Receipt
+		expectedProductStock []product.Product
+		expectedOutput       []string
+	}{
+		{
+			description:   "test_read_payment_success",
+			quantity:      1,
+			input:         "2\n10\n",
+			fiveCoinStock: 1,
+			expectedState: STATE_DONE,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}},
+				TotalAmount:  money.Baht(5),
+				IsSuccessful: true,
+				Status:       STATUS_SUCCESSFUL,
+				Paid:         []ReceiptMoney{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Quantity: 1}},
+				Change:       []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+				Returned:     []ReceiptMoney{},
+			},
+			expectedProductStock: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+			expectedOutput: []string{
+				"------------ Checkout ------------",
+				"money doesn't excepted, please try again",
+			},
+		},
+		{
+			description:   "test_read_payment_success_after_money_is_rejected",
+			quantity:      1,
+			input:         "10\n5\n",
+			fiveCoinStock: 0,
+			expectedState: STATE_DONE,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}},
+				TotalAmount:  money.Baht(5),
+				IsSuccessful: true,
+				Status:       STATUS_SUCCESSFUL,
+				Paid:         []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+				Change:       []ReceiptMoney{},
+				Returned:     []ReceiptMoney{},
+			},
+			expectedProductStock: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+			expectedOutput: []string{
+				"exact change only, 10 is rejected because its change can't be made, please try again",
+			},
+		},
+		{
+			description:   "test_read_payment_cancelled_while_inserting_money",
+			quantity:      2,
+			input:         "5\ncancel\n",
+			fiveCoinStock: 1,
+			expectedState: STATE_CANCELLED,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 2}},
+				TotalAmount:  money.Baht(10),
+				IsSuccessful: false,
+				Status:       STATUS_CANCELLED,
+				Paid:         []ReceiptMoney{},
+				Change:       []ReceiptMoney{},
+				Returned:     []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+			},
+			expectedProductStock: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+			expectedOutput: []string{
+				"Total amount left:  5 THB",
+			},
+		},
+		{
+			description:   "test_read_payment_cancelled_after_money_is_rejected",
+			quantity:      1,
+			input:         "10\ncancel\n",
+			fiveCoinStock: 0,
+			expectedState: STATE_CANCELLED,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}},
+				TotalAmount:  money.Baht(5),
+				IsSuccessful: false,
+				Status:       STATUS_CANCELLED,
+				Paid:         []ReceiptMoney{},
+				Change:       []ReceiptMoney{},
+				Returned:     []ReceiptMoney{},
+			},
+			expectedProductStock: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+			expectedOutput: []string{
+				"exact change only, 10 is rejected because its change can't be made, please try again",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := transactionPrepData()
+			machine.Bank.Money[1].Stock = test.fiveCoinStock
+			session := machine.NewSession()
+			session.Start()
+			for i := 0; i < test.quantity; i++ {
+				session.Select("1")
+			}
+			session.FinishSelection()
+
+			output := &bytes.Buffer{}
+			err := session.ReadPayment(strings.NewReader(test.input), output)
+			assert.NoError(t, err)
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+			assert.Equal(t, test.expectedState, session.State())
+			assert.Equal(t, test.expectedReceipt, session.Receipt())
+			assert.Equal(t, test.expectedProductStock, machine.Inventory.Products)
+		})
+	}
+}
+
+func Test_Session_exact_change_only(t *testing.T) {
+	tests := []struct {
+		description           string
+		fiveCoinStock         int64
+		inserted              []string
+		insert                string
+		expectedRejectedMoney []money.Money
+		expectedExactChange   bool
+		expectedError         error
+		hasError              bool
+	}{
+		{
+			description:           "test_insert_success_change_can_be_made",
+			fiveCoinStock:         1,
+			insert:                "10",
+			expectedRejectedMoney: []money.Money{},
+			expectedExactChange:   false,
+			hasError:              false,
+		},
+		{
+			description:           "test_insert_success_exact_payment",
+			fiveCoinStock:         0,
+			insert:                "5",
+			expectedRejectedMoney: []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}},
+			expectedExactChange:   true,
+			hasError:              false,
+		},
+		{
+			description:           "test_insert_success_change_from_received_money",
+			fiveCoinStock:         0,
+			inserted:              []string{"5"},
+			insert:                "10",
+			expectedRejectedMoney: []money.Money{},
+			expectedExactChange:   true,
+			hasError:              false,
+		},
+		{
+			description:           "test_insert_failed_change_can_not_be_made",
+			fiveCoinStock:         0,
+			insert:                "10",
+			expectedRejectedMoney: []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}},
+			expectedError:         errors.New("exact change only, 10 is rejected because its change can't be made"),
+			expectedExactChange:   true,
+			hasError:              true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			//10 can be paid for Hanami, so 10 rejected for Lays is a sign of low money's stock
+			machine := transactionPrepData()
+			machine.Inventory.AddProduct(product.Product{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 10})
+			machine.Bank.Money[1].Stock = test.fiveCoinStock
+			session := machine.NewSession()
+			session.Start()
+			session.Select("1")
+			if len(test.inserted) > 0 {
+				session.Select("1")
+			}
+			session.FinishSelection()
+			for _, inserted := range test.inserted {
+				session.Insert(inserted)
+			}
+
+			assert.Equal(t, test.expectedRejectedMoney, session.RejectedMoney())
+			assert.Equal(t, test.expectedExactChange, session.ExactChangeOnly())
+
+			err := session.Insert(test.insert)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, STATE_AWAITING_PAYMENT, session.State())
+				assert.NoError(t, session.Cancel())
+			} else {
+				assert.NoError(t, err)
+				assert.NoError(t, session.Checkout())
+			}
+		})
+	}
+}
+
+func Test_Session_timeout(t *testing.T) {
+	tests := []struct {
+		description        string
+		input              string
+		waitsBeforeTimeout int
+		expectedReceipt    Receipt
+		expectedOutput     []string
+	}{
+		{
+			description:        "test_timeout_while_selecting",
+			input:              "1\n",
+			waitsBeforeTimeout: 2,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}},
+				TotalAmount:  money.Baht(5),
+				IsSuccessful: false,
+				Status:       STATUS_TIMED_OUT,
+				Paid:         []ReceiptMoney{},
+				Change:       []ReceiptMoney{},
+				Returned:     []ReceiptMoney{},
+			},
+			expectedOutput: []string{
+				"session timed out, please take your money back",
+			},
+		},
+		{
+			description:        "test_timeout_while_paying",
+			input:              "1\n1\n\n5\n",
+			waitsBeforeTimeout: 5,
+			expectedReceipt: Receipt{
+				Products:     []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 2}},
+				TotalAmount:  money.Baht(10),
+				IsSuccessful: false,
+				Status:       STATUS_TIMED_OUT,
+				Paid:         []ReceiptMoney{},
+				Change:       []ReceiptMoney{},
+				Returned:     []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}},
+			},
+			expectedOutput: []string{
+				"Please select money to insert (5, 10) or type \"cancel\" to get your money back: ",
+				"session timed out, please take your money back",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			clock := newFakeClock()
+			machine := transactionPrepData()
+			machine.Clock = clock
+			machine.SelectionTimeout = 30 * time.Second
+			machine.PaymentTimeout = time.Minute
+			session := machine.NewSession()
+			session.Start()
+
+			//user stops typing after input
+			userInput, stopTyping := io.Pipe()
+			defer stopTyping.Close()
+			go io.WriteString(stopTyping, test.input)
+
+			output := &bytes.Buffer{}
+			done := make(chan error)
+			go func() {
+				err := session.ReadSelection(userInput, output)
+				if err == nil && session.State() == STATE_AWAITING_PAYMENT {
+					err = session.ReadPayment(userInput, output)
+				}
+				done <- err
+			}()
+
+			//every line is read before the timeout, then nothing is typed
+			for i := 0; i < test.waitsBeforeTimeout; i++ {
+				<-clock.waiting
+			}
+			clock.Advance(time.Minute)
+
+			err := <-done
+			assert.NoError(t, err)
+			assert.Equal(t, STATE_CANCELLED, session.State())
+			assert.Equal(t, test.expectedReceipt, session.Receipt())
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+			assert.Equal(t, transactionPrepData().State(), machine.State())
+
+			err = session.Reset()
+			assert.NoError(t, err)
+			assert.Equal(t, STATE_IDLE, session.State())
+		})
+	}
+}
+
+func Test_Session_ReadSelection_timeout_leaves_next_line(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	machine.SelectionTimeout = 30 * time.Second
+	session := machine.NewSession()
+	session.Start()
+
+	userInput, typing := io.Pipe()
+	defer typing.Close()
+	lines := NewLineReader(userInput)
+
+	done := make(chan error)
+	go func() {
+		done <- session.ReadSelection(lines, &bytes.Buffer{})
+	}()
+	<-clock.waiting
+	clock.Advance(time.Minute)
+	assert.NoError(t, <-done)
+	assert.Equal(t, STATE_CANCELLED, session.State())
+
+	//line typed after the timeout goes to the next prompt that reads the same input
+	go io.WriteString(typing, "exit\n")
+	var userContinue string
+	fmt.Fscanln(lines, &userContinue)
+	assert.Equal(t, "exit", userContinue)
+}
+
+func Test_Session_ReadPayment_cancelled_when_input_fails(t *testing.T) {
+	machine := transactionPrepData()
+	session := machine.NewSession()
+	session.Start()
+
+	readErr := errors.New("input/output error")
+	lines := NewLineReader(io.MultiReader(strings.NewReader("1\n1\n\n5\n"), iotest.ErrReader(readErr)))
+	output := &bytes.Buffer{}
+	assert.NoError(t, session.ReadSelection(lines, output))
+	assert.NoError(t, session.ReadPayment(lines, output))
+
+	//money inserted before input failed is returned and the error is left to the next reader
+	assert.Equal(t, STATE_CANCELLED, session.State())
+	assert.Equal(t, STATUS_CANCELLED, session.Receipt().Status)
+	assert.Contains(t, output.String(), "input has ended, please take your money back")
+	assert.Equal(t, readErr, lines.Err())
+	assert.Equal(t, transactionPrepData().State(), machine.State())
+}
+
+func Test_Session_pending_transactions_of_concurrent_sessions(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	stateStore := &memoryStore{}
+	machine.Store = stateStore
+
+	first := machine.NewSession()
+	first.Start()
+	first.Select("1")
+	assert.NoError(t, first.FinishSelection())
+	second := machine.NewSession()
+	second.Start()
+	second.Select("1")
+	assert.NoError(t, second.FinishSelection())
+
+	//only the finished session's pending transaction is removed, others are kept by restock too
+	assert.NoError(t, second.Cancel())
+	assert.NoError(t, machine.RestockProduct(1, 1))
+
+	pending := &PendingTransaction{StartedAt: clock.Now(), TotalAmount: money.Baht(5), Products: map[int64]int64{1: 1}}
+	assert.Equal(t, map[string]*PendingTransaction{first.ID(): pending, second.ID(): pending}, stateStore.states[1].Pending)
+	assert.Equal(t, map[string]*PendingTransaction{first.ID(): pending}, stateStore.states[2].Pending)
+	assert.Equal(t, map[string]*PendingTransaction{first.ID(): pending}, stateStore.states[3].Pending)
+
+	//nothing is pending after the first session is paid
+	first.Insert("5")
+	assert.NoError(t, first.Checkout())
+	assert.Empty(t, stateStore.states[len(stateStore.states)-1].Pending)
+}
+
+//changeContentionPrepData - two sessions that paid 10 THB for a 5 THB Lays while the machine has one 5 THB coin for change
+func changeContentionPrepData() (*Machine, *Session, *Session) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 2},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0},
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 1},
+	})
+
+	sessions := []*Session{machine.NewSession(), machine.NewSession()}
+	for _, session := range sessions {
+		session.Start()
+		session.Select("1")
+		session.FinishSelection()
+		session.Insert("10")
+	}
+	return machine, sessions[0], sessions[1]
+}
+
+func Test_Session_Checkout_change_taken_by_other_session(t *testing.T) {
+	machine, first, second := changeContentionPrepData()
+	events := &memoryEvents{}
+	machine.Events = events
+
+	assert.NoError(t, first.Checkout())
+
+	//the only 5 THB coin is used up, so the second purchase waits for payment that can be changed
+	assert.Error(t, second.Checkout())
+	assert.Equal(t, STATE_AWAITING_PAYMENT, second.State())
+	assert.Equal(t, money.Baht(10), second.PaidAmount())
+	assert.Equal(t, EVENT_CHANGE_FAILED, events.events[len(events.events)-1].Type)
+	assert.Equal(t, int64(1), machine.Inventory.Products[0].Stock)
+
+	//money is returned, then it can be paid with exact money
+	assert.NoError(t, second.ReturnMoney())
+	assert.NoError(t, second.Insert("5"))
+	assert.NoError(t, second.Checkout())
+	assert.Equal(t, int64(0), machine.Inventory.Products[0].Stock)
+}
+
+func Test_Session_Checkout_concurrent_change(t *testing.T) {
+	machine, first, second := changeContentionPrepData()
+
+	errs := make(chan error)
+	for _, session := range []*Session{first, second} {
+		go func(session *Session) {
+			errs <- session.Checkout()
+		}(session)
+	}
+	firstErr, secondErr := <-errs, <-errs
+
+	//exactly one purchase is changed with the 5 THB coin, the other one waits for payment
+	assert.True(t, (firstErr == nil) != (secondErr == nil))
+	states := []string{first.State(), second.State()}
+	assert.ElementsMatch(t, []string{STATE_DONE, STATE_AWAITING_PAYMENT}, states)
+	assert.Equal(t, int64(1), machine.Inventory.Products[0].Stock)
+	assert.Equal(t, []money.Money{
+		{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1},
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	}, machine.Bank.List())
+}
+
+func Test_Session_Expire(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	machine.PaymentTimeout = time.Minute
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+
+	//no selection timeout
+	clock.Advance(time.Hour)
+	assert.False(t, session.Expire())
+
+	session.FinishSelection()
+	session.Insert("10")
+	clock.Advance(59 * time.Second)
+	assert.False(t, session.Expire())
+	assert.Equal(t, STATE_AWAITING_PAYMENT, session.State())
+
+	clock.Advance(time.Second)
+	assert.True(t, session.Expire())
+	assert.Equal(t, STATE_CANCELLED, session.State())
+	assert.Equal(t, STATUS_TIMED_OUT, session.Receipt().Status)
+	assert.Equal(t, []ReceiptMoney{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Quantity: 1}}, session.Receipt().Returned)
+}
+
+//memoryLedger - Ledger that keeps every appended entry
+type memoryLedger struct {
+	entries []LedgerEntry
+	err     error
+}
+
+func (l *memoryLedger) Append(entry LedgerEntry) error {
+	if l.err != nil {
+		return l.err
+	}
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func Test_Session_records_ledger(t *testing.T) {
+	tests := []struct {
+		description    string
+		prepData       func(s *Session) error
+		ledgerError    error
+		expectedStatus []string
+		expectedError  error
+		hasError       bool
+	}{
+		{
+			description: "test_record_success_done",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("10")
+				return s.Checkout()
+			},
+			expectedStatus: []string{STATUS_SUCCESSFUL},
+			hasError:       false,
+		},
+		{
+			description: "test_record_success_cancelled_then_done",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				s.Cancel()
+				s.Reset()
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				return s.Checkout()
+			},
+			expectedStatus: []string{STATUS_CANCELLED, STATUS_SUCCESSFUL},
+			hasError:       false,
+		},
+		{
+			description: "test_record_success_payment_is_not_enough_is_not_finished",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("5")
+				return s.Checkout()
+			},
+			expectedStatus: []string{},
+			expectedError:  errors.New("payment is not enough"),
+			hasError:       true,
+		},
+		{
+			description: "test_record_failed_ledger_error",
+			prepData: func(s *Session) error {
+				s.Start()
+				s.Select("1")
+				s.FinishSelection()
+				s.Insert("10")
+				return s.Checkout()
+			},
+			ledgerError:    errors.New("disk is full"),
+			expectedStatus: []string{},
+			expectedError:  errors.New("disk is full"),
+			hasError:       true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			clock := newFakeClock()
+			machine := transactionPrepData()
+			machine.Clock = clock
+			salesLedger := &memoryLedger{err: test.ledgerError}
+			machine.Ledger = salesLedger
+			session := machine.NewSession()
+
+			err := test.prepData(session)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			statuses := []string{}
+			for _, entry := range salesLedger.entries {
+				assert.Equal(t, clock.Now(), entry.Time)
+				statuses = append(statuses, entry.Receipt.Status)
+			}
+			assert.Equal(t, test.expectedStatus, statuses)
+		})
+	}
+}
+
+func Test_Session_records_ledger_when_timed_out(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	machine.PaymentTimeout = time.Minute
+	salesLedger := &memoryLedger{}
+	machine.Ledger = salesLedger
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("5")
+
+	clock.Advance(time.Minute)
+	assert.True(t, session.Expire())
+	assert.Equal(t, []LedgerEntry{{Time: clock.Now(), Receipt: session.Receipt()}}, salesLedger.entries)
+	assert.Equal(t, STATUS_TIMED_OUT, salesLedger.entries[0].Receipt.Status)
+}
+
+//memoryMetrics - Metrics that keeps everything it's told
+type memoryMetrics struct {
+	stocks           []State
+	receipts         []Receipt
+	paymentDurations []time.Duration
+	changeFailures   []string
+}
+
+func (mm *memoryMetrics) Stock(state State) {
+	mm.stocks = append(mm.stocks, state)
+}
+
+func (mm *memoryMetrics) Purchase(receipt Receipt, paymentDuration time.Duration) {
+	mm.receipts = append(mm.receipts, receipt)
+	mm.paymentDurations = append(mm.paymentDurations, paymentDuration)
+}
+
+func (mm *memoryMetrics) ChangeFailed(stage string) {
+	mm.changeFailures = append(mm.changeFailures, stage)
+}
+
+func Test_Session_counts_metrics(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	machineMetrics := &memoryMetrics{}
+	machine.Metrics = machineMetrics
+	session := machine.NewSession()
+
+	//successful purchase paid in 30 seconds
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	clock.Advance(30 * time.Second)
+	assert.NoError(t, session.Insert("10"))
+	assert.NoError(t, session.Checkout())
+	assert.Equal(t, []State{machine.State()}, machineMetrics.stocks)
+	assert.Equal(t, []time.Duration{30 * time.Second}, machineMetrics.paymentDurations)
+	assert.Equal(t, STATUS_SUCCESSFUL, machineMetrics.receipts[0].Status)
+
+	//5 THB coin for change is used up, so 10 THB coin is rejected then the purchase is cancelled
+	session.Reset()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	assert.Error(t, session.Insert("10"))
+	clock.Advance(5 * time.Second)
+	assert.NoError(t, session.Cancel())
+	assert.Equal(t, []string{STAGE_INSERT}, machineMetrics.changeFailures)
+	assert.Equal(t, []time.Duration{30 * time.Second, 5 * time.Second}, machineMetrics.paymentDurations)
+	assert.Equal(t, STATUS_CANCELLED, machineMetrics.receipts[1].Status)
+
+	//stock is counted after every change of it
+	assert.NoError(t, machine.RestockProduct(1, 5))
+	assert.Equal(t, int64(14), machineMetrics.stocks[len(machineMetrics.stocks)-1].Products[0].Stock)
+}
+
+//memoryEvents - EventSink that keeps every written event
+type memoryEvents struct {
+	events []Event
+}
+
+func (e *memoryEvents) Write(event Event) {
+	e.events = append(e.events, event)
+}
+
+func Test_Session_writes_events(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	events := &memoryEvents{}
+	machine.Events = events
+	session := machine.NewSession()
+	firstID := session.ID()
+
+	//successful purchase, 10 THB is changed with the only 5 THB coin
+	session.Start()
+	session.Select("9")
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("3")
+	session.Insert("10")
+	session.Checkout()
+
+	//5 THB coin for change is used up, so 10 THB coin is rejected then the purchase is cancelled
+	session.Reset()
+	secondID := session.ID()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("10")
+	session.Insert("5")
+	session.Cancel()
+
+	lays := []ReceiptProduct{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Quantity: 1}}
+	fiveCoin := []ReceiptMoney{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Quantity: 1}}
+	rejectedReason := "exact change only, 10 is rejected because its change can't be made"
+	assert.Equal(t, []Event{
+		{Type: EVENT_PRODUCT_REJECTED, Time: clock.Now(), SessionID: firstID, Input: "9", Reason: "product doesn't exist"},
+		{Type: EVENT_PRODUCT_SELECTED, Time: clock.Now(), SessionID: firstID, ProductNo: 1, Name: "Lays", Amount: money.Baht(5)},
+		{Type: EVENT_COIN_REJECTED, Time: clock.Now(), SessionID: firstID, Input: "3", Reason: "money doesn't excepted"},
+		{Type: EVENT_COIN_INSERTED, Time: clock.Now(), SessionID: firstID, Name: "10", Amount: money.Baht(10)},
+		{Type: EVENT_DISPENSED, Time: clock.Now(), SessionID: firstID, Amount: money.Baht(5), Products: lays},
+		{Type: EVENT_CHANGE_RETURNED, Time: clock.Now(), SessionID: firstID, Amount: money.Baht(5), Money: fiveCoin},
+		{Type: EVENT_PRODUCT_SELECTED, Time: clock.Now(), SessionID: secondID, ProductNo: 1, Name: "Lays", Amount: money.Baht(5)},
+		{Type: EVENT_CHANGE_FAILED, Time: clock.Now(), SessionID: secondID, Stage: STAGE_INSERT, Amount: money.Baht(5), Reason: rejectedReason},
+		{Type: EVENT_COIN_REJECTED, Time: clock.Now(), SessionID: secondID, Input: "10", Name: "10", Amount: money.Baht(10), Reason: rejectedReason},
+		{Type: EVENT_COIN_INSERTED, Time: clock.Now(), SessionID: secondID, Name: "5", Amount: money.Baht(5)},
+		{Type: EVENT_TRANSACTION_CANCELLED, Time: clock.Now(), SessionID: secondID, Amount: money.Baht(5), Reason: STATUS_CANCELLED, Products: lays, Money: fiveCoin},
+	}, events.events)
+	assert.NotEmpty(t, firstID)
+	assert.NotEqual(t, firstID, secondID)
+}
+
+func Test_Session_reserves_selected_products(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	})
+	first := machine.NewSession()
+	second := machine.NewSession()
+	first.Start()
+	second.Start()
+
+	//the last Lays is held by the first customer, so the second can't select it
+	assert.NoError(t, first.Select("1"))
+	assert.Equal(t, errors.New("Lays is out of stock"), second.Select("1"))
+	assert.Equal(t, map[int64]int64{1: 1}, machine.Inventory.Reserved())
+
+	//cancelled purchase releases it
+	assert.NoError(t, first.Cancel())
+	assert.Equal(t, map[int64]int64{}, machine.Inventory.Reserved())
+	assert.NoError(t, second.Select("1"))
+
+	//sold product is released with its stock decreased
+	second.FinishSelection()
+	second.Insert("5")
+	assert.NoError(t, second.Checkout())
+	assert.Equal(t, map[int64]int64{}, machine.Inventory.Reserved())
+	assert.Equal(t, int64(0), machine.Inventory.List()[0].Stock)
+}
+
+func Test_Session_reservation_expires(t *testing.T) {
+	clock := newFakeClock()
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	})
+	machine.Clock = clock
+	machine.ReservationTTL = time.Minute
+	first := machine.NewSession()
+	second := machine.NewSession()
+
+	//the first customer leaves without cancelling
+	first.Start()
+	first.Select("1")
+	first.FinishSelection()
+	first.Insert("5")
+
+	//the hold expires, then the second customer takes the last Lays
+	clock.Advance(time.Minute)
+	second.Start()
+	assert.NoError(t, second.Select("1"))
+	second.FinishSelection()
+
+	//the first customer comes back but the Lays is gone, money can still be returned
+	assert.Equal(t, errors.New("Lays is out of stock"), first.Checkout())
+	assert.Equal(t, STATE_AWAITING_PAYMENT, first.State())
+	assert.NoError(t, first.Cancel())
+
+	second.Insert("5")
+	assert.NoError(t, second.Checkout())
+}
+
+func Test_Session_timed_out_releases_reservation(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	machine.SelectionTimeout = time.Minute
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+	session.Select("1")
+	assert.Equal(t, map[int64]int64{1: 2}, machine.Inventory.Reserved())
+
+	clock.Advance(time.Minute)
+	assert.True(t, session.Expire())
+	assert.Equal(t, map[int64]int64{}, machine.Inventory.Reserved())
+}
+
+func Test_Session_vends_from_selected_slot(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	})
+	assert.NoError(t, machine.Inventory.LoadSlots([]product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 1},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 3},
+		{Code: "A3", ProductNo: 1, Capacity: 5, Stock: 0},
+	}))
+	s := machine.NewSession()
+	s.Start()
+
+	//the piece of a selected slot is vended from that slot even if it's less full,
+	//the piece of a selected product no. is vended from the fullest slot
+	assert.Equal(t, errors.New("slot A3 is empty"), s.Select("A3"))
+	assert.NoError(t, s.Select("a1"))
+	assert.Equal(t, errors.New("slot A1 is empty"), s.Select("A1"))
+	assert.NoError(t, s.Select("1"))
+	assert.NoError(t, s.FinishSelection())
+	assert.NoError(t, s.Insert("5"))
+	assert.NoError(t, s.Insert("5"))
+	assert.NoError(t, s.Checkout())
+
+	state := machine.State()
+	assert.Equal(t, []product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 0},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 2},
+		{Code: "A3", ProductNo: 1, Capacity: 5, Stock: 0},
+	}, state.Slots)
+	assert.Equal(t, int64(2), state.Products[0].Stock)
+
+	//jammed slot's pieces can't be selected
+	assert.NoError(t, machine.SetSlotJammed("A2", true))
+	s.Reset()
+	s.Start()
+	assert.Equal(t, errors.New("slot A2 of Lays is jammed"), s.Select("1"))
+}
+
+func Test_Session_keeps_piece_of_slot_held_by_another_session(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0},
+	})
+	assert.NoError(t, machine.Inventory.LoadSlots([]product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 1},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 1},
+	}))
+
+	//A1 is held for the first session, so the second one buying product no. 1 is vended from A2
+	first := machine.NewSession()
+	first.Start()
+	assert.NoError(t, first.Select("A1"))
+	second := machine.NewSession()
+	second.Start()
+	assert.NoError(t, second.Select("1"))
+	assert.NoError(t, second.FinishSelection())
+	assert.NoError(t, second.Insert("5"))
+	assert.NoError(t, second.Checkout())
+
+	assert.NoError(t, first.FinishSelection())
+	assert.NoError(t, first.Insert("5"))
+	assert.NoError(t, first.Checkout())
+	assert.Equal(t, STATUS_SUCCESSFUL, first.Receipt().Status)
+	assert.Equal(t, []product.Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 0},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 0},
+	}, machine.State().Slots)
+}
+
+func Test_Session_fractional_amounts(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Water", Price: money.Satang(1250), Stock: 2},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0},
+		{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 0},
+		{MoneyType: money.COIN, Name: "0.50", Value: money.Satang(50), Stock: 1},
+		{MoneyType: money.COIN, Name: "0.25", Value: money.Satang(25), Stock: 2},
+	})
+	s := machine.NewSession()
+	s.Start()
+
+	//12.50 paid with 13 is changed with a 50-satang coin
+	assert.NoError(t, s.Select("1"))
+	assert.NoError(t, s.FinishSelection())
+	assert.Equal(t, money.Satang(1250), s.TotalAmount())
+	for _, moneyName := range []string{"10", "1", "1", "1"} {
+		assert.NoError(t, s.Insert(moneyName))
+	}
+	assert.NoError(t, s.Checkout())
+	assert.Equal(t, []money.Money{{MoneyType: money.COIN, Name: "0.50", Value: money.Satang(50)}}, s.ChangeList())
+
+	output := &bytes.Buffer{}
+	PrintReceipt(output, s.Receipt())
+	assert.Contains(t, output.String(), "12.50 THB")
+
+	//12.50 paid with 12 and three 25-satang coins is changed with a 25-satang coin
+	s.Reset()
+	s.Start()
+	assert.NoError(t, s.Select("1"))
+	assert.NoError(t, s.FinishSelection())
+	for _, moneyName := range []string{"10", "1", "1", "0.25", "0.25", "0.25"} {
+		assert.NoError(t, s.Insert(moneyName))
+	}
+	assert.NoError(t, s.Checkout())
+	assert.Equal(t, []money.Money{{MoneyType: money.COIN, Name: "0.25", Value: money.Satang(25)}}, s.ChangeList())
+
+	state := machine.State()
+	assert.Equal(t, int64(0), state.Products[0].Stock)
+	assert.Equal(t, []int64{2, 5, 0, 4}, []int64{state.Money[0].Stock, state.Money[1].Stock, state.Money[2].Stock, state.Money[3].Stock})
+}
+
+func Test_Session_quantities_beyond_int8(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 200, Name: "Water", Price: money.Baht(1), Stock: 200},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "1", Value: money.Baht(1), Stock: 0},
+	})
+	s := machine.NewSession()
+	s.Start()
+
+	//200 cans paid with 200 one-baht coins would overflow int8 quantities
+	for i := 0; i < 200; i++ {
+		assert.NoError(t, s.Select("200"))
+	}
+	assert.Equal(t, errors.New("Water is out of stock"), s.Select("200"))
+	assert.NoError(t, s.FinishSelection())
+	for i := 0; i < 200; i++ {
+		assert.NoError(t, s.Insert("1"))
+	}
+	assert.NoError(t, s.Checkout())
+
+	assert.Equal(t, map[product.Product]int64{{ProductNo: 200, Name: "Water", Price: money.Baht(1)}: 200}, s.BuyedProducts())
+	assert.Equal(t, map[money.Money]int64{{MoneyType: money.COIN, Name: "1", Value: money.Baht(1)}: 200}, s.ReceivedMoney())
+	state := machine.State()
+	assert.Equal(t, int64(0), state.Products[0].Stock)
+	assert.Equal(t, int64(200), state.Money[0].Stock)
+}
+
+func Test_Session_amount_overflows(t *testing.T) {
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Gold", Price: math.MaxInt64, Stock: 2},
+	}, []money.Money{
+		{MoneyType: money.BANK, Name: "max", Value: math.MaxInt64, Stock: 0},
+	})
+	s := machine.NewSession()
+	s.Start()
+
+	//total price that doesn't fit is rejected and the piece isn't held
+	assert.NoError(t, s.Select("1"))
+	assert.Equal(t, errors.New("total price is exceeded, Gold can't be selected"), s.Select("1"))
+	assert.Equal(t, money.Amount(math.MaxInt64), s.TotalAmount())
+	assert.Equal(t, map[int64]int64{1: 1}, machine.Inventory.Reserved())
+
+	//paid amount that doesn't fit is rejected
+	assert.NoError(t, s.FinishSelection())
+	assert.NoError(t, s.Insert("max"))
+	assert.Equal(t, errors.New("paid amount is exceeded, max is rejected"), s.Insert("max"))
+	assert.Equal(t, money.Amount(math.MaxInt64), s.PaidAmount())
+	assert.NoError(t, s.Checkout())
+}
@@ -0,0 +1,143 @@
+package payment
+
+import (
+	"sort"
+	"time"
+	"vending-machine/money"
+	"vending-machine/product"
+)
+
+//State - snapshot of machine's stock and the transactions that are in progress
+type State struct {
+	Products []product.Product `json:"products"`
+	Slots    []product.Slot    `json:"slots,omitempty"`
+	Money    []money.Money     `json:"money"`
+
+	//Pending - transactions in progress by ID of their session, one for each session awaiting payment
+	Pending map[string]*PendingTransaction `json:"pending,omitempty"`
+
+	//LastTransactionID - ID of the last journaled transaction included in the stock
+	LastTransactionID int64 `json:"lastTransactionId,omitempty"`
+}
+
+//PendingTransaction - purchase that has started but has not finished yet,
+//if it is found in saved state on startup, the machine was stopped in the middle of it
+type PendingTransaction struct {
+	StartedAt   time.Time       `json:"startedAt"`
+	TotalAmount money.Amount    `json:"totalAmount"`
+	Products    map[int64]int64 `json:"products"`
+}
+
+//Interrupted - pending transactions of state that were interrupted, the oldest first,
+//a pending transaction whose session's transaction is in recovered was sold by Recover, so it isn't interrupted
+func (s State) Interrupted(recovered []Transaction) []PendingTransaction {
+	recoveredSessions := make(map[string]bool)
+	for _, tx := range recovered {
+		recoveredSessions[tx.SessionID] = true
+	}
+
+	interrupted := []PendingTransaction{}
+	for sessionID, pending := range s.Pending {
+		if !recoveredSessions[sessionID] {
+			interrupted = append(interrupted, *pending)
+		}
+	}
+	sort.Slice(interrupted, func(i, j int) bool {
+		return interrupted[i].StartedAt.Before(interrupted[j].StartedAt)
+	})
+	return interrupted
+}
+
+//StateStore - keep machine's state so it can be reloaded after restart
+type StateStore interface {
+	Save(state State) error
+}
+
+//State - snapshot of machine's stock, changing the snapshot doesn't change the machine
+func (m *Machine) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state()
+}
+
+//state - snapshot of machine's stock, the machine must be locked
+func (m *Machine) state() State {
+	return State{
+		Products:          m.Inventory.List(),
+		Slots:             m.Inventory.ListSlots(),
+		Money:             m.Bank.List(),
+		LastTransactionID: m.lastTransactionID,
+	}
+}
+
+//Restore - replace machine's stock with the stock of state
+func (m *Machine) Restore(state State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Inventory = product.NewInventory(state.Products)
+	m.Inventory.Replace(state.Products, state.Slots)
+	m.Bank = money.NewBank(state.Money)
+	m.lastTransactionID = state.LastTransactionID
+	m.observeStock()
+}
+
+//savePending - lock the machine, then save its state with pending transaction of session sessionID,
+//nil pending for the session's transaction that has finished
+func (m *Machine) savePending(sessionID string, pending *PendingTransaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := m.pending[sessionID]
+	m.setPending(sessionID, pending)
+
+	//pending transactions in memory are kept as they are saved
+	err := m.save()
+	if err != nil {
+		m.setPending(sessionID, previous)
+	}
+	return err
+}
+
+//setPending - set pending transaction of session sessionID, nil for none, the machine must be locked
+func (m *Machine) setPending(sessionID string, pending *PendingTransaction) {
+	if pending == nil {
+		delete(m.pending, sessionID)
+		return
+	}
+	if m.pending == nil {
+		m.pending = make(map[string]*PendingTransaction)
+	}
+	m.pending[sessionID] = pending
+}
+
+//save - save machine's state with every pending transaction to the machine's store if there is one,
+//the machine must be locked
+func (m *Machine) save() error {
+	if m.Store == nil {
+		return nil
+	}
+
+	state := m.state()
+	if len(m.pending) > 0 {
+		state.Pending = make(map[string]*PendingTransaction)
+		for sessionID, pending := range m.pending {
+			state.Pending[sessionID] = pending
+		}
+	}
+	return m.Store.Save(state)
+}
+
+//newPendingTransaction - pending transaction of buyedProducts that starts now by the machine's clock
+func (m *Machine) newPendingTransaction(totalProductAmount money.Amount, buyedProducts map[product.Product]int64) *PendingTransaction {
+	products := make(map[int64]int64)
+	for boughtProduct, amount := range buyedProducts {
+		products[boughtProduct.ProductNo] = products[boughtProduct.ProductNo] + amount
+	}
+
+	return &PendingTransaction{
+		StartedAt:   m.clock().Now(),
+		TotalAmount: totalProductAmount,
+		Products:    products,
+	}
+}
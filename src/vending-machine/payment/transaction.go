@@ -0,0 +1,442 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"vending-machine/money"
+	"vending-machine/product"
+)
+
+const (
+	STEP_BEGIN    = "begin"
+	STEP_PRODUCTS = "products"
+	STEP_DEPOSIT  = "deposit"
+	STEP_PAYOUT   = "payout"
+	STEP_SAVE     = "save"
+	STEP_COMMIT   = "commit"
+)
+
+//Transaction - stock changes of a purchase that are applied or rolled back as a unit
+type Transaction struct {
+	ID int64 `json:"id"`
+
+	//Products - quantity to decrease from product's stock by product no.
+	Products map[int64]int64 `json:"products"`
+
+	//Slots - quantity of Products vended from the slot that user selected by slot code,
+	//the rest of Products is vended from the fullest slots of the product
+	Slots map[string]int64 `json:"slots,omitempty"`
+
+	//Received - quantity to increase to money's stock by money's name
+	Received map[string]int64 `json:"received"`
+
+	//Change - money to decrease from money's stock, one piece each
+	Change []money.Money `json:"change"`
+
+	//SessionID - ID of the session whose purchase is the transaction, its pending transaction is finished by it
+	SessionID string `json:"sessionId,omitempty"`
+}
+
+//Journal - write-ahead log of transactions,
+//intent of a transaction is written before stock is changed so incomplete ones can be found after a crash
+type Journal interface {
+	//Begin - write intent of tx and return it with its new ID
+	Begin(tx Transaction) (Transaction, error)
+	Commit(tx Transaction) error
+	Rollback(tx Transaction) error
+
+	//Incomplete - transactions that began but were neither committed nor rolled back, oldest first
+	Incomplete() ([]Transaction, error)
+}
+
+//newTransaction - transaction of a purchase, selectedSlots is pieces of buyedProducts selected by slot code
+func newTransaction(buyedProducts map[product.Product]int64, selectedSlots map[string]int64, receivedMoney map[money.Money]int64, changeList []money.Money) Transaction {
+	products := make(map[int64]int64)
+	for boughtProduct, amount := range buyedProducts {
+		products[boughtProduct.ProductNo] = products[boughtProduct.ProductNo] + amount
+	}
+
+	var slots map[string]int64
+	if len(selectedSlots) > 0 {
+		slots = make(map[string]int64)
+		for code, amount := range selectedSlots {
+			slots[code] = amount
+		}
+	}
+
+	received := make(map[string]int64)
+	for recMoney, amount := range receivedMoney {
+		received[recMoney.Name] = received[recMoney.Name] + amount
+	}
+
+	change := make([]money.Money, len(changeList))
+	copy(change, changeList)
+
+	return Transaction{
+		Products: products,
+		Slots:    slots,
+		Received: received,
+		Change:   change,
+	}
+}
+
+//changeError - change that can't be made at checkout, nothing is changed so the purchase can still be paid
+type changeError struct {
+	err error
+}
+
+func (e changeError) Error() string {
+	return e.err.Error()
+}
+
+//settle - make change of changeAmount, then apply transaction of a purchase of session sessionID
+//(selectedSlots is pieces of buyedProducts selected by slot code) to the machine's stock and save it without the session's pending transaction, then release products held
+//for the session, changeError is returned without changing anything if change can't be made,
+//if any other step fails the machine's stock and the session's pending transaction are put back to what they were,
+//once the stock is saved the purchase is settled even if the journal can't be committed,
+//then a commit failed event is written and Recover commits the transaction on the next start
+func (m *Machine) settle(sessionID string, buyedProducts map[product.Product]int64, selectedSlots map[string]int64, receivedMoney map[money.Money]int64, changeAmount money.Amount) ([]money.Money, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	//change is made while the machine is locked, so no other purchase takes the same money meanwhile
+	changeList, err := m.Change(changeAmount, receivedMoney)
+	if err != nil {
+		return nil, changeError{err: err}
+	}
+	tx := newTransaction(buyedProducts, selectedSlots, receivedMoney, changeList)
+	tx.SessionID = sessionID
+
+	//write intent before changing anything
+	if m.Journal != nil {
+		tx, err = m.Journal.Begin(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//stock is observed after it's applied or rolled back
+	defer m.observeStock()
+
+	//the pending transaction is finished by the same save as the applied stock,
+	//until then the saved state keeps it, and Recover replays tx if the machine stops in between
+	before := m.state()
+	pending := m.pending[sessionID]
+	m.setPending(sessionID, nil)
+	err = m.apply(tx)
+	if err == nil {
+		err = m.fail(STEP_SAVE)
+	}
+	if err == nil {
+		err = m.save()
+	}
+	if err != nil {
+		m.rollback(before)
+		m.setPending(sessionID, pending)
+		if m.Journal != nil {
+			rollbackErr := m.Journal.Rollback(tx)
+			if rollbackErr != nil {
+				return nil, fmt.Errorf("%v, journal can't be rolled back: %v", err, rollbackErr)
+			}
+		}
+		return nil, err
+	}
+
+	//products are sold, so they don't need to be held anymore
+	m.Inventory.Release(sessionID)
+
+	err = m.fail(STEP_COMMIT)
+	if err == nil && m.Journal != nil {
+		err = m.Journal.Commit(tx)
+	}
+	if err != nil {
+		m.emit(sessionID, Event{Type: EVENT_COMMIT_FAILED, Reason: fmt.Sprintf("transaction %v can't be committed: %v", tx.ID, err)})
+	}
+	return changeList, nil
+}
+
+//apply - change the machine's stock by tx all or nothing, the machine must be locked
+func (m *Machine) apply(tx Transaction) error {
+	err := m.fail(STEP_BEGIN)
+	if err != nil {
+		return err
+	}
+	stockTx := m.Begin()
+
+	buyedProducts := make(map[product.Product]int64)
+	for productNo, amount := range tx.Products {
+		buyedProducts[product.Product{ProductNo: productNo}] = amount
+	}
+	stockTx.StageProducts(buyedProducts)
+	stockTx.StageSlots(tx.Slots)
+	err = m.fail(STEP_PRODUCTS)
+	if err != nil {
+		return err
+	}
+
+	receivedMoney := make(map[money.Money]int64)
+	for moneyName, amount := range tx.Received {
+		receivedMoney[money.Money{Name: moneyName}] = amount
+	}
+	stockTx.StageDeposit(receivedMoney)
+	err = m.fail(STEP_DEPOSIT)
+	if err != nil {
+		return err
+	}
+
+	stockTx.StagePayout(tx.Change)
+	err = m.fail(STEP_PAYOUT)
+	if err != nil {
+		return err
+	}
+
+	err = stockTx.commit()
+	if err != nil {
+		return err
+	}
+
+	m.lastTransactionID = tx.ID
+	return nil
+}
+
+//rollback - put the machine's stock back to before, in place so inventory and bank keep sharing their stock,
+//the machine must be locked
+func (m *Machine) rollback(before State) {
+	m.Inventory.Replace(before.Products, before.Slots)
+	m.Bank.Replace(before.Money)
+	m.lastTransactionID = before.LastTransactionID
+}
+
+//fail - error injected after step for test purpose, nil in normal operation
+func (m *Machine) fail(step string) error {
+	if m.failpoint == nil {
+		return nil
+	}
+	return m.failpoint(step)
+}
+
+//Recover - finish transactions that were incomplete in the machine's journal when the machine stopped,
+//a transaction already in the machine's saved stock is committed, otherwise it is replayed onto the stock
+//and reverted (rolled back) only if it can't be applied anymore
+func (m *Machine) Recover() ([]Transaction, []Transaction, error) {
+	replayed := []Transaction{}
+	reverted := []Transaction{}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Journal == nil {
+		return replayed, reverted, nil
+	}
+
+	incomplete, err := m.Journal.Incomplete()
+	if err != nil {
+		return replayed, reverted, err
+	}
+	defer m.observeStock()
+
+	for _, tx := range incomplete {
+		//stock was saved after the transaction was applied, only the commit was lost
+		if tx.ID <= m.lastTransactionID {
+			err = m.Journal.Commit(tx)
+			if err != nil {
+				return replayed, reverted, err
+			}
+			replayed = append(replayed, tx)
+			continue
+		}
+
+		before := m.state()
+		err = m.apply(tx)
+		if err != nil {
+			m.rollback(before)
+			err = m.Journal.Rollback(tx)
+			if err != nil {
+				return replayed, reverted, err
+			}
+			reverted = append(reverted, tx)
+			continue
+		}
+
+		//if replayed stock can't be saved, leave the transaction incomplete to recover on the next start
+		err = m.save()
+		if err != nil {
+			m.rollback(before)
+			return replayed, reverted, err
+		}
+
+		err = m.Journal.Commit(tx)
+		if err != nil {
+			return replayed, reverted, err
+		}
+		replayed = append(replayed, tx)
+	}
+
+	return replayed, reverted, nil
+}
+
+//StockTx - changes of the machine's product's stock and money's stock that are staged in memory,
+//then validated together and applied all or nothing on Commit
+type StockTx struct {
+	machine  *Machine
+	products map[int64]int64
+	slots    map[string]int64 //pieces of products vended from the slot selected by slot code
+	deposit  map[string]int64
+	payout   map[string]int64
+	finished bool
+}
+
+//Begin - start staging changes of the machine's stock
+func (m *Machine) Begin() *StockTx {
+	return &StockTx{
+		machine:  m,
+		products: make(map[int64]int64),
+		slots:    make(map[string]int64),
+		deposit:  make(map[string]int64),
+		payout:   make(map[string]int64),
+	}
+}
+
+//StageProducts - stage decrement of product's stock by buyedProducts map (products that user buy)
+func (tx *StockTx) StageProducts(buyedProducts map[product.Product]int64) {
+	for boughtProduct, amount := range buyedProducts {
+		tx.products[boughtProduct.ProductNo] = tx.products[boughtProduct.ProductNo] + amount
+	}
+}
+
+//StageSlots - stage pieces of staged products that are vended from the slot that user selected by slot code
+func (tx *StockTx) StageSlots(selectedSlots map[string]int64) {
+	for code, amount := range selectedSlots {
+		tx.slots[code] = tx.slots[code] + amount
+	}
+}
+
+//StageDeposit - stage increment of money's stock by receivedMoney map (money received from user)
+func (tx *StockTx) StageDeposit(receivedMoney map[money.Money]int64) {
+	for recMoney, amount := range receivedMoney {
+		tx.deposit[recMoney.Name] = tx.deposit[recMoney.Name] + amount
+	}
+}
+
+//StagePayout - stage decrement of money's stock by changeList (money that changed to user)
+func (tx *StockTx) StagePayout(changeList []money.Money) {
+	for _, change := range changeList {
+		tx.payout[change.Name] = tx.payout[change.Name] + 1
+	}
+}
+
+//Discard - drop staged changes without applying them
+func (tx *StockTx) Discard() {
+	tx.finished = true
+}
+
+//Commit - validate every staged change then apply all of them, nothing is applied if any is invalid,
+//payout may use money deposited in the same transaction unless it goes to cash box
+func (tx *StockTx) Commit() error {
+	tx.machine.mu.Lock()
+	defer tx.machine.mu.Unlock()
+	return tx.commit()
+}
+
+//commit - validate then apply every staged change, the machine must be locked
+func (tx *StockTx) commit() error {
+	if tx.finished {
+		return errors.New("stock transaction is already finished")
+	}
+
+	m := tx.machine
+	products := m.Inventory.List()
+	slots := m.Inventory.ListSlots()
+	moneyList := m.Bank.List()
+
+	//every staged product and money must exist in the machine
+	for productNo := range tx.products {
+		if !hasProduct(products, productNo) {
+			return errors.New("product doesn't exist")
+		}
+	}
+	for moneyName := range tx.deposit {
+		if !hasMoney(moneyList, moneyName) {
+			return errors.New("money doesn't excepted")
+		}
+	}
+	for moneyName := range tx.payout {
+		if !hasMoney(moneyList, moneyName) {
+			return errors.New("money doesn't excepted")
+		}
+	}
+
+	//pieces selected by slot code are vended from that slot, pieces held for other purchases' slots are kept
+	held := m.Inventory.ReservedSlots()
+	selected := make(map[int64]int64)
+	for code, amount := range tx.slots {
+		productNo, err := product.DrawSlot(slots, code, amount)
+		if err != nil {
+			return err
+		}
+		selected[productNo] = selected[productNo] + amount
+
+		//this purchase's own hold of the slot is drawn now
+		held[code] = held[code] - amount
+		if held[code] <= 0 {
+			delete(held, code)
+		}
+	}
+	for productNo, amount := range selected {
+		if amount > tx.products[productNo] {
+			return errors.New("slot pieces of product no. " + strconv.FormatInt(productNo, 10) + " are more than its staged pieces")
+		}
+	}
+
+	for i, prod := range products {
+		amount := tx.products[prod.ProductNo]
+		if prod.Stock-amount < 0 {
+			return errors.New(prod.Name + "'s stock is less than zero")
+		}
+		products[i].Stock = prod.Stock - amount
+
+		//the rest of the pieces of the planogram are vended from the fullest slots of the product
+		if len(slots) > 0 {
+			err := product.DrawSlots(slots, prod.ProductNo, amount-selected[prod.ProductNo], held)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for i, availMoney := range moneyList {
+		//deposit goes to cash box if it can't be used for change, payout is only from stock
+		deposited, err := availMoney.Deposit(tx.deposit[availMoney.Name])
+		if err != nil {
+			return err
+		}
+		deposited.Stock = deposited.Stock - tx.payout[availMoney.Name]
+		if deposited.Stock < 0 {
+			return errors.New(availMoney.Name + "'s stock is less than zero")
+		}
+		moneyList[i] = deposited
+	}
+
+	//everything is valid, apply in place so inventory and bank keep sharing their stock
+	m.Inventory.Replace(products, slots)
+	m.Bank.Replace(moneyList)
+	tx.finished = true
+	return nil
+}
+
+func hasProduct(products []product.Product, productNo int64) bool {
+	for _, prod := range products {
+		if prod.ProductNo == productNo {
+			return true
+		}
+	}
+	return false
+}
+
+func hasMoney(moneyList []money.Money, moneyName string) bool {
+	for _, availMoney := range moneyList {
+		if availMoney.Name == moneyName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,580 @@
+package payment
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"vending-machine/money"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//memoryJournal - Journal that keeps status of every transaction
+type memoryJournal struct {
+	transactions []Transaction
+	status       map[int64]string
+}
+
+func newMemoryJournal() *memoryJournal {
+	return &memoryJournal{status: make(map[int64]string)}
+}
+
+func (j *memoryJournal) Begin(tx Transaction) (Transaction, error) {
+	tx.ID = int64(len(j.transactions) + 1)
+	j.transactions = append(j.transactions, tx)
+	j.status[tx.ID] = "begin"
+	return tx, nil
+}
+
+func (j *memoryJournal) Commit(tx Transaction) error {
+	j.status[tx.ID] = "commit"
+	return nil
+}
+
+func (j *memoryJournal) Rollback(tx Transaction) error {
+	j.status[tx.ID] = "rollback"
+	return nil
+}
+
+func (j *memoryJournal) Incomplete() ([]Transaction, error) {
+	incomplete := []Transaction{}
+	for _, tx := range j.transactions {
+		if j.status[tx.ID] == "begin" {
+			incomplete = append(incomplete, tx)
+		}
+	}
+	return incomplete, nil
+}
+
+func transactionPrepData() *Machine {
+	return NewMachine([]product.Product{
+		{
+			ProductNo: 1,
+			Name:      "Lays",
+			Price:     money.Baht(5),
+			Stock:     10,
+		},
+	}, []money.Money{
+		{
+			MoneyType: money.COIN,
+			Name:      "10",
+			Value:     money.Baht(10),
+			Stock:     0,
+		},
+		{
+			MoneyType: money.COIN,
+			Name:      "5",
+			Value:     money.Baht(5),
+			Stock:     1,
+		},
+	})
+}
+
+func Test_Machine_settle_rolls_back_failed_step(t *testing.T) {
+	buyedProducts := map[product.Product]int64{{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}: 1}
+	receivedMoney := map[money.Money]int64{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10)}: 1}
+	changeList := []money.Money{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5)}}
+
+	tests := []struct {
+		description    string
+		failedStep     string
+		expectedStatus string
+		expectedState  State
+		hasError       bool
+	}{
+		{
+			description:    "test_settle_success",
+			failedStep:     "",
+			expectedStatus: "commit",
+			expectedState: State{
+				Products:          []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+				Money:             []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+				LastTransactionID: 1,
+			},
+			hasError: false,
+		},
+		{
+			description:    "test_settle_failed_before_products",
+			failedStep:     STEP_BEGIN,
+			expectedStatus: "rollback",
+			expectedState:  transactionPrepData().State(),
+			hasError:       true,
+		},
+		{
+			description:    "test_settle_failed_after_products",
+			failedStep:     STEP_PRODUCTS,
+			expectedStatus: "rollback",
+			expectedState:  transactionPrepData().State(),
+			hasError:       true,
+		},
+		{
+			description:    "test_settle_failed_after_deposit",
+			failedStep:     STEP_DEPOSIT,
+			expectedStatus: "rollback",
+			expectedState:  transactionPrepData().State(),
+			hasError:       true,
+		},
+		{
+			description:    "test_settle_failed_after_payout",
+			failedStep:     STEP_PAYOUT,
+			expectedStatus: "rollback",
+			expectedState:  transactionPrepData().State(),
+			hasError:       true,
+		},
+		{
+			description:    "test_settle_failed_before_save",
+			failedStep:     STEP_SAVE,
+			expectedStatus: "rollback",
+			expectedState:  transactionPrepData().State(),
+			hasError:       true,
+		},
+		{
+			description:    "test_settle_success_when_commit_failed",
+			failedStep:     STEP_COMMIT,
+			expectedStatus: "begin",
+			expectedState: State{
+				Products:          []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+				Money:             []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+				LastTransactionID: 1,
+			},
+			hasError: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := transactionPrepData()
+			journal := newMemoryJournal()
+			stateStore := &memoryStore{}
+			machine.Journal = journal
+			machine.Store = stateStore
+			machine.failpoint = func(step string) error {
+				if step == test.failedStep {
+					return errors.New("machine stopped after " + step)
+				}
+				return nil
+			}
+			pending := machine.newPendingTransaction(money.Baht(5), buyedProducts)
+			machine.setPending("a1", pending)
+
+			actualChangeList, err := machine.settle("a1", buyedProducts, nil, receivedMoney, money.Baht(5))
+			if test.hasError {
+				assert.Equal(t, errors.New("machine stopped after "+test.failedStep), err)
+				assert.Empty(t, actualChangeList)
+				assert.Empty(t, stateStore.states)
+				assert.Equal(t, map[string]*PendingTransaction{"a1": pending}, machine.pending)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, changeList, actualChangeList)
+				assert.Equal(t, []State{test.expectedState}, stateStore.states)
+				assert.Empty(t, machine.pending)
+			}
+			assert.Equal(t, test.expectedState, machine.State())
+			assert.Equal(t, test.expectedStatus, journal.status[1])
+		})
+	}
+}
+
+func Test_Session_Checkout_commit_failed(t *testing.T) {
+	clock := newFakeClock()
+	machine := transactionPrepData()
+	machine.Clock = clock
+	journal := newMemoryJournal()
+	events := &memoryEvents{}
+	machine.Journal = journal
+	machine.Events = events
+	machine.failpoint = func(step string) error {
+		if step == STEP_COMMIT {
+			return errors.New("journal is full")
+		}
+		return nil
+	}
+
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("10")
+
+	//products and change are given, the purchase is successful
+	err := session.Checkout()
+	assert.NoError(t, err)
+	assert.Equal(t, STATE_DONE, session.State())
+	assert.Equal(t, STATUS_SUCCESSFUL, session.Receipt().Status)
+	assert.Equal(t, int64(9), machine.Inventory.Products[0].Stock)
+	assert.Contains(t, events.events,
+		Event{Type: EVENT_COMMIT_FAILED, Time: clock.Now(), SessionID: session.ID(), Reason: "transaction 1 can't be committed: journal is full"})
+
+	//the sold transaction is committed, not replayed again, on the next start
+	machine.failpoint = nil
+	replayed, reverted, err := machine.Recover()
+	assert.NoError(t, err)
+	assert.Len(t, replayed, 1)
+	assert.Empty(t, reverted)
+	assert.Equal(t, "commit", journal.status[1])
+	assert.Equal(t, int64(9), machine.Inventory.Products[0].Stock)
+}
+
+func Test_Session_Checkout_stopped_between_begin_and_save(t *testing.T) {
+	machine := transactionPrepData()
+	journal := newMemoryJournal()
+	stateStore := &memoryStore{}
+	machine.Journal = journal
+	machine.Store = stateStore
+
+	session := machine.NewSession()
+	session.Start()
+	session.Select("1")
+	session.FinishSelection()
+	session.Insert("10")
+
+	//the machine stops after the intent is written and before the sold stock is saved
+	machine.failpoint = func(step string) error {
+		if step == STEP_BEGIN {
+			panic("machine stopped")
+		}
+		return nil
+	}
+	assert.Panics(t, func() { session.Checkout() })
+
+	//saved state still has the pending transaction and the journal has its intent
+	saved := stateStore.states[len(stateStore.states)-1]
+	assert.Len(t, saved.Pending, 1)
+	assert.Equal(t, int64(10), saved.Products[0].Stock)
+	assert.Equal(t, "begin", journal.status[1])
+
+	//on the next start Recover sells it, so it isn't warned as interrupted
+	restarted := transactionPrepData()
+	restarted.Restore(saved)
+	restarted.Journal = journal
+	replayed, reverted, err := restarted.Recover()
+	assert.NoError(t, err)
+	assert.Len(t, replayed, 1)
+	assert.Empty(t, reverted)
+	assert.Equal(t, "commit", journal.status[1])
+	assert.Equal(t, int64(9), restarted.Inventory.Products[0].Stock)
+	assert.Len(t, saved.Interrupted(nil), 1)
+	assert.Empty(t, saved.Interrupted(replayed))
+}
+
+func Test_Machine_Recover(t *testing.T) {
+	tx := Transaction{
+		Products: map[int64]int64{1: 1},
+		Received: map[string]int64{"10": 1},
+		Change:   []money.Money{{MoneyType: money.COIN, Name: "5", Value: money.Baht(5)}},
+	}
+
+	tests := []struct {
+		description      string
+		prepData         func(machine *Machine, journal *memoryJournal)
+		expectedReplayed int
+		expectedReverted int
+		expectedStatus   string
+		expectedState    State
+	}{
+		{
+			description: "test_recover_replays_transaction_not_in_stock",
+			prepData: func(machine *Machine, journal *memoryJournal) {
+				journal.Begin(tx)
+			},
+			expectedReplayed: 1,
+			expectedStatus:   "commit",
+			expectedState: State{
+				Products:          []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+				Money:             []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+				LastTransactionID: 1,
+			},
+		},
+		{
+			description: "test_recover_commits_transaction_already_in_stock",
+			prepData: func(machine *Machine, journal *memoryJournal) {
+				journal.Begin(tx)
+				machine.Restore(State{
+					Products:          []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+					Money:             []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+					LastTransactionID: 1,
+				})
+			},
+			expectedReplayed: 1,
+			expectedStatus:   "commit",
+			expectedState: State{
+				Products:          []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 9}},
+				Money:             []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+				LastTransactionID: 1,
+			},
+		},
+		{
+			description: "test_recover_reverts_transaction_that_can_not_be_applied",
+			prepData: func(machine *Machine, journal *memoryJournal) {
+				journal.Begin(tx)
+				machine.Bank.Money[1].Stock = 0
+			},
+			expectedReverted: 1,
+			expectedStatus:   "rollback",
+			expectedState: State{
+				Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+				Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := transactionPrepData()
+			journal := newMemoryJournal()
+			machine.Journal = journal
+			test.prepData(machine, journal)
+
+			replayed, reverted, err := machine.Recover()
+			assert.NoError(t, err)
+			assert.Len(t, replayed, test.expectedReplayed)
+			assert.Len(t, reverted, test.expectedReverted)
+			assert.Equal(t, test.expectedStatus, journal.status[1])
+			assert.Equal(t, test.expectedState, machine.State())
+
+			//recovering again finds nothing to do
+			replayed, reverted, err = machine.Recover()
+			assert.NoError(t, err)
+			assert.Empty(t, replayed)
+			assert.Empty(t, reverted)
+		})
+	}
+}
+
+func Test_StockTx_Commit(t *testing.T) {
+	tests := []struct {
+		description   string
+		prepData      func(tx *StockTx)
+		expectedState State
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_commit_success",
+			prepData: func(tx *StockTx) {
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 2})
+				tx.StageDeposit(map[money.Money]int64{{Name: "10"}: 1})
+				tx.StagePayout([]money.Money{{Name: "5"}})
+			},
+			expectedState: State{
+				Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 8}},
+				Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_commit_success_with_payout_from_deposit",
+			prepData: func(tx *StockTx) {
+				tx.StageDeposit(map[money.Money]int64{{Name: "10"}: 2})
+				tx.StagePayout([]money.Money{{Name: "10"}, {Name: "5"}})
+			},
+			expectedState: State{
+				Products: []product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}},
+				Money:    []money.Money{{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 1}, {MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 0}},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_commit_failed_product_stock_is_less_than_zero",
+			prepData: func(tx *StockTx) {
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 6})
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 5})
+				tx.StageDeposit(map[money.Money]int64{{Name: "10"}: 1})
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("Lays's stock is less than zero"),
+			hasError:      true,
+		},
+		{
+			description: "test_commit_failed_money_stock_is_less_than_zero",
+			prepData: func(tx *StockTx) {
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 1})
+				tx.StageDeposit(map[money.Money]int64{{Name: "10"}: 1})
+				tx.StagePayout([]money.Money{{Name: "5"}, {Name: "5"}})
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("5's stock is less than zero"),
+			hasError:      true,
+		},
+		{
+			description: "test_commit_failed_money_stock_is_exceeded",
+			prepData: func(tx *StockTx) {
+				tx.StageDeposit(map[money.Money]int64{{Name: "5"}: math.MaxInt64})
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("5's stock is exceeded"),
+			hasError:      true,
+		},
+		{
+			description: "test_commit_failed_product_does_not_exist",
+			prepData: func(tx *StockTx) {
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 1, {ProductNo: 9}: 1})
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("product doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description: "test_commit_failed_money_does_not_accepted",
+			prepData: func(tx *StockTx) {
+				tx.StageDeposit(map[money.Money]int64{{Name: "20"}: 1})
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("money doesn't excepted"),
+			hasError:      true,
+		},
+		{
+			description: "test_commit_failed_discarded",
+			prepData: func(tx *StockTx) {
+				tx.StageProducts(map[product.Product]int64{{ProductNo: 1}: 1})
+				tx.Discard()
+			},
+			expectedState: transactionPrepData().State(),
+			expectedError: errors.New("stock transaction is already finished"),
+			hasError:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			machine := transactionPrepData()
+			tx := machine.Begin()
+			test.prepData(tx)
+
+			err := tx.Commit()
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+
+				//a transaction is committed only once
+				err = tx.Commit()
+				assert.Equal(t, errors.New("stock transaction is already finished"), err)
+			}
+			assert.Equal(t, test.expectedState, machine.State())
+		})
+	}
+}
+
+func Test_StockTx_Commit_deposit_to_cash_box(t *testing.T) {
+	machine := NewMachine([]product.Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 10}}, []money.Money{
+		{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), NonRecyclable: true},
+		{MoneyType: money.BANK, Name: "100", Value: money.Baht(100), Stock: 9, Capacity: 10},
+	})
+
+	tx := machine.Begin()
+	tx.StageDeposit(map[money.Money]int64{{Name: "1000"}: 1, {Name: "100"}: 2})
+	tx.StagePayout([]money.Money{{Name: "100"}})
+	err := tx.Commit()
+	assert.NoError(t, err)
+	assert.Equal(t, []money.Money{
+		{MoneyType: money.BANK, Name: "1000", Value: money.Baht(1000), NonRecyclable: true, CashBox: 1},
+		{MoneyType: money.BANK, Name: "100", Value: money.Baht(100), Stock: 9, Capacity: 10, CashBox: 1},
+	}, machine.Bank.Money)
+
+	//money in cash box can't be paid out
+	tx = machine.Begin()
+	tx.StagePayout([]money.Money{{Name: "1000"}})
+	err = tx.Commit()
+	assert.Equal(t, errors.New("1000's stock is less than zero"), err)
+}
+
+//lockedLedger - Ledger that keeps every appended entry and can be appended by many sessions at once
+type lockedLedger struct {
+	mu      sync.Mutex
+	entries []LedgerEntry
+}
+
+func (l *lockedLedger) Append(entry LedgerEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func Test_Machine_concurrent_sessions(t *testing.T) {
+	const workers = 50
+	const rounds = 4
+	machine := NewMachine([]product.Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 100},
+	}, []money.Money{
+		{MoneyType: money.COIN, Name: "10", Value: money.Baht(10), Stock: 0, Capacity: 100},
+		{MoneyType: money.COIN, Name: "5", Value: money.Baht(5), Stock: 50},
+	})
+	machine.Journal = newMemoryJournal()
+	salesLedger := &lockedLedger{}
+	machine.Ledger = salesLedger
+	initial := machine.State()
+
+	//every worker buys Lays with a 10 THB coin, or a 5 THB coin when change runs out,
+	//while others restock and read the machine's stock
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			session := machine.NewSession()
+			for j := 0; j < rounds; j++ {
+				session.Start()
+				if session.Select("1") != nil {
+					session.Cancel()
+				} else {
+					session.FinishSelection()
+					if session.Insert("10") != nil {
+						session.Insert("5")
+					}
+					if session.Checkout() != nil && session.State() == STATE_AWAITING_PAYMENT {
+						session.Cancel()
+					}
+				}
+				assert.Contains(t, []string{STATE_DONE, STATE_CANCELLED}, session.State())
+				session.Reset()
+
+				if i%10 == 0 {
+					assert.NoError(t, machine.RestockMoney("5", 1))
+				}
+				machine.State()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, salesLedger.entries, workers*rounds)
+
+	//every piece sold or paid by successful purchases is in the machine's stock, nothing else is
+	expected := initial
+	for i := range expected.Money {
+		if expected.Money[i].Name == "5" {
+			expected.Money[i].Stock = expected.Money[i].Stock + workers/10*rounds
+		}
+	}
+	for _, entry := range salesLedger.entries {
+		if entry.Receipt.Status != STATUS_SUCCESSFUL {
+			continue
+		}
+		for _, boughtProduct := range entry.Receipt.Products {
+			expected.Products[0].Stock = expected.Products[0].Stock - boughtProduct.Quantity
+		}
+		for _, paid := range entry.Receipt.Paid {
+			for i := range expected.Money {
+				if expected.Money[i].Name == paid.Name {
+					expected.Money[i], _ = expected.Money[i].Deposit(paid.Quantity)
+				}
+			}
+		}
+		for _, change := range entry.Receipt.Change {
+			for i := range expected.Money {
+				if expected.Money[i].Name == change.Name {
+					expected.Money[i].Stock = expected.Money[i].Stock - change.Quantity
+				}
+			}
+		}
+	}
+
+	final := machine.State()
+	assert.Equal(t, expected.Products, final.Products)
+	for i, mon := range final.Money {
+		assert.GreaterOrEqual(t, mon.Stock, int64(0))
+		assert.Equal(t, expected.Money[i].Stock+expected.Money[i].CashBox, mon.Stock+mon.CashBox, mon.Name)
+	}
+}
@@ -3,69 +3,154 @@ package product
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"sort"
 	"strconv"
+	"sync"
+	"vending-machine/money"
 )
 
 type Product struct {
-	ProductNo int8
+	ProductNo int64
 	Name      string
-	Price     int64
-	Stock     int8
+	Price     money.Amount
+	Stock     int64
 }
 
 var ProductStock = []Product{
 	{
 		ProductNo: 1,
 		Name:      "Lays",
-		Price:     5,
+		Price:     money.Baht(5),
 		Stock:     1,
 	},
 	{
 		ProductNo: 2,
 		Name:      "Hanami",
-		Price:     10,
+		Price:     money.Baht(10),
 		Stock:     10,
 	},
 	{
 		ProductNo: 3,
 		Name:      "Kitkat",
-		Price:     25,
+		Price:     money.Baht(25),
 		Stock:     10,
 	},
 	{
 		ProductNo: 4,
 		Name:      "Pepsi",
-		Price:     15,
+		Price:     money.Baht(15),
 		Stock:     10,
 	},
 }
 
-func ListAllProducts() {
-	fmt.Println("List of products")
-	fmt.Println("No        Name      Price     Stock")
-	fmt.Println("-----------------------------------")
-	for _, product := range ProductStock {
-		fmt.Printf("%-10v%-10v%-10v%-10v\n", product.ProductNo, product.Name, product.Price, product.Stock)
+//Inventory - product's stock owned by a single machine, safe for concurrent use through its methods
+type Inventory struct {
+	Products []Product
+
+	//Slots - planogram that product's stock is vended from, nil for selling product's stock without slots
+	Slots []Slot
+
+	mu           sync.RWMutex
+	reservations map[string]Reservation //pieces held for purchases by purchase's ID
+}
+
+//NewInventory - create inventory from a copy of products
+func NewInventory(products []Product) *Inventory {
+	inventoryProducts := make([]Product, len(products))
+	copy(inventoryProducts, products)
+	return &Inventory{Products: inventoryProducts}
+}
+
+//globalInventory - the only inventory of the global ProductStock, so every use of it is synchronized
+var globalInventory = &Inventory{}
+
+//DefaultInventory - inventory that operates on the global ProductStock
+func DefaultInventory() *Inventory {
+	globalInventory.mu.Lock()
+	defer globalInventory.mu.Unlock()
+	globalInventory.Products = ProductStock
+	return globalInventory
+}
+
+//List - copy of every product, changing it doesn't change the inventory
+func (inv *Inventory) List() []Product {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	products := make([]Product, len(inv.Products))
+	copy(products, inv.Products)
+	return products
+}
+
+//Replace - replace every product with products and the planogram with slots, in place if the number of products
+//is the same so stock shared with the global ProductStock stays shared, product's stock follows slots if there are any
+func (inv *Inventory) Replace(products []Product, slots []Slot) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if len(inv.Products) != len(products) {
+		inv.Products = make([]Product, len(products))
+	}
+	copy(inv.Products, products)
+	inv.Slots = copySlots(slots)
+	if len(inv.Slots) > 0 {
+		syncStock(inv.Products, inv.Slots)
 	}
-	fmt.Println("-----------------------------------")
 }
 
-func SelectProduct(userInput *os.File) (map[Product]int8, int64, error) {
+func ListAllProducts(output io.Writer) {
+	DefaultInventory().ListAllProducts(output)
+}
+
+func (inv *Inventory) ListAllProducts(output io.Writer) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	//reserved pieces are held for purchases in progress, only available pieces can be selected
+	products := inv.List()
+	reserved := inv.Reserved()
+	slots := inv.ListSlots()
 
-	//if userInput is not from file (for test purpose) then use from stdin instead
+	fmt.Fprintln(output, "List of products")
+	fmt.Fprintln(output, "No        Name      Price     Available Reserved")
+	fmt.Fprintln(output, "-----------------------------------------------")
+	for _, product := range products {
+		fmt.Fprintf(output, "%-10v%-10v%-10v%-10v%-10v\n", product.ProductNo, product.Name, product.Price, availableStock(product.Stock, reserved[product.ProductNo]), reserved[product.ProductNo])
+	}
+	fmt.Fprintln(output, "-----------------------------------------------")
+
+	if len(slots) > 0 {
+		listSlots(output, products, slots)
+	}
+}
+
+func SelectProduct(userInput io.Reader, output io.Writer) (map[Product]int64, money.Amount, error) {
+	return DefaultInventory().SelectProduct(userInput, output)
+}
+
+func (inv *Inventory) SelectProduct(userInput io.Reader, output io.Writer) (map[Product]int64, money.Amount, error) {
+
+	//if userInput is not given (for test purpose) then use from stdin instead
 	if userInput == nil {
 		userInput = os.Stdin
 	}
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
 
 	//create tmpProductStock from product's stock because we'll change the real stock when everything is success
-	tmpProductStock := make([]Product, len(ProductStock))
-	copy(tmpProductStock, ProductStock)
+	tmpProductStock := inv.List()
 
 	//loop for select product until user ENTER for checkout
-	var totalAmount int64
-	boughtProducts := make(map[Product]int8)
-	fmt.Println("Please Select Product No: ")
+	var totalAmount money.Amount
+	boughtProducts := make(map[Product]int64)
+	fmt.Fprintln(output, "Please Select Product No: ")
 	for {
 		var selectedProduct string
 		fmt.Fscanln(userInput, &selectedProduct)
@@ -80,10 +165,17 @@ func SelectProduct(userInput *os.File) (map[Product]int8, int64, error) {
 		}
 
 		//validate product that user's selected
-		product, err := checkProduct(selectedProduct, tmpProductStock)
+		product, err := checkProduct(selectedProduct, tmpProductStock, nil)
 		if err != nil {
 			//if product validation is not pass, then let user to select product again
-			fmt.Printf("%+v, please select product no. again\n", err)
+			fmt.Fprintf(output, "%+v, please select product no. again\n", err)
+			continue
+		}
+
+		//total price must fit in int64, the product is not selected if it doesn't
+		if product.Price > math.MaxInt64-totalAmount {
+			returnProduct(tmpProductStock, product)
+			fmt.Fprintf(output, "total price is exceeded, %+v can't be selected\n", product.Name)
 			continue
 		}
 
@@ -98,21 +190,40 @@ func SelectProduct(userInput *os.File) (map[Product]int8, int64, error) {
 			boughtProducts[product] = productMap + 1
 		}
 
-		fmt.Println("Press ENTER to checkout or continue select product")
+		fmt.Fprintln(output, "Press ENTER to checkout or continue select product")
 	}
 
 	return boughtProducts, totalAmount, nil
 }
 
-//checkProduct - for check product no. from receiving product's stock is available or not
-//and return product for founded product no.
-func checkProduct(productNo string, productStock []Product) (Product, error) {
+//checkProduct - for check product no. or slot code from receiving product's stock is available or not
+//and return product for founded product no., slots is the planogram that the product is vended from if any
+func checkProduct(productNo string, productStock []Product, slots []Slot) (Product, error) {
+	//slot code selects the product loaded in the slot
+	if len(slots) > 0 && isSlotCode(productNo) {
+		slot, err := checkSlot(productNo, slots)
+		if err != nil {
+			return Product{}, err
+		}
+		productNo = strconv.FormatInt(slot.ProductNo, 10)
+	} else if len(slots) > 0 {
+		//product that has no available piece is rejected with the reason of its slots
+		for i, product := range productStock {
+			if strconv.FormatInt(product.ProductNo, 10) == productNo && product.Stock == 0 {
+				return Product{}, slotReason(productStock[i], slots)
+			}
+		}
+	}
+
 	for i, product := range productStock {
-		productNoInt, err := strconv.Atoi(productNo)
+		productNoInt, err := strconv.ParseInt(productNo, 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return Product{}, errors.New("product no. " + productNo + " is out of range")
+		}
 		if err != nil {
 			return Product{}, errors.New("invalid input")
 		}
-		if int8(productNoInt) == product.ProductNo {
+		if productNoInt == product.ProductNo {
 			//if product's stock is zero then error
 			if product.Stock == 0 {
 				return Product{}, errors.New(product.Name + " is out of stock")
@@ -133,29 +244,156 @@ func checkProduct(productNo string, productStock []Product) (Product, error) {
 }
 
 //DecreaseStock - decrease global product's stock by buyedProducts map (products that user buy)
-func DecreaseStock(buyedProducts map[Product]int8) error {
+func DecreaseStock(buyedProducts map[Product]int64) error {
+	return DefaultInventory().DecreaseStock(buyedProducts)
+}
+
+//DecreaseStock - decrease inventory's stock by buyedProducts map (products that user buy)
+//every product is validated before any stock is decreased, so stock is never partly decreased
+func (inv *Inventory) DecreaseStock(buyedProducts map[Product]int64) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	decreasedProducts := make([]Product, len(inv.Products))
+	copy(decreasedProducts, inv.Products)
+
 	for boughtProduct, amount := range buyedProducts {
-		for i, product := range ProductStock {
+		for i, product := range decreasedProducts {
 			if boughtProduct.ProductNo == product.ProductNo {
-				if ProductStock[i].Stock-amount < 0 {
+				if decreasedProducts[i].Stock-amount < 0 {
 					return errors.New(product.Name + "'s stock is less than zero")
 				}
-				ProductStock[i].Stock = ProductStock[i].Stock - amount
+				decreasedProducts[i].Stock = decreasedProducts[i].Stock - amount
 				break
 			}
 		}
 	}
+
+	copy(inv.Products, decreasedProducts)
+	return nil
+}
+
+//Restock - add amount to the stock of product no. productNo
+func (inv *Inventory) Restock(productNo int64, amount int64) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if amount <= 0 {
+		return errors.New("restock amount must be greater than zero")
+	}
+	for i, product := range inv.Products {
+		if productNo == product.ProductNo {
+			if len(inv.Slots) > 0 {
+				return inv.restockSlots(product, amount)
+			}
+			if amount > math.MaxInt64-product.Stock {
+				return errors.New(product.Name + "'s stock is exceeded")
+			}
+			inv.Products[i].Stock = product.Stock + amount
+			return nil
+		}
+	}
+	return errors.New("product doesn't exist")
+}
+
+//AddProduct - add new product to the inventory, product no. must not be used by another product
+func (inv *Inventory) AddProduct(newProduct Product) error {
+	if newProduct.ProductNo < 1 {
+		return errors.New("product no. must be greater than zero")
+	}
+	if newProduct.Name == "" {
+		return errors.New("product has no name")
+	}
+	if newProduct.Price < 0 {
+		return errors.New(newProduct.Name + "'s price must not be negative")
+	}
+	if newProduct.Stock < 0 {
+		return errors.New(newProduct.Name + "'s stock must not be negative")
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	for _, product := range inv.Products {
+		if newProduct.ProductNo == product.ProductNo {
+			return errors.New("product no. " + strconv.FormatInt(product.ProductNo, 10) + " is already used by " + product.Name)
+		}
+	}
+	//stock of the planogram is only in slots, it's loaded by restocking after the product is assigned to a slot
+	if len(inv.Slots) > 0 && newProduct.Stock != 0 {
+		return errors.New(newProduct.Name + "'s stock must be loaded into a slot")
+	}
+
+	inv.Products = append(inv.Products, newProduct)
 	return nil
 }
 
-func PrintBoughtProduct(boughtProducts map[Product]int8) {
-	fmt.Printf("You've bought\n")
-	for product, value := range boughtProducts {
-		fmt.Printf("%+v price %+v THB for %+v piece", product.Name, product.Price, value)
+//ChangePrice - set price of product no. productNo
+func (inv *Inventory) ChangePrice(productNo int64, price money.Amount) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for i, product := range inv.Products {
+		if productNo == product.ProductNo {
+			if price < 0 {
+				return errors.New(product.Name + "'s price must not be negative")
+			}
+			inv.Products[i].Price = price
+			return nil
+		}
+	}
+	return errors.New("product doesn't exist")
+}
+
+//RemoveProduct - remove product no. productNo from the inventory and return it
+func (inv *Inventory) RemoveProduct(productNo int64) (Product, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for i, product := range inv.Products {
+		if productNo == product.ProductNo {
+			remainingProducts := make([]Product, 0, len(inv.Products)-1)
+			remainingProducts = append(remainingProducts, inv.Products[:i]...)
+			remainingProducts = append(remainingProducts, inv.Products[i+1:]...)
+			inv.Products = remainingProducts
+			inv.unassignSlots(productNo)
+			return product, nil
+		}
+	}
+	return Product{}, errors.New("product doesn't exist")
+}
+
+//returnProduct - put a piece of product back to productStock after checkProduct took it
+func returnProduct(productStock []Product, product Product) {
+	for i := range productStock {
+		if productStock[i].ProductNo == product.ProductNo {
+			productStock[i].Stock = productStock[i].Stock + 1
+		}
+	}
+}
+
+func PrintBoughtProduct(output io.Writer, boughtProducts map[Product]int64) {
+	//if output is not given then use stdout instead
+	if output == nil {
+		output = os.Stdout
+	}
+
+	//print in order of product no.
+	orderedProducts := []Product{}
+	for product := range boughtProducts {
+		orderedProducts = append(orderedProducts, product)
+	}
+	sort.Slice(orderedProducts, func(i, j int) bool {
+		return orderedProducts[i].ProductNo < orderedProducts[j].ProductNo
+	})
+
+	fmt.Fprintf(output, "You've bought\n")
+	for _, product := range orderedProducts {
+		value := boughtProducts[product]
+		fmt.Fprintf(output, "%+v price %+v THB for %+v piece", product.Name, product.Price, value)
 		if value > 1 {
-			fmt.Println("s")
+			fmt.Fprintln(output, "s")
 		} else {
-			fmt.Println("")
+			fmt.Fprintln(output, "")
 		}
 	}
 }
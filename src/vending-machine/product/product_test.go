@@ -1,10 +1,15 @@
 package product
 
 import (
+	"bytes"
 	"errors"
-	"io"
-	"io/ioutil"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"vending-machine/money"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -14,25 +19,25 @@ func commonPrepData() []Product {
 		{
 			ProductNo: 1,
 			Name:      "Lays",
-			Price:     5,
+			Price:     money.Baht(5),
 			Stock:     1,
 		},
 		{
 			ProductNo: 2,
 			Name:      "Hanami",
-			Price:     10,
+			Price:     money.Baht(10),
 			Stock:     10,
 		},
 		{
 			ProductNo: 3,
 			Name:      "Kitkat",
-			Price:     25,
+			Price:     money.Baht(25),
 			Stock:     10,
 		},
 		{
 			ProductNo: 4,
 			Name:      "Pepsi",
-			Price:     15,
+			Price:     money.Baht(15),
 			Stock:     10,
 		},
 	}
@@ -43,8 +48,9 @@ func Test_SelectProduct(t *testing.T) {
 		description          string
 		prepData             func()
 		input                string
-		expectedBuyedProduct map[Product]int8
-		expectedTotalAmount  int64
+		expectedBuyedProduct map[Product]int64
+		expectedTotalAmount  money.Amount
+		expectedOutput       []string
 		expectedError        string
 		hasError             bool
 	}{
@@ -54,22 +60,22 @@ func Test_SelectProduct(t *testing.T) {
 				commonPrepData()
 			},
 			input:               "1\n2\n4\n2\n4\n2\n\n",
-			expectedTotalAmount: 65,
-			expectedBuyedProduct: map[Product]int8{
+			expectedTotalAmount: money.Baht(65),
+			expectedBuyedProduct: map[Product]int64{
 				{
 					ProductNo: 1,
 					Name:      "Lays",
-					Price:     5,
+					Price:     money.Baht(5),
 				}: 1,
 				{
 					ProductNo: 4,
 					Name:      "Pepsi",
-					Price:     15,
+					Price:     money.Baht(15),
 				}: 2,
 				{
 					ProductNo: 2,
 					Name:      "Hanami",
-					Price:     10,
+					Price:     money.Baht(10),
 				}: 3,
 			},
 			hasError: false,
@@ -80,58 +86,74 @@ func Test_SelectProduct(t *testing.T) {
 				commonPrepData()
 			},
 			input:               "1\n1\n2\n4\n2\n4\n2\n\n",
-			expectedTotalAmount: 65,
-			expectedBuyedProduct: map[Product]int8{
+			expectedTotalAmount: money.Baht(65),
+			expectedOutput: []string{
+				"Please Select Product No: ",
+				"Lays is out of stock, please select product no. again",
+			},
+			expectedBuyedProduct: map[Product]int64{
 				{
 					ProductNo: 1,
 					Name:      "Lays",
-					Price:     5,
+					Price:     money.Baht(5),
 				}: 1,
 				{
 					ProductNo: 4,
 					Name:      "Pepsi",
-					Price:     15,
+					Price:     money.Baht(15),
 				}: 2,
 				{
 					ProductNo: 2,
 					Name:      "Hanami",
-					Price:     10,
+					Price:     money.Baht(10),
 				}: 3,
 			},
 			hasError: false,
 		},
+		{
+			description: "test_select_product_success_quantity_beyond_int8",
+			prepData: func() {
+				ProductStock = []Product{{ProductNo: 1, Name: "Water", Price: money.Baht(1), Stock: 200}}
+			},
+			input:                strings.Repeat("1\n", 200) + "\n",
+			expectedTotalAmount:  money.Baht(200),
+			expectedBuyedProduct: map[Product]int64{{ProductNo: 1, Name: "Water", Price: money.Baht(1)}: 200},
+			hasError:             false,
+		},
+		{
+			description: "test_select_product_success_total_price_is_exceeded",
+			prepData: func() {
+				ProductStock = []Product{{ProductNo: 1, Name: "Gold", Price: math.MaxInt64, Stock: 2}}
+			},
+			input:                "1\n1\n\n",
+			expectedTotalAmount:  math.MaxInt64,
+			expectedBuyedProduct: map[Product]int64{{ProductNo: 1, Name: "Gold", Price: math.MaxInt64}: 1},
+			expectedOutput:       []string{"total price is exceeded, Gold can't be selected"},
+			hasError:             false,
+		},
 		{
 			description: "test_select_product_failed_user_not_select_any_product",
 			prepData: func() {
 				commonPrepData()
 			},
-			input:         "\n",
-			expectedError: "you have not select any product",
-			hasError:      true,
+			input:          "\n",
+			expectedOutput: []string{"Please Select Product No: "},
+			expectedError:  "you have not select any product",
+			hasError:       true,
 		},
 	}
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
 			test.prepData()
 
-			//create mock user input
-			userInput, err := ioutil.TempFile("", "")
-			if err != nil {
-				t.Fatal(err)
-			}
-			defer userInput.Close()
-
-			_, err = io.WriteString(userInput, test.input)
-			if err != nil {
-				t.Fatal(err)
-			}
+			//create mock user input and capture output
+			userInput := strings.NewReader(test.input)
+			output := &bytes.Buffer{}
 
-			_, err = userInput.Seek(0, io.SeekStart)
-			if err != nil {
-				t.Fatal(err)
+			buyedProduct, totalAmount, err := SelectProduct(userInput, output)
+			for _, expectedOutput := range test.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
 			}
-
-			buyedProduct, totalAmount, err := SelectProduct(userInput)
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expectedError, err.Error())
@@ -171,31 +193,31 @@ func Test_checkProduct(t *testing.T) {
 
 				ProductNo: 1,
 				Name:      "Lays",
-				Price:     5,
+				Price:     money.Baht(5),
 			},
 			expectedProductStock: []Product{
 				{
 					ProductNo: 1,
 					Name:      "Lays",
-					Price:     5,
+					Price:     money.Baht(5),
 					Stock:     0,
 				},
 				{
 					ProductNo: 2,
 					Name:      "Hanami",
-					Price:     10,
+					Price:     money.Baht(10),
 					Stock:     10,
 				},
 				{
 					ProductNo: 3,
 					Name:      "Kitkat",
-					Price:     25,
+					Price:     money.Baht(25),
 					Stock:     10,
 				},
 				{
 					ProductNo: 4,
 					Name:      "Pepsi",
-					Price:     15,
+					Price:     money.Baht(15),
 					Stock:     10,
 				},
 			},
@@ -212,6 +234,17 @@ func Test_checkProduct(t *testing.T) {
 			expectedError:        errors.New("invalid input"),
 			hasError:             true,
 		},
+		{
+			description: "test_check_product_failed_product_no_is_out_of_range",
+			input: inputArgs{
+				productNo:    "9223372036854775808",
+				productStock: commonPrepData(),
+			},
+			expected:             Product{},
+			expectedProductStock: commonPrepData(),
+			expectedError:        errors.New("product no. 9223372036854775808 is out of range"),
+			hasError:             true,
+		},
 		{
 			description: "test_check_product_failed_product_is_out_of_stock",
 			input: inputArgs{
@@ -220,7 +253,7 @@ func Test_checkProduct(t *testing.T) {
 					{
 						ProductNo: 2,
 						Name:      "Hanami",
-						Price:     10,
+						Price:     money.Baht(10),
 						Stock:     0,
 					},
 				},
@@ -230,7 +263,7 @@ func Test_checkProduct(t *testing.T) {
 				{
 					ProductNo: 2,
 					Name:      "Hanami",
-					Price:     10,
+					Price:     money.Baht(10),
 					Stock:     0,
 				},
 			},
@@ -245,7 +278,7 @@ func Test_checkProduct(t *testing.T) {
 					{
 						ProductNo: 2,
 						Name:      "Hanami",
-						Price:     10,
+						Price:     money.Baht(10),
 						Stock:     0,
 					},
 				},
@@ -255,7 +288,7 @@ func Test_checkProduct(t *testing.T) {
 				{
 					ProductNo: 2,
 					Name:      "Hanami",
-					Price:     10,
+					Price:     money.Baht(10),
 					Stock:     0,
 				},
 			},
@@ -265,7 +298,7 @@ func Test_checkProduct(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.description, func(t *testing.T) {
-			output, err := checkProduct(test.input.productNo, test.input.productStock)
+			output, err := checkProduct(test.input.productNo, test.input.productStock, nil)
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expectedError, err)
@@ -284,7 +317,7 @@ func Test_DecreaseStock(t *testing.T) {
 	tests := []struct {
 		description   string
 		prepData      func()
-		input         map[Product]int8
+		input         map[Product]int64
 		expected      []Product
 		expectedError error
 		hasError      bool
@@ -296,18 +329,18 @@ func Test_DecreaseStock(t *testing.T) {
 					{
 						ProductNo: 1,
 						Name:      "Sunbyte",
-						Price:     10,
+						Price:     money.Baht(10),
 						Stock:     10,
 					},
 					{
 						ProductNo: 2,
 						Name:      "Papika",
-						Price:     5,
+						Price:     money.Baht(5),
 						Stock:     10,
 					},
 				}
 			},
-			input: map[Product]int8{
+			input: map[Product]int64{
 				{
 					ProductNo: 1,
 					Name:      "Sunbyte",
@@ -321,13 +354,13 @@ func Test_DecreaseStock(t *testing.T) {
 				{
 					ProductNo: 1,
 					Name:      "Sunbyte",
-					Price:     10,
+					Price:     money.Baht(10),
 					Stock:     9,
 				},
 				{
 					ProductNo: 2,
 					Name:      "Papika",
-					Price:     5,
+					Price:     money.Baht(5),
 					Stock:     0,
 				},
 			},
@@ -340,18 +373,18 @@ func Test_DecreaseStock(t *testing.T) {
 					{
 						ProductNo: 1,
 						Name:      "Sunbyte",
-						Price:     10,
+						Price:     money.Baht(10),
 						Stock:     10,
 					},
 					{
 						ProductNo: 2,
 						Name:      "Papika",
-						Price:     5,
+						Price:     money.Baht(5),
 						Stock:     10,
 					},
 				}
 			},
-			input: map[Product]int8{
+			input: map[Product]int64{
 				{
 					ProductNo: 1,
 					Name:      "Sunbyte",
@@ -365,13 +398,13 @@ func Test_DecreaseStock(t *testing.T) {
 				{
 					ProductNo: 1,
 					Name:      "Sunbyte",
-					Price:     10,
-					Stock:     9,
+					Price:     money.Baht(10),
+					Stock:     10,
 				},
 				{
 					ProductNo: 2,
 					Name:      "Papika",
-					Price:     5,
+					Price:     money.Baht(5),
 					Stock:     10,
 				},
 			},
@@ -387,6 +420,7 @@ func Test_DecreaseStock(t *testing.T) {
 			if test.hasError {
 				assert.Error(t, err)
 				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, test.expected, ProductStock)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, test.expected, ProductStock)
@@ -394,3 +428,282 @@ func Test_DecreaseStock(t *testing.T) {
 		})
 	}
 }
+
+func Test_Inventory_Restock(t *testing.T) {
+	type inputArgs struct {
+		productNo int64
+		amount    int64
+	}
+
+	tests := []struct {
+		description   string
+		input         inputArgs
+		expected      []Product
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_restock_success",
+			input: inputArgs{
+				productNo: 1,
+				amount:    5,
+			},
+			expected: []Product{
+				{
+					ProductNo: 1,
+					Name:      "Lays",
+					Price:     money.Baht(5),
+					Stock:     6,
+				},
+				{
+					ProductNo: 2,
+					Name:      "Hanami",
+					Price:     money.Baht(10),
+					Stock:     10,
+				},
+				{
+					ProductNo: 3,
+					Name:      "Kitkat",
+					Price:     money.Baht(25),
+					Stock:     10,
+				},
+				{
+					ProductNo: 4,
+					Name:      "Pepsi",
+					Price:     money.Baht(15),
+					Stock:     10,
+				},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_restock_failed_product_does_not_exist",
+			input: inputArgs{
+				productNo: 9,
+				amount:    5,
+			},
+			expectedError: errors.New("product doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description: "test_restock_success_stock_beyond_int8",
+			input: inputArgs{
+				productNo: 2,
+				amount:    190,
+			},
+			expected: []Product{
+				{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 1},
+				{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 200},
+				{ProductNo: 3, Name: "Kitkat", Price: money.Baht(25), Stock: 10},
+				{ProductNo: 4, Name: "Pepsi", Price: money.Baht(15), Stock: 10},
+			},
+			hasError: false,
+		},
+		{
+			description: "test_restock_failed_stock_is_exceeded",
+			input: inputArgs{
+				productNo: 2,
+				amount:    math.MaxInt64 - 9,
+			},
+			expectedError: errors.New("Hanami's stock is exceeded"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inventory := NewInventory(commonPrepData())
+			err := inventory.Restock(test.input.productNo, test.input.amount)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, commonPrepData(), inventory.Products)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, inventory.Products)
+			}
+		})
+	}
+}
+
+func Test_Inventory_AddProduct(t *testing.T) {
+	tests := []struct {
+		description   string
+		input         Product
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_add_product_success",
+			input:       Product{ProductNo: 5, Name: "Oreo", Price: money.Baht(20), Stock: 3},
+			hasError:    false,
+		},
+		{
+			description:   "test_add_product_failed_product_no_is_used",
+			input:         Product{ProductNo: 2, Name: "Oreo", Price: money.Baht(20), Stock: 3},
+			expectedError: errors.New("product no. 2 is already used by Hanami"),
+			hasError:      true,
+		},
+		{
+			description:   "test_add_product_failed_product_no_is_not_positive",
+			input:         Product{ProductNo: 0, Name: "Oreo", Price: money.Baht(20), Stock: 3},
+			expectedError: errors.New("product no. must be greater than zero"),
+			hasError:      true,
+		},
+		{
+			description:   "test_add_product_failed_no_name",
+			input:         Product{ProductNo: 5, Price: money.Baht(20), Stock: 3},
+			expectedError: errors.New("product has no name"),
+			hasError:      true,
+		},
+		{
+			description:   "test_add_product_failed_negative_price",
+			input:         Product{ProductNo: 5, Name: "Oreo", Price: money.Baht(-1), Stock: 3},
+			expectedError: errors.New("Oreo's price must not be negative"),
+			hasError:      true,
+		},
+		{
+			description:   "test_add_product_failed_negative_stock",
+			input:         Product{ProductNo: 5, Name: "Oreo", Price: money.Baht(20), Stock: -1},
+			expectedError: errors.New("Oreo's stock must not be negative"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inventory := NewInventory(commonPrepData())
+			err := inventory.AddProduct(test.input)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, commonPrepData(), inventory.Products)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, append(commonPrepData(), test.input), inventory.Products)
+			}
+		})
+	}
+}
+
+func Test_Inventory_ChangePrice(t *testing.T) {
+	inventory := NewInventory(commonPrepData())
+
+	err := inventory.ChangePrice(3, money.Baht(30))
+	assert.NoError(t, err)
+	assert.Equal(t, money.Baht(30), inventory.Products[2].Price)
+
+	err = inventory.ChangePrice(3, money.Baht(-1))
+	assert.Equal(t, errors.New("Kitkat's price must not be negative"), err)
+	assert.Equal(t, money.Baht(30), inventory.Products[2].Price)
+
+	err = inventory.ChangePrice(9, money.Baht(30))
+	assert.Equal(t, errors.New("product doesn't exist"), err)
+}
+
+func Test_Inventory_RemoveProduct(t *testing.T) {
+	products := commonPrepData()
+	inventory := NewInventory(products)
+
+	removedProduct, err := inventory.RemoveProduct(2)
+	assert.NoError(t, err)
+	assert.Equal(t, commonPrepData()[1], removedProduct)
+	assert.Equal(t, []Product{commonPrepData()[0], commonPrepData()[2], commonPrepData()[3]}, inventory.Products)
+	assert.Equal(t, commonPrepData(), products)
+
+	_, err = inventory.RemoveProduct(2)
+	assert.Equal(t, errors.New("product doesn't exist"), err)
+}
+
+func Test_NewInventory_does_not_share_stock(t *testing.T) {
+	products := commonPrepData()
+	first := NewInventory(products)
+	second := NewInventory(products)
+
+	err := first.DecreaseStock(map[Product]int64{{ProductNo: 1, Name: "Lays"}: 1})
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(0), first.Products[0].Stock)
+	assert.Equal(t, int64(1), second.Products[0].Stock)
+	assert.Equal(t, int64(1), products[0].Stock)
+}
+
+func Test_ListAllProducts(t *testing.T) {
+	output := &bytes.Buffer{}
+	inv := NewInventory(commonPrepData())
+	inv.Reserve("a1", "2", time.Time{})
+	inv.Reserve("b2", "2", time.Time{})
+	inv.Reserve("b2", "1", time.Time{})
+	inv.ListAllProducts(output)
+
+	assert.Equal(t, "List of products\n"+
+		"No        Name      Price     Available Reserved\n"+
+		"-----------------------------------------------\n"+
+		"1         Lays      5         0         1         \n"+
+		"2         Hanami    10        8         2         \n"+
+		"3         Kitkat    25        10        0         \n"+
+		"4         Pepsi     15        10        0         \n"+
+		"-----------------------------------------------\n", output.String())
+}
+
+func Test_Inventory_concurrent_use(t *testing.T) {
+	const workers = 50
+	const rounds = 100
+	inv := NewInventory([]Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 100},
+		{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 0},
+	})
+
+	//every worker buys Lays until it's out of stock and moves what it bought to Hanami,
+	//while others list the stock
+	var bought int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				err := inv.DecreaseStock(map[Product]int64{{ProductNo: 1}: 1})
+				if err == nil {
+					atomic.AddInt64(&bought, 1)
+					assert.NoError(t, inv.Restock(2, 1))
+				}
+				for _, prod := range inv.List() {
+					assert.GreaterOrEqual(t, prod.Stock, int64(0))
+				}
+				inv.ListAllProducts(&bytes.Buffer{})
+			}
+		}()
+	}
+	wg.Wait()
+
+	//no piece is lost or made up
+	assert.Equal(t, int64(100), bought)
+	assert.Equal(t, []Product{
+		{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 0},
+		{ProductNo: 2, Name: "Hanami", Price: money.Baht(10), Stock: 100},
+	}, inv.List())
+}
+
+func Test_DecreaseStock_concurrent_use(t *testing.T) {
+	ProductStock = []Product{{ProductNo: 1, Name: "Lays", Price: money.Baht(5), Stock: 100}}
+
+	var bought int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				err := DecreaseStock(map[Product]int64{{ProductNo: 1}: 1})
+				if err == nil {
+					atomic.AddInt64(&bought, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), bought)
+	assert.Equal(t, int64(0), ProductStock[0].Stock)
+}
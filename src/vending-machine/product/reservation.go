@@ -0,0 +1,206 @@
+package product
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//Reservation - pieces of products held for a purchase, they can't be selected by another purchase
+//until it's released or it expires
+type Reservation struct {
+	Products map[int64]int64
+
+	//Slots - pieces of Products held in each slot by slot code, for pieces that were selected by slot code
+	Slots map[string]int64
+
+	//ExpiresAt - time that the reservation is released by ReleaseExpired, zero for holding until it's released
+	ExpiresAt time.Time
+}
+
+//Reserve - hold a piece of product no. (or slot code) productNo for purchase id until expiresAt, every piece held
+//for the purchase is held until expiresAt, the product must have a piece that is not reserved, same as checkProduct,
+//slot code of the piece is returned if a slot code is selected, the piece is then vended from that slot
+func (inv *Inventory) Reserve(id string, productNo string, expiresAt time.Time) (Product, string, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	//validate against pieces that are not reserved by any purchase
+	available := make([]Product, len(inv.Products))
+	copy(available, inv.Products)
+	reserved := inv.reserved("")
+	for i, prod := range available {
+		available[i].Stock = availableStock(prod.Stock, reserved[prod.ProductNo])
+	}
+	availableSlots := copySlots(inv.Slots)
+	reservedSlots := inv.reservedSlots("")
+	for i, slot := range availableSlots {
+		availableSlots[i].Stock = availableStock(slot.Stock, reservedSlots[slot.Code])
+	}
+	selectedProduct, err := checkProduct(productNo, available, availableSlots)
+	if err != nil {
+		return Product{}, "", err
+	}
+
+	reservation := inv.reservation(id)
+	reservation.Products[selectedProduct.ProductNo] = reservation.Products[selectedProduct.ProductNo] + 1
+	slotCode := ""
+	if len(inv.Slots) > 0 && isSlotCode(productNo) {
+		slotCode = strings.ToUpper(productNo)
+		reservation.Slots[slotCode] = reservation.Slots[slotCode] + 1
+	}
+	reservation.ExpiresAt = expiresAt
+	inv.reservations[id] = reservation
+	return selectedProduct, slotCode, nil
+}
+
+//Renew - hold exactly products (pieces by product no.) and slots (pieces of them selected by slot code)
+//for purchase id until expiresAt, pieces are held again if its reservation has expired
+//and no other purchase has reserved them meanwhile
+func (inv *Inventory) Renew(id string, products map[int64]int64, slots map[string]int64, expiresAt time.Time) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	reservedByOthers := inv.reserved(id)
+	for productNo, amount := range products {
+		prod, ok := inv.find(productNo)
+		if !ok {
+			return errors.New("product doesn't exist")
+		}
+		if availableStock(prod.Stock, reservedByOthers[productNo]) < amount {
+			return errors.New(prod.Name + " is out of stock")
+		}
+	}
+	reservedSlotsByOthers := inv.reservedSlots(id)
+	for code, amount := range slots {
+		slot, ok := inv.findSlot(code)
+		if !ok {
+			return errors.New("slot " + code + " doesn't exist")
+		}
+		if slot.Jammed {
+			return errors.New("slot " + code + " is jammed")
+		}
+		if availableStock(slot.Stock, reservedSlotsByOthers[code]) < amount {
+			return errors.New("slot " + code + " is empty")
+		}
+	}
+
+	reservation := Reservation{Products: make(map[int64]int64), Slots: make(map[string]int64), ExpiresAt: expiresAt}
+	for productNo, amount := range products {
+		reservation.Products[productNo] = amount
+	}
+	for code, amount := range slots {
+		reservation.Slots[code] = amount
+	}
+	if inv.reservations == nil {
+		inv.reservations = make(map[string]Reservation)
+	}
+	inv.reservations[id] = reservation
+	return nil
+}
+
+//Release - stop holding pieces for purchase id, nothing happens if it holds nothing
+func (inv *Inventory) Release(id string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	delete(inv.reservations, id)
+}
+
+//ReleaseExpired - release every reservation that has expired at now, return IDs of their purchases
+func (inv *Inventory) ReleaseExpired(now time.Time) []string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	released := []string{}
+	for id, reservation := range inv.reservations {
+		if !reservation.ExpiresAt.IsZero() && !now.Before(reservation.ExpiresAt) {
+			delete(inv.reservations, id)
+			released = append(released, id)
+		}
+	}
+	return released
+}
+
+//Reserved - pieces reserved by every purchase by product no.
+func (inv *Inventory) Reserved() map[int64]int64 {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return inv.reserved("")
+}
+
+//ReservedSlots - pieces reserved by every purchase that selected them by slot code, by slot code
+func (inv *Inventory) ReservedSlots() map[string]int64 {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return inv.reservedSlots("")
+}
+
+//reserved - pieces reserved by every purchase except purchase exceptID by product no., the inventory must be locked
+func (inv *Inventory) reserved(exceptID string) map[int64]int64 {
+	reserved := make(map[int64]int64)
+	for id, reservation := range inv.reservations {
+		if id == exceptID {
+			continue
+		}
+		for productNo, amount := range reservation.Products {
+			reserved[productNo] = reserved[productNo] + amount
+		}
+	}
+	return reserved
+}
+
+//reservedSlots - pieces reserved by slot code by every purchase except purchase exceptID by slot code,
+//the inventory must be locked
+func (inv *Inventory) reservedSlots(exceptID string) map[string]int64 {
+	reserved := make(map[string]int64)
+	for id, reservation := range inv.reservations {
+		if id == exceptID {
+			continue
+		}
+		for code, amount := range reservation.Slots {
+			reserved[code] = reserved[code] + amount
+		}
+	}
+	return reserved
+}
+
+//reservation - reservation of purchase id, an empty one if it holds nothing, the inventory must be locked
+func (inv *Inventory) reservation(id string) Reservation {
+	if inv.reservations == nil {
+		inv.reservations = make(map[string]Reservation)
+	}
+	reservation, ok := inv.reservations[id]
+	if !ok {
+		reservation = Reservation{Products: make(map[int64]int64), Slots: make(map[string]int64)}
+	}
+	return reservation
+}
+
+//find - product no. productNo, the inventory must be locked
+func (inv *Inventory) find(productNo int64) (Product, bool) {
+	for _, prod := range inv.Products {
+		if prod.ProductNo == productNo {
+			return prod, true
+		}
+	}
+	return Product{}, false
+}
+
+//findSlot - slot of code, the inventory must be locked
+func (inv *Inventory) findSlot(code string) (Slot, bool) {
+	for _, slot := range inv.Slots {
+		if slot.Code == code {
+			return slot, true
+		}
+	}
+	return Slot{}, false
+}
+
+//availableStock - pieces of stock that are not reserved, never less than zero
+//because stock of a sold product is decreased just before its reservation is released
+func availableStock(stock int64, reserved int64) int64 {
+	if stock <= reserved {
+		return 0
+	}
+	return stock - reserved
+}
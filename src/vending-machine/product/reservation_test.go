@@ -0,0 +1,137 @@
+package product
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"vending-machine/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Inventory_Reserve(t *testing.T) {
+	expiresAt := time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)
+
+	tests := []struct {
+		description      string
+		reserved         map[string]string //product no. reserved by purchase's ID before
+		productNo        string
+		expected         Product
+		expectedReserved map[int64]int64
+		expectedError    error
+		hasError         bool
+	}{
+		{
+			description:      "test_reserve_success",
+			productNo:        "2",
+			expected:         Product{ProductNo: 2, Name: "Hanami", Price: money.Baht(10)},
+			expectedReserved: map[int64]int64{2: 1},
+			hasError:         false,
+		},
+		{
+			description:      "test_reserve_success_add_to_own_reservation",
+			reserved:         map[string]string{"a1": "2"},
+			productNo:        "2",
+			expected:         Product{ProductNo: 2, Name: "Hanami", Price: money.Baht(10)},
+			expectedReserved: map[int64]int64{2: 2},
+			hasError:         false,
+		},
+		{
+			description:      "test_reserve_failed_last_piece_is_reserved_by_another_purchase",
+			reserved:         map[string]string{"b2": "1"},
+			productNo:        "1",
+			expectedReserved: map[int64]int64{1: 1},
+			expectedError:    errors.New("Lays is out of stock"),
+			hasError:         true,
+		},
+		{
+			description:      "test_reserve_failed_last_piece_is_reserved_by_itself",
+			reserved:         map[string]string{"a1": "1"},
+			productNo:        "1",
+			expectedReserved: map[int64]int64{1: 1},
+			expectedError:    errors.New("Lays is out of stock"),
+			hasError:         true,
+		},
+		{
+			description:      "test_reserve_failed_product_doesnt_exist",
+			productNo:        "9",
+			expectedReserved: map[int64]int64{},
+			expectedError:    errors.New("product doesn't exist"),
+			hasError:         true,
+		},
+		{
+			description:      "test_reserve_failed_invalid_input",
+			productNo:        "abc",
+			expectedReserved: map[int64]int64{},
+			expectedError:    errors.New("invalid input"),
+			hasError:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inv := NewInventory(commonPrepData())
+			for id, productNo := range test.reserved {
+				inv.Reserve(id, productNo, expiresAt)
+			}
+
+			actual, _, err := inv.Reserve("a1", test.productNo, expiresAt)
+			if test.hasError {
+				assert.Error(t, err)
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expected, actual)
+			assert.Equal(t, test.expectedReserved, inv.Reserved())
+
+			//reservation doesn't change stock
+			assert.Equal(t, commonPrepData(), inv.List())
+		})
+	}
+}
+
+func Test_Inventory_Renew(t *testing.T) {
+	at := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	inv := NewInventory(commonPrepData())
+	inv.Reserve("a1", "1", at.Add(time.Minute))
+
+	//reservation of the last Lays expires then another purchase holds it
+	assert.Equal(t, []string{"a1"}, inv.ReleaseExpired(at.Add(time.Minute)))
+	assert.NoError(t, inv.Renew("b2", map[int64]int64{1: 1}, nil, time.Time{}))
+	assert.Equal(t, errors.New("Lays is out of stock"), inv.Renew("a1", map[int64]int64{1: 1}, nil, at.Add(2*time.Minute)))
+	assert.Equal(t, errors.New("product doesn't exist"), inv.Renew("a1", map[int64]int64{9: 1}, nil, at.Add(2*time.Minute)))
+
+	//reservation without expiry is held until it's released
+	assert.Empty(t, inv.ReleaseExpired(at.Add(time.Hour)))
+	assert.NoError(t, inv.Renew("a1", map[int64]int64{2: 10}, nil, at.Add(2*time.Minute)))
+	assert.Equal(t, map[int64]int64{1: 1, 2: 10}, inv.Reserved())
+
+	inv.Release("b2")
+	inv.Release("a1")
+	assert.Equal(t, map[int64]int64{}, inv.Reserved())
+}
+
+func Test_Inventory_Reserve_slot(t *testing.T) {
+	inv := newSlotInventory(t)
+
+	//a piece selected by slot code is held in that slot
+	actual, slotCode, err := inv.Reserve("a1", "a1", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, Product{ProductNo: 1, Name: "Lays", Price: money.Baht(5)}, actual)
+	assert.Equal(t, "A1", slotCode)
+	_, slotCode, err = inv.Reserve("a1", "1", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "", slotCode)
+	_, _, err = inv.Reserve("b2", "A1", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"A1": 2}, inv.ReservedSlots())
+
+	//every piece of A1 is held, though Lays still has pieces in A2
+	_, _, err = inv.Reserve("c3", "A1", time.Time{})
+	assert.Equal(t, errors.New("slot A1 is empty"), err)
+	assert.Equal(t, errors.New("slot A1 is empty"), inv.Renew("a1", map[int64]int64{1: 2}, map[string]int64{"A1": 2}, time.Time{}))
+
+	inv.Release("b2")
+	assert.NoError(t, inv.Renew("a1", map[int64]int64{1: 2}, map[string]int64{"A1": 2}, time.Time{}))
+	assert.Equal(t, map[string]int64{"A1": 2}, inv.ReservedSlots())
+}
@@ -0,0 +1,341 @@
+package product
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	SLOT_OK         = "ok"
+	SLOT_EMPTY      = "empty"
+	SLOT_JAMMED     = "jammed"
+	SLOT_UNASSIGNED = "unassigned"
+)
+
+//Slot - position of the planogram addressed by a row letter and a column number like A1 or B3,
+//it holds pieces of the product loaded in it up to its capacity
+type Slot struct {
+	Code      string
+	ProductNo int64 //product loaded in the slot, zero for unassigned
+	Capacity  int64
+	Stock     int64
+	Jammed    bool //pieces of a jammed slot can't be vended until it's cleared
+}
+
+//Status - whether pieces can be vended from the slot, SLOT_OK if they can
+func (slot Slot) Status() string {
+	switch {
+	case slot.ProductNo == 0:
+		return SLOT_UNASSIGNED
+	case slot.Jammed:
+		return SLOT_JAMMED
+	case slot.Stock == 0:
+		return SLOT_EMPTY
+	}
+	return SLOT_OK
+}
+
+//ValidSlotCode - whether code is a row letter followed by a column number like A1
+func ValidSlotCode(code string) bool {
+	if len(code) < 2 || code[0] < 'A' || code[0] > 'Z' || code[1] == '0' {
+		return false
+	}
+	_, err := strconv.ParseUint(code[1:], 10, 8)
+	return err == nil
+}
+
+//LoadSlots - replace the planogram of the inventory with a copy of slots,
+//then product's stock is the pieces in its slots that are not jammed
+func (inv *Inventory) LoadSlots(slots []Slot) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	err := validateSlots(inv.Products, slots)
+	if err != nil {
+		return err
+	}
+	inv.Slots = copySlots(slots)
+	syncStock(inv.Products, inv.Slots)
+	return nil
+}
+
+//ListSlots - copy of every slot, changing it doesn't change the inventory
+func (inv *Inventory) ListSlots() []Slot {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	return copySlots(inv.Slots)
+}
+
+//SetJammed - mark slot code as jammed or cleared, pieces of a jammed slot are not in product's stock
+func (inv *Inventory) SetJammed(code string, jammed bool) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	code = strings.ToUpper(code)
+	for i, slot := range inv.Slots {
+		if slot.Code == code {
+			inv.Slots[i].Jammed = jammed
+			syncStock(inv.Products, inv.Slots)
+			return nil
+		}
+	}
+	return errors.New("slot " + code + " doesn't exist")
+}
+
+//AssignSlot - load product no. productNo into slot code, zero for unassigned, then the product is restocked
+//into the slot, the slot must be empty if it's assigned to another product so no piece is sold as the wrong product
+func (inv *Inventory) AssignSlot(code string, productNo int64) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	code = strings.ToUpper(code)
+	for i, slot := range inv.Slots {
+		if slot.Code != code {
+			continue
+		}
+		if slot.ProductNo != productNo && slot.Stock > 0 {
+			return errors.New("slot " + code + " must be empty to be assigned to another product")
+		}
+
+		slots := copySlots(inv.Slots)
+		slots[i].ProductNo = productNo
+		err := validateSlots(inv.Products, slots)
+		if err != nil {
+			return err
+		}
+		inv.Slots = slots
+		syncStock(inv.Products, inv.Slots)
+		return nil
+	}
+	return errors.New("slot " + code + " doesn't exist")
+}
+
+//DrawSlot - take amount pieces out of slot code that user selected, return product no. of the slot
+func DrawSlot(slots []Slot, code string, amount int64) (int64, error) {
+	for i, slot := range slots {
+		if slot.Code != code {
+			continue
+		}
+		switch {
+		case slot.ProductNo == 0:
+			return 0, errors.New("slot " + code + " is unassigned")
+		case slot.Jammed:
+			return 0, errors.New("slot " + code + " is jammed")
+		case slot.Stock < amount:
+			return 0, errors.New("slot " + code + " is empty")
+		}
+		slots[i].Stock = slot.Stock - amount
+		return slot.ProductNo, nil
+	}
+	return 0, errors.New("slot " + code + " doesn't exist")
+}
+
+//DrawSlots - take amount pieces of product no. productNo out of slots one by one, each from the slot of the product
+//that is not jammed and has the most pieces that are not held, held is pieces held by slot code for purchases
+//that selected the slot, they are taken only if no other piece is left
+func DrawSlots(slots []Slot, productNo int64, amount int64, held map[string]int64) error {
+	for ; amount > 0; amount-- {
+		fullest := -1
+		for i, slot := range slots {
+			if slot.ProductNo != productNo || slot.Status() != SLOT_OK {
+				continue
+			}
+			if fullest < 0 || slot.Stock-held[slot.Code] > slots[fullest].Stock-held[slots[fullest].Code] {
+				fullest = i
+			}
+		}
+		if fullest < 0 {
+			return fmt.Errorf("slots of product no. %v are empty", productNo)
+		}
+		slots[fullest].Stock = slots[fullest].Stock - 1
+	}
+	return nil
+}
+
+//restockSlots - load amount pieces of product into its slots, the emptiest slot that has room is filled first,
+//the inventory must be locked
+func (inv *Inventory) restockSlots(product Product, amount int64) error {
+	//room never overflows because capacity of the slots of a product fits in int64
+	assigned := false
+	var room int64
+	for _, slot := range inv.Slots {
+		if slot.ProductNo == product.ProductNo {
+			assigned = true
+			room = room + slot.Capacity - slot.Stock
+		}
+	}
+	if !assigned {
+		return errors.New(product.Name + " is not assigned to any slot")
+	}
+	if amount > room {
+		return errors.New(product.Name + "'s slots are full")
+	}
+
+	for amount > 0 {
+		emptiest := -1
+		for i, slot := range inv.Slots {
+			if slot.ProductNo == product.ProductNo && slot.Stock < slot.Capacity && (emptiest < 0 || slot.Stock < inv.Slots[emptiest].Stock) {
+				emptiest = i
+			}
+		}
+		loaded := inv.Slots[emptiest].Capacity - inv.Slots[emptiest].Stock
+		if loaded > amount {
+			loaded = amount
+		}
+		inv.Slots[emptiest].Stock = inv.Slots[emptiest].Stock + loaded
+		amount = amount - loaded
+	}
+	syncStock(inv.Products, inv.Slots)
+	return nil
+}
+
+//unassignSlots - empty every slot of product no. productNo and leave it unassigned, the inventory must be locked
+func (inv *Inventory) unassignSlots(productNo int64) {
+	for i, slot := range inv.Slots {
+		if slot.ProductNo == productNo {
+			inv.Slots[i].ProductNo = 0
+			inv.Slots[i].Stock = 0
+		}
+	}
+}
+
+//listSlots - print the planogram with product's name and status of each slot
+func listSlots(output io.Writer, products []Product, slots []Slot) {
+	names := make(map[int64]string)
+	for _, product := range products {
+		names[product.ProductNo] = product.Name
+	}
+
+	fmt.Fprintln(output, "List of slots")
+	fmt.Fprintln(output, "Slot      Product   Stock     Capacity  Status")
+	fmt.Fprintln(output, "-----------------------------------------------")
+	for _, slot := range slots {
+		fmt.Fprintf(output, "%-10v%-10v%-10v%-10v%v\n", slot.Code, names[slot.ProductNo], slot.Stock, slot.Capacity, slot.Status())
+	}
+	fmt.Fprintln(output, "-----------------------------------------------")
+}
+
+//checkSlot - slot of code that pieces can be vended from, code is a slot code that user selected
+func checkSlot(code string, slots []Slot) (Slot, error) {
+	code = strings.ToUpper(code)
+	for _, slot := range slots {
+		if slot.Code != code {
+			continue
+		}
+		switch slot.Status() {
+		case SLOT_UNASSIGNED:
+			return Slot{}, errors.New("slot " + code + " is unassigned")
+		case SLOT_JAMMED:
+			return Slot{}, errors.New("slot " + code + " is jammed")
+		case SLOT_EMPTY:
+			return Slot{}, errors.New("slot " + code + " is empty")
+		}
+		return slot, nil
+	}
+	return Slot{}, errors.New("slot " + code + " doesn't exist")
+}
+
+//slotReason - why product that has no available piece can't be vended from its slots
+func slotReason(product Product, slots []Slot) error {
+	assigned := false
+	for _, slot := range slots {
+		if slot.ProductNo != product.ProductNo {
+			continue
+		}
+		assigned = true
+		if slot.Jammed && slot.Stock > 0 {
+			return errors.New("slot " + slot.Code + " of " + product.Name + " is jammed")
+		}
+	}
+	if !assigned {
+		return errors.New(product.Name + " is not assigned to any slot")
+	}
+	return errors.New(product.Name + " is out of stock")
+}
+
+//isSlotCode - whether user selected a slot code rather than a product no.
+func isSlotCode(selection string) bool {
+	if selection == "" {
+		return false
+	}
+	row := strings.ToUpper(selection[:1])[0]
+	return row >= 'A' && row <= 'Z'
+}
+
+//validateSlots - every slot has a unique code, a product of products or none and stock within its capacity
+func validateSlots(products []Product, slots []Slot) error {
+	codes := make(map[string]bool)
+	capacities := make(map[int64]int64)
+	for _, slot := range slots {
+		if !ValidSlotCode(slot.Code) {
+			return errors.New("slot code " + slot.Code + " must be a row letter and a column number like A1")
+		}
+		if codes[slot.Code] {
+			return errors.New("slot " + slot.Code + " is duplicated")
+		}
+		codes[slot.Code] = true
+
+		if slot.Capacity < 1 {
+			return errors.New("slot " + slot.Code + "'s capacity must be greater than zero")
+		}
+		if slot.Stock < 0 || slot.Stock > slot.Capacity {
+			return errors.New("slot " + slot.Code + "'s stock must be between 0 and its capacity")
+		}
+		if slot.ProductNo == 0 {
+			if slot.Stock != 0 {
+				return errors.New("slot " + slot.Code + " is unassigned so its stock must be zero")
+			}
+			continue
+		}
+		if !hasProductNo(products, slot.ProductNo) {
+			return errors.New("product of slot " + slot.Code + " doesn't exist")
+		}
+
+		//product's stock is the sum of its slots, so it must fit in int64 even when every slot is full
+		if slot.Capacity > math.MaxInt64-capacities[slot.ProductNo] {
+			return errors.New("capacity of slot " + slot.Code + " is exceeded, its product's stock can't be counted")
+		}
+		capacities[slot.ProductNo] = capacities[slot.ProductNo] + slot.Capacity
+	}
+	return nil
+}
+
+//syncStock - set stock of each product to the pieces in its slots that are not jammed
+func syncStock(products []Product, slots []Slot) {
+	for i, product := range products {
+		products[i].Stock = slotStock(product.ProductNo, slots)
+	}
+}
+
+//slotStock - pieces of product no. productNo in slots that are not jammed
+func slotStock(productNo int64, slots []Slot) int64 {
+	var stock int64
+	for _, slot := range slots {
+		if slot.ProductNo == productNo && !slot.Jammed {
+			stock = stock + slot.Stock
+		}
+	}
+	return stock
+}
+
+func hasProductNo(products []Product, productNo int64) bool {
+	for _, product := range products {
+		if product.ProductNo == productNo {
+			return true
+		}
+	}
+	return false
+}
+
+func copySlots(slots []Slot) []Slot {
+	if slots == nil {
+		return nil
+	}
+	copied := make([]Slot, len(slots))
+	copy(copied, slots)
+	return copied
+}
@@ -0,0 +1,463 @@
+package product
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"vending-machine/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var slotProducts = []Product{
+	{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+	{ProductNo: 2, Name: "Hanami", Price: money.Baht(10)},
+	{ProductNo: 3, Name: "Kitkat", Price: money.Baht(25)},
+}
+
+func newSlotInventory(t *testing.T) *Inventory {
+	inv := NewInventory(slotProducts)
+	assert.NoError(t, inv.LoadSlots([]Slot{
+		{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 2},
+		{Code: "A2", ProductNo: 1, Capacity: 5, Stock: 4},
+		{Code: "B1", ProductNo: 2, Capacity: 5, Stock: 0},
+		{Code: "B2", ProductNo: 3, Capacity: 5, Stock: 3, Jammed: true},
+		{Code: "B3", Capacity: 5},
+	}))
+	return inv
+}
+
+func Test_checkProduct_slots(t *testing.T) {
+	tests := []struct {
+		description   string
+		selection     string
+		expected      Product
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description: "test_check_slot_success",
+			selection:   "A1",
+			expected:    Product{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+			hasError:    false,
+		},
+		{
+			description: "test_check_slot_success_lower_case",
+			selection:   "a2",
+			expected:    Product{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+			hasError:    false,
+		},
+		{
+			description: "test_check_product_no_success",
+			selection:   "1",
+			expected:    Product{ProductNo: 1, Name: "Lays", Price: money.Baht(5)},
+			hasError:    false,
+		},
+		{
+			description:   "test_check_slot_failed_empty",
+			selection:     "B1",
+			expectedError: errors.New("slot B1 is empty"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_slot_failed_jammed",
+			selection:     "B2",
+			expectedError: errors.New("slot B2 is jammed"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_slot_failed_unassigned",
+			selection:     "B3",
+			expectedError: errors.New("slot B3 is unassigned"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_slot_failed_doesnt_exist",
+			selection:     "C1",
+			expectedError: errors.New("slot C1 doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_product_no_failed_slots_are_empty",
+			selection:     "2",
+			expectedError: errors.New("Hanami is out of stock"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_product_no_failed_slot_is_jammed",
+			selection:     "3",
+			expectedError: errors.New("slot B2 of Kitkat is jammed"),
+			hasError:      true,
+		},
+		{
+			description:   "test_check_product_no_failed_product_doesnt_exist",
+			selection:     "9",
+			expectedError: errors.New("product doesn't exist"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inv := newSlotInventory(t)
+			output, err := checkProduct(test.selection, inv.List(), inv.ListSlots())
+
+			if test.hasError {
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, test.expected, output)
+			}
+		})
+	}
+}
+
+func Test_checkProduct_unassigned_product(t *testing.T) {
+	inv := NewInventory(append(slotProducts, Product{ProductNo: 4, Name: "Pepsi", Price: money.Baht(15)}))
+	assert.NoError(t, inv.LoadSlots([]Slot{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 1}}))
+
+	_, err := checkProduct("4", inv.List(), inv.ListSlots())
+	assert.Equal(t, errors.New("Pepsi is not assigned to any slot"), err)
+}
+
+func Test_Inventory_LoadSlots(t *testing.T) {
+	tests := []struct {
+		description   string
+		slots         []Slot
+		expectedStock []int64
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description:   "test_load_slots_success_stock_without_jammed_slots",
+			slots:         []Slot{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 2}, {Code: "A2", ProductNo: 1, Capacity: 5, Stock: 3}, {Code: "B1", ProductNo: 2, Capacity: 5, Stock: 4, Jammed: true}},
+			expectedStock: []int64{5, 0, 0},
+			hasError:      false,
+		},
+		{
+			description:   "test_load_slots_failed_invalid_code",
+			slots:         []Slot{{Code: "1A", ProductNo: 1, Capacity: 5}},
+			expectedError: errors.New("slot code 1A must be a row letter and a column number like A1"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_duplicated",
+			slots:         []Slot{{Code: "A1", ProductNo: 1, Capacity: 5}, {Code: "A1", ProductNo: 2, Capacity: 5}},
+			expectedError: errors.New("slot A1 is duplicated"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_no_capacity",
+			slots:         []Slot{{Code: "A1", ProductNo: 1}},
+			expectedError: errors.New("slot A1's capacity must be greater than zero"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_stock_over_capacity",
+			slots:         []Slot{{Code: "A1", ProductNo: 1, Capacity: 5, Stock: 6}},
+			expectedError: errors.New("slot A1's stock must be between 0 and its capacity"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_stock_of_unassigned_slot",
+			slots:         []Slot{{Code: "A1", Capacity: 5, Stock: 1}},
+			expectedError: errors.New("slot A1 is unassigned so its stock must be zero"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_product_doesnt_exist",
+			slots:         []Slot{{Code: "A1", ProductNo: 9, Capacity: 5}},
+			expectedError: errors.New("product of slot A1 doesn't exist"),
+			hasError:      true,
+		},
+		{
+			description:   "test_load_slots_failed_capacity_exceeded",
+			slots:         []Slot{{Code: "A1", ProductNo: 1, Capacity: math.MaxInt64}, {Code: "A2", ProductNo: 1, Capacity: 1}},
+			expectedError: errors.New("capacity of slot A2 is exceeded, its product's stock can't be counted"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inv := NewInventory(slotProducts)
+			err := inv.LoadSlots(test.slots)
+
+			if test.hasError {
+				assert.Equal(t, test.expectedError, err)
+				assert.Nil(t, inv.ListSlots())
+			} else {
+				assert.NoError(t, err)
+				stock := []int64{}
+				for _, prod := range inv.List() {
+					stock = append(stock, prod.Stock)
+				}
+				assert.Equal(t, test.expectedStock, stock)
+			}
+		})
+	}
+}
+
+func Test_DrawSlot(t *testing.T) {
+	tests := []struct {
+		description   string
+		code          string
+		amount        int64
+		expectedStock []int64 //stock of every slot after drawing
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description:   "test_draw_slot_success_from_less_full_slot",
+			code:          "A1",
+			amount:        2,
+			expectedStock: []int64{0, 4, 0, 3, 0},
+			hasError:      false,
+		},
+		{
+			description:   "test_draw_slot_failed_slot_is_empty",
+			code:          "A1",
+			amount:        3,
+			expectedError: errors.New("slot A1 is empty"),
+			hasError:      true,
+		},
+		{
+			description:   "test_draw_slot_failed_slot_is_jammed",
+			code:          "B2",
+			amount:        1,
+			expectedError: errors.New("slot B2 is jammed"),
+			hasError:      true,
+		},
+		{
+			description:   "test_draw_slot_failed_slot_is_unassigned",
+			code:          "B3",
+			amount:        1,
+			expectedError: errors.New("slot B3 is unassigned"),
+			hasError:      true,
+		},
+		{
+			description:   "test_draw_slot_failed_slot_does_not_exist",
+			code:          "C1",
+			amount:        1,
+			expectedError: errors.New("slot C1 doesn't exist"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			slots := newSlotInventory(t).ListSlots()
+			productNo, err := DrawSlot(slots, test.code, test.amount)
+
+			if test.hasError {
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(1), productNo)
+				stock := []int64{}
+				for _, slot := range slots {
+					stock = append(stock, slot.Stock)
+				}
+				assert.Equal(t, test.expectedStock, stock)
+			}
+		})
+	}
+}
+
+func Test_DrawSlots(t *testing.T) {
+	tests := []struct {
+		description   string
+		productNo     int64
+		amount        int64
+		held          map[string]int64
+		expectedStock []int64 //stock of every slot after drawing
+		expectedError error
+		hasError      bool
+	}{
+		{
+			description:   "test_draw_slots_success_from_fullest_slot",
+			productNo:     1,
+			amount:        1,
+			expectedStock: []int64{2, 3, 0, 3, 0},
+			hasError:      false,
+		},
+		{
+			description:   "test_draw_slots_success_keeps_held_pieces",
+			productNo:     1,
+			amount:        1,
+			held:          map[string]int64{"A2": 3},
+			expectedStock: []int64{1, 4, 0, 3, 0},
+			hasError:      false,
+		},
+		{
+			description:   "test_draw_slots_success_fullest_slot_changes_while_drawing",
+			productNo:     1,
+			amount:        4,
+			expectedStock: []int64{1, 1, 0, 3, 0},
+			hasError:      false,
+		},
+		{
+			description:   "test_draw_slots_failed_slots_are_empty",
+			productNo:     1,
+			amount:        7,
+			expectedError: errors.New("slots of product no. 1 are empty"),
+			hasError:      true,
+		},
+		{
+			description:   "test_draw_slots_failed_slot_is_jammed",
+			productNo:     3,
+			amount:        1,
+			expectedError: errors.New("slots of product no. 3 are empty"),
+			hasError:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			slots := newSlotInventory(t).ListSlots()
+			err := DrawSlots(slots, test.productNo, test.amount, test.held)
+
+			if test.hasError {
+				assert.Equal(t, test.expectedError, err)
+			} else {
+				assert.NoError(t, err)
+				stock := []int64{}
+				for _, slot := range slots {
+					stock = append(stock, slot.Stock)
+				}
+				assert.Equal(t, test.expectedStock, stock)
+			}
+		})
+	}
+}
+
+func Test_Inventory_Restock_slots(t *testing.T) {
+	inv := newSlotInventory(t)
+
+	//pieces go to the emptiest slot of the product first
+	assert.NoError(t, inv.Restock(1, 3))
+	slots := inv.ListSlots()
+	assert.Equal(t, int64(5), slots[0].Stock)
+	assert.Equal(t, int64(4), slots[1].Stock)
+	assert.Equal(t, int64(9), inv.List()[0].Stock)
+
+	assert.Equal(t, errors.New("Lays's slots are full"), inv.Restock(1, 2))
+	assert.Equal(t, errors.New("Lays's slots are full"), inv.Restock(1, math.MaxInt64))
+	assert.Equal(t, int64(9), inv.List()[0].Stock)
+
+	inv.Products = append(inv.Products, Product{ProductNo: 4, Name: "Pepsi", Price: money.Baht(15)})
+	assert.Equal(t, errors.New("Pepsi is not assigned to any slot"), inv.Restock(4, 1))
+}
+
+func Test_Inventory_SetJammed(t *testing.T) {
+	inv := newSlotInventory(t)
+
+	//pieces of a jammed slot are not in product's stock until it's cleared
+	assert.NoError(t, inv.SetJammed("a2", true))
+	assert.Equal(t, int64(2), inv.List()[0].Stock)
+	assert.NoError(t, inv.SetJammed("A2", false))
+	assert.Equal(t, int64(6), inv.List()[0].Stock)
+
+	assert.Equal(t, errors.New("slot C9 doesn't exist"), inv.SetJammed("C9", true))
+}
+
+func Test_Inventory_AssignSlot(t *testing.T) {
+	tests := []struct {
+		description   string
+		code          string
+		productNo     int64
+		expectedSlots []Slot
+		expectedError error
+	}{
+		{
+			description:   "test_assign_unassigned_slot",
+			code:          "b3",
+			productNo:     2,
+			expectedSlots: []Slot{{Code: "B1", ProductNo: 2, Capacity: 5, Stock: 0}, {Code: "B3", ProductNo: 2, Capacity: 5}},
+		},
+		{
+			description:   "test_assign_empty_slot_to_another_product",
+			code:          "B1",
+			productNo:     1,
+			expectedSlots: []Slot{{Code: "B1", ProductNo: 1, Capacity: 5, Stock: 0}, {Code: "B3", Capacity: 5}},
+		},
+		{
+			description:   "test_unassign_empty_slot",
+			code:          "B1",
+			productNo:     0,
+			expectedSlots: []Slot{{Code: "B1", Capacity: 5}},
+		},
+		{
+			description:   "test_assign_slot_that_has_pieces_of_another_product",
+			code:          "A1",
+			productNo:     2,
+			expectedError: errors.New("slot A1 must be empty to be assigned to another product"),
+		},
+		{
+			description:   "test_assign_slot_to_product_that_doesnt_exist",
+			code:          "B3",
+			productNo:     9,
+			expectedError: errors.New("product of slot B3 doesn't exist"),
+		},
+		{
+			description:   "test_assign_slot_that_doesnt_exist",
+			code:          "C9",
+			productNo:     1,
+			expectedError: errors.New("slot C9 doesn't exist"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			inv := newSlotInventory(t)
+			before := inv.ListSlots()
+
+			err := inv.AssignSlot(test.code, test.productNo)
+			if test.expectedError != nil {
+				assert.Equal(t, test.expectedError, err)
+				assert.Equal(t, before, inv.ListSlots())
+				return
+			}
+			assert.NoError(t, err)
+			for _, expectedSlot := range test.expectedSlots {
+				assert.Contains(t, inv.ListSlots(), expectedSlot)
+			}
+		})
+	}
+}
+
+func Test_Inventory_slots_of_added_and_removed_product(t *testing.T) {
+	inv := newSlotInventory(t)
+
+	assert.Equal(t, errors.New("Pepsi's stock must be loaded into a slot"), inv.AddProduct(Product{ProductNo: 4, Name: "Pepsi", Price: money.Baht(15), Stock: 1}))
+	assert.NoError(t, inv.AddProduct(Product{ProductNo: 4, Name: "Pepsi", Price: money.Baht(15)}))
+
+	//slots of removed product are left unassigned
+	_, err := inv.RemoveProduct(1)
+	assert.NoError(t, err)
+	slots := inv.ListSlots()
+	assert.Equal(t, SLOT_UNASSIGNED, slots[0].Status())
+	assert.Equal(t, SLOT_UNASSIGNED, slots[1].Status())
+
+	//added product is sold from a slot that is freed by the removal once it's assigned and restocked
+	assert.Equal(t, errors.New("Pepsi is not assigned to any slot"), inv.Restock(4, 3))
+	assert.NoError(t, inv.AssignSlot("A1", 4))
+	assert.NoError(t, inv.Restock(4, 3))
+	assert.Equal(t, int64(3), inv.List()[2].Stock)
+	assert.Equal(t, Slot{Code: "A1", ProductNo: 4, Capacity: 5, Stock: 3}, inv.ListSlots()[0])
+}
+
+func Test_ListAllProducts_slots(t *testing.T) {
+	output := bytes.Buffer{}
+	newSlotInventory(t).ListAllProducts(&output)
+
+	assert.Contains(t, output.String(), "List of slots\n"+
+		"Slot      Product   Stock     Capacity  Status\n"+
+		"-----------------------------------------------\n"+
+		"A1        Lays      2         5         ok\n"+
+		"A2        Lays      4         5         ok\n"+
+		"B1        Hanami    0         5         empty\n"+
+		"B2        Kitkat    3         5         jammed\n"+
+		"B3                  0         5         unassigned\n"+
+		"-----------------------------------------------\n")
+}
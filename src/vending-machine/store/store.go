@@ -0,0 +1,89 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"vending-machine/payment"
+)
+
+//FileStore - keep machine's state in a JSON file
+type FileStore struct {
+	Path string
+}
+
+//NewFileStore - create store that keeps machine's state in file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+//Load - read machine's state from file, error wraps os.ErrNotExist when nothing has been saved yet
+func (s *FileStore) Load() (payment.State, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return payment.State{}, err
+	}
+
+	var state payment.State
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return payment.State{}, err
+	}
+	return state, nil
+}
+
+//Save - write machine's state to file atomically,
+//state is written to a temporary file in the same directory first then renamed over the old file,
+//so a crash while saving leaves either the old state or the new state but never a partial one
+func (s *FileStore) Save(state payment.State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.Path)
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(s.Path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	//remove temporary file if anything fails before rename, after rename it doesn't exist anymore
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	//flush to disk before rename so the renamed file is never empty after a crash
+	err = tmpFile.Sync()
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpFile.Name(), s.Path)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+//syncDir - flush directory entry so the rename survives a crash
+func syncDir(dir string) error {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer dirFile.Close()
+
+	//some platforms can't sync a directory, the rename is still atomic there
+	dirFile.Sync()
+	return nil
+}
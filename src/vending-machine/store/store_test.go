@@ -0,0 +1,145 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"vending-machine/money"
+	"vending-machine/payment"
+	"vending-machine/product"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commonPrepData() payment.State {
+	return payment.State{
+		Products: []product.Product{
+			{
+				ProductNo: 1,
+				Name:      "Lays",
+				Price:     money.Baht(5),
+				Stock:     1,
+			},
+		},
+		Money: []money.Money{
+			{
+				MoneyType: money.COIN,
+				Name:      "10",
+				Value:     money.Baht(10),
+				Stock:     3,
+			},
+		},
+	}
+}
+
+func Test_FileStore_Save_Load(t *testing.T) {
+	tests := []struct {
+		description string
+		input       []payment.State
+		expected    payment.State
+	}{
+		{
+			description: "test_save_load_success",
+			input:       []payment.State{commonPrepData()},
+			expected:    commonPrepData(),
+		},
+		{
+			description: "test_save_load_success_with_pending_transaction",
+			input: []payment.State{
+				{
+					Products: commonPrepData().Products,
+					Money:    commonPrepData().Money,
+					Pending: map[string]*payment.PendingTransaction{"4f1c": {
+						StartedAt:   time.Date(2021, 8, 1, 10, 0, 0, 0, time.UTC),
+						TotalAmount: money.Baht(5),
+						Products:    map[int64]int64{1: 1},
+					}},
+				},
+			},
+			expected: payment.State{
+				Products: commonPrepData().Products,
+				Money:    commonPrepData().Money,
+				Pending: map[string]*payment.PendingTransaction{"4f1c": {
+					StartedAt:   time.Date(2021, 8, 1, 10, 0, 0, 0, time.UTC),
+					TotalAmount: money.Baht(5),
+					Products:    map[int64]int64{1: 1},
+				}},
+			},
+		},
+		{
+			description: "test_save_load_success_with_overwrite",
+			input: []payment.State{
+				commonPrepData(),
+				{
+					Products: []product.Product{},
+					Money:    []money.Money{},
+				},
+			},
+			expected: payment.State{
+				Products: []product.Product{},
+				Money:    []money.Money{},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dir := t.TempDir()
+			stateStore := NewFileStore(filepath.Join(dir, "state.json"))
+
+			for _, state := range test.input {
+				err := stateStore.Save(state)
+				assert.NoError(t, err)
+			}
+
+			output, err := stateStore.Load()
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, output)
+
+			//no temporary file is left behind
+			files, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+func Test_FileStore_Load_failed(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileStore(filepath.Join(dir, "state.json")).Load()
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	err = os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"products": [`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewFileStore(filepath.Join(dir, "broken.json")).Load()
+	assert.Error(t, err)
+}
+
+func Test_FileStore_Save_failed_keeps_old_state(t *testing.T) {
+	dir := t.TempDir()
+	stateStore := NewFileStore(filepath.Join(dir, "state.json"))
+
+	err := stateStore.Save(commonPrepData())
+	assert.NoError(t, err)
+
+	//directory can't be written anymore so the new state can't be saved
+	err = os.Chmod(dir, 0500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0700)
+	if os.Getuid() == 0 {
+		t.Skip("root can write to read-only directory")
+	}
+
+	err = stateStore.Save(payment.State{})
+	assert.Error(t, err)
+
+	output, err := stateStore.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, commonPrepData(), output)
+}